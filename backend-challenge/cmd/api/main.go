@@ -1,72 +1,371 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/ilyulev/kart-challenge/backend-api/internal/auth"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/config"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/handlers"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/lifecycle"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/metrics"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/middleware"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/pricing"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/ratelimit"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/requestid"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/services"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/tracing"
 
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/otel"
 )
 
 func main() {
 	// Load configuration
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fatal(slog.Default(), "Failed to load configuration", err)
+	}
 
-	// Initialize promo code service - fail fast on errors
-	promoService := services.NewPromoCodeService()
-	log.Println("Initializing promo code service...")
+	// Everything logs through one logger; the standard log package, used by
+	// net/http for example, writes to it as well
+	logger, err := logging.New(os.Stdout, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		fatal(slog.Default(), "Failed to create logger", err)
+	}
+	slog.SetDefault(logger)
+
+	// Everything started below is stopped in reverse order on SIGTERM
+	app := lifecycle.NewManager(cfg.ShutdownTimeout, logger)
+
+	// Components register their metrics here, served on /metrics
+	registry := metrics.NewRegistry()
+
+	// Spans go to the configured exporter. The provider is registered first
+	// so it stops last, flushing the spans of everything stopped before it.
+	exporter, err := tracing.NewExporter(app.Context(), cfg.TraceExporter, os.Stdout)
+	if err != nil {
+		fatal(logger, "Failed to create trace exporter", err)
+	}
+	tracerProvider, err := tracing.NewProvider(app.Context(), exporter, cfg.TraceSampleRatio)
+	if err != nil {
+		fatal(logger, "Failed to create tracer provider", err)
+	}
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(tracing.Propagator)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warn("Tracing failed", "error", err)
+	}))
+	app.Register("tracer provider", tracerProvider.Shutdown)
+
+	// Resolve coupon sources, falling back to the published files
+	var promoOptions []services.PromoOption
+	if len(cfg.CouponSources) > 0 {
+		sources, err := services.NewCouponSources(cfg.CouponSources)
+		if err != nil {
+			fatal(logger, "Invalid coupon sources", err)
+		}
+		promoOptions = append(promoOptions, services.WithCouponSources(sources...))
+	}
+	if cfg.PromoSnapshotPath != "" {
+		promoOptions = append(promoOptions, services.WithSnapshotPath(cfg.PromoSnapshotPath))
+	}
+	if cfg.CouponCacheDir != "" {
+		promoOptions = append(promoOptions, services.WithCacheDir(cfg.CouponCacheDir))
+	}
+	if cfg.CouponManifest != "" {
+		manifest, err := services.LoadChecksumManifest(cfg.CouponManifest)
+		if err != nil {
+			fatal(logger, "Invalid coupon manifest", err)
+		}
+		promoOptions = append(promoOptions, services.WithChecksumManifest(manifest))
+	}
+	promoOptions = append(promoOptions, services.WithDownloadConcurrency(cfg.CouponDownloadConcurrency),
+		services.WithLogger(logger.With("component", "promo")), services.WithMetrics(registry),
+		services.WithTracerProvider(tracerProvider))
+
+	partialPolicy, err := services.ParsePartialPolicy(cfg.PromoPartialPolicy)
+	if err != nil {
+		fatal(logger, "Invalid promo policy", err)
+	}
+	promoOptions = append(promoOptions, services.WithPolicy(services.PromoPolicy{
+		MinWeight:     cfg.PromoMinWeight,
+		PartialPolicy: partialPolicy,
+	}))
 
-	if err := promoService.Initialize(); err != nil {
-		log.Fatalf("Failed to initialize promo codes: %v", err)
+	// Initialize promo code service - fail fast on errors
+	promoService := services.NewPromoCodeService(promoOptions...)
+	if err := promoService.Initialize(app.Context()); err != nil {
+		fatal(logger, "Failed to initialize promo codes", err)
 		// Fail fast - better than running with broken promo validation
 	}
+	app.Register("promo code service", promoService.Shutdown)
+
+	logger.Info("Promo code service ready", "codes", promoService.GetValidCodesCount())
 
-	log.Printf("Promo code service ready with %d valid codes",
-		promoService.GetValidCodesCount())
+	// Map promo codes to discount rules
+	pricingEngine := pricing.NewEngine()
+	for code, rule := range cfg.PromoRules {
+		if err := pricingEngine.MapCode(code, rule); err != nil {
+			fatal(logger, "Invalid promo rules", err)
+		}
+	}
 
-	// Create Echo instance
+	// Open the product catalog, shared by the product and order handlers
+	var products repository.ProductRepository
+	if cfg.ProductsFile != "" {
+		products, err = repository.NewFileProductRepository(cfg.ProductsFile)
+		if err != nil {
+			fatal(logger, "Failed to load products", err)
+		}
+		logger.Info("Products are stored in a file", "path", cfg.ProductsFile)
+	} else {
+		products, err = repository.NewMemoryProductRepository(repository.DefaultCategories(), repository.DefaultProducts())
+		if err != nil {
+			fatal(logger, "Failed to load products", err)
+		}
+	}
+
+	// Open the order store, in memory unless a database file is configured.
+	// Redemptions and stock share its database so usage caps and stock
+	// levels survive restarts.
+	var orders repository.OrderRepository = repository.NewMemoryOrderRepository()
+	var redemptionStore repository.RedemptionStore = repository.NewMemoryRedemptionStore()
+	var inventory repository.InventoryStore = repository.NewMemoryInventoryStore()
+	if cfg.OrderStorePath != "" {
+		boltOrders, err := repository.NewBoltOrderRepository(cfg.OrderStorePath)
+		if err != nil {
+			fatal(logger, "Failed to open order store", err)
+		}
+		app.Register("order store", func(context.Context) error {
+			return boltOrders.Close()
+		})
+		orders = boltOrders
+		redemptionStore = boltOrders.Redemptions()
+		inventory = boltOrders.Inventory()
+		logger.Info("Orders are stored in a database", "path", cfg.OrderStorePath)
+	}
+
+	// Load promo code dates and redemption caps
+	var promoTerms []services.PromoTerms
+	if cfg.PromoTermsFile != "" {
+		promoTerms, err = services.LoadPromoTermsFile(cfg.PromoTermsFile)
+		if err != nil {
+			fatal(logger, "Failed to load promo terms", err)
+		}
+	}
+	redemptions, err := services.NewPromoRedemptions(promoTerms, redemptionStore)
+	if err != nil {
+		fatal(logger, "Invalid promo terms", err)
+	}
+
+	// Load API keys from config and the optional key file
+	keyRing, err := auth.NewKeyRing(cfg.LoadAPIKeys, cfg.APIKeysGracePeriod)
+	if err != nil {
+		fatal(logger, "Failed to load API keys", err)
+	}
+	if keyRing.Keys().Len() == 0 {
+		logger.Warn("No API keys configured, protected endpoints will reject every request")
+	}
+	if cfg.APIKeysFile != "" && cfg.APIKeysReloadInterval > 0 {
+		app.Go("API key file watcher", func(ctx context.Context) {
+			keyRing.Watch(ctx, cfg.APIKeysFile, cfg.APIKeysReloadInterval)
+		})
+	}
+	app.Go("SIGHUP key reloader", func(ctx context.Context) {
+		reloadKeysOnHangup(ctx, logger, keyRing)
+	})
+
+	// Create Echo instance. Its banner is left out, the logger reports startup.
 	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	// Trust X-Forwarded-For only from private networks, so clients cannot
+	// pick the IP they are rate limited by
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	// Error bodies name the request, see middleware.RequestID
+	e.JSONSerializer = middleware.RequestIDSerializer{}
+	e.HTTPErrorHandler = middleware.HTTPErrorHandler
 
-	// Apply middleware
-	e.Use(echomiddleware.Logger())
-	e.Use(echomiddleware.Recover())
-	e.Use(echomiddleware.CORS())
-	e.Use(echomiddleware.LoggerWithConfig(echomiddleware.LoggerConfig{
-		Format: "${time_rfc3339} ${method} ${uri} ${status} ${latency_human}\n",
+	// Apply middleware. The request log also names the request ID, the API
+	// key used, so requests can be traced to an integration, and the trace.
+	// Only RequestLogger hands errors to the error handler; the middleware
+	// inside it records them and passes them on.
+	e.Use(middleware.RequestID())
+	e.Use(middleware.RequestLogger(logger))
+	e.Use(middleware.Tracing(tracerProvider))
+	e.Use(middleware.Metrics(registry))
+	e.Use(echomiddleware.RecoverWithConfig(echomiddleware.RecoverConfig{
+		DisableStackAll:     true,
+		DisableErrorHandler: true,
+		LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
+			logging.FromContext(c.Request().Context(), logger).Error("Handler panicked", "error", err, "stack", string(stack))
+			return err
+		},
+	}))
+	e.Use(echomiddleware.CORSWithConfig(echomiddleware.CORSConfig{
+		// Browser clients page through products with the Link header, and
+		// can report the request ID of a failed request
+		ExposeHeaders: []string{"Link", requestid.Header},
 	}))
 
 	// Initialize handlers
-	productHandler := handlers.NewProductHandler()
-	orderHandler := handlers.NewOrderHandler(promoService)
+	productHandler := handlers.NewProductHandler(products, inventory, logger)
+	categoryHandler := handlers.NewCategoryHandler(products, inventory, logger)
+	orderHandler := handlers.NewOrderHandler(promoService, pricingEngine, products, inventory, orders, redemptions, logger, registry)
 	healthHandler := handlers.NewHealthHandler(promoService)
+	adminHandler := handlers.NewAdminHandler(promoService)
+	inventoryHandler := handlers.NewInventoryHandler(products, inventory, logger)
+	var mediaHandler *handlers.MediaHandler
+	if cfg.MediaDir != "" {
+		mediaHandler = handlers.NewMediaHandler(cfg.MediaDir, logger)
+		logger.Info("Serving media", "dir", cfg.MediaDir)
+	}
 
 	// Register all routes
-	registerRoutes(e, productHandler, orderHandler, healthHandler)
+	orderKeyLimit, orderIPLimit := cfg.RateLimit(config.RateLimitGroupOrders)
+	productKeyLimit, productIPLimit := cfg.RateLimit(config.RateLimitGroupProducts)
+	registerRoutes(e, productHandler, categoryHandler, orderHandler, healthHandler, adminHandler, inventoryHandler, mediaHandler, routeOptions{
+		keys:             keyRing,
+		metrics:          registry,
+		idempotencyStore: repository.NewMemoryIdempotencyStore(),
+		idempotencyTTL:   cfg.IdempotencyTTL,
+		rateLimitStore:   ratelimit.NewMemoryStore(),
+		orderLimits: middleware.RateLimitPolicy{
+			Group: config.RateLimitGroupOrders, PerKey: orderKeyLimit, PerIP: orderIPLimit,
+		},
+		productLimits: middleware.RateLimitPolicy{
+			Group: config.RateLimitGroupProducts, PerKey: productKeyLimit, PerIP: productIPLimit,
+		},
+	})
+
+	// Start server. On shutdown it stops taking connections and waits for
+	// in-flight requests, before the stores they use are closed.
+	app.Register("HTTP server", e.Shutdown)
+	logger.Info("Starting server", "port", cfg.Port, "url", "http://localhost:"+cfg.Port)
+	go func() {
+		if err := e.Start(":" + cfg.Port); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			app.Fail(fmt.Errorf("server stopped: %w", err))
+		}
+	}()
+
+	failure := app.Wait(syscall.SIGINT, syscall.SIGTERM)
+	if failure != nil {
+		logger.Error("Shutting down", "error", failure)
+	}
+	if err := app.Shutdown(); err != nil {
+		fatal(logger, "Shutdown incomplete", err)
+	}
+	if failure != nil {
+		os.Exit(1)
+	}
+	logger.Info("Shutdown complete")
+}
 
-	// Start server
-	log.Printf("Starting Echo server on port %s", cfg.Port)
-	log.Printf("Access your API at: http://localhost:%s", cfg.Port)
-	e.Logger.Fatal(e.Start(":" + cfg.Port))
+// fatal logs err and exits
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// reloadKeysOnHangup reloads the API keys whenever the process gets SIGHUP,
+// until ctx is done
+func reloadKeysOnHangup(ctx context.Context, logger *slog.Logger, keyRing *auth.KeyRing) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+		}
+
+		if err := keyRing.Reload(); err != nil {
+			logger.Error("API key reload failed, keeping current keys", "error", err)
+			continue
+		}
+		keys := keyRing.Keys()
+		logger.Info("API keys reloaded on SIGHUP", "keys", keys.Len(), "retiring", keys.Retiring())
+	}
+}
+
+// routeOptions holds what the route middleware needs
+type routeOptions struct {
+	keys             auth.KeyLookup
+	metrics          *metrics.Registry
+	idempotencyStore repository.IdempotencyStore
+	idempotencyTTL   time.Duration
+	rateLimitStore   ratelimit.Store
+	orderLimits      middleware.RateLimitPolicy
+	productLimits    middleware.RateLimitPolicy
 }
 
 // registerRoutes centralizes all route registration
-func registerRoutes(e *echo.Echo, productHandler *handlers.ProductHandler, orderHandler *handlers.OrderHandler, healthHandler *handlers.HealthHandler) {
+func registerRoutes(e *echo.Echo, productHandler *handlers.ProductHandler, categoryHandler *handlers.CategoryHandler, orderHandler *handlers.OrderHandler, healthHandler *handlers.HealthHandler,
+	adminHandler *handlers.AdminHandler, inventoryHandler *handlers.InventoryHandler, mediaHandler *handlers.MediaHandler, opts routeOptions) {
 	// API routes group - DON'T apply middleware to the entire group
 	api := e.Group("/api")
 
 	// Product routes (no auth required for GET)
-	api.GET("/product", productHandler.ListProducts)
-	api.GET("/product/:productId", productHandler.GetProduct)
+	productLimit := middleware.RateLimit(opts.rateLimitStore, opts.productLimits)
+	api.GET("/product", productHandler.ListProducts, productLimit)
+	api.GET("/product/:productId", productHandler.GetProduct, productLimit)
+	api.GET("/category", categoryHandler.ListCategories, productLimit)
+	api.GET("/category/:slug/products", categoryHandler.ListCategoryProducts, productLimit)
+
+	// Catalog changes (auth with the manage_products scope required).
+	// The IP limit comes before auth so failed attempts count, the key
+	// limit after it.
+	productAuth := middleware.APIKeyAuth(opts.keys, auth.ScopeManageProducts)
+	productKeyLimit := middleware.RateLimitKey(opts.rateLimitStore, opts.productLimits)
+	api.POST("/product", productHandler.CreateProduct, productLimit, productAuth, productKeyLimit)
+	api.PUT("/product/:productId", productHandler.UpdateProduct, productLimit, productAuth, productKeyLimit)
+	api.PATCH("/product/:productId", productHandler.PatchProduct, productLimit, productAuth, productKeyLimit)
+	api.DELETE("/product/:productId", productHandler.DeleteProduct, productLimit, productAuth, productKeyLimit)
+
+	// Order routes (auth required) - apply auth middleware only to these routes,
+	// between the IP and the key limit like the catalog changes
+	orderLimit := middleware.RateLimit(opts.rateLimitStore, opts.orderLimits)
+	orderKeyLimit := middleware.RateLimitKey(opts.rateLimitStore, opts.orderLimits)
+	api.POST("/order", orderHandler.PlaceOrder, orderLimit, middleware.APIKeyAuth(opts.keys, auth.ScopeCreateOrder),
+		orderKeyLimit, middleware.Idempotency(opts.idempotencyStore, opts.idempotencyTTL))
+	api.GET("/order", orderHandler.ListOrders, orderLimit, middleware.APIKeyAuth(opts.keys, auth.ScopeReadOrders), orderKeyLimit)
+	api.GET("/order/:orderId", orderHandler.GetOrder, orderLimit, middleware.APIKeyAuth(opts.keys, auth.ScopeReadOrders), orderKeyLimit)
 
-	// Order routes (auth required) - apply auth middleware only to these routes
-	api.POST("/order", orderHandler.PlaceOrder, middleware.APIKeyAuth())
+	// Admin routes (auth with the admin scope required for the whole group)
+	admin := e.Group("/admin", middleware.APIKeyAuth(opts.keys, auth.ScopeAdmin))
+	admin.POST("/promo/reload", adminHandler.ReloadPromoCodes)
+	admin.GET("/promo/downloads", adminHandler.PromoDownloads)
+	admin.GET("/promo/errors", adminHandler.PromoLoadErrors)
+	admin.GET("/promo/codes/:code", adminHandler.CheckPromoCode)
+	admin.GET("/inventory", inventoryHandler.ListStock)
+	admin.POST("/inventory/:productId/restock", inventoryHandler.Restock)
+	admin.PUT("/inventory/:productId", inventoryHandler.SetStock)
+	admin.DELETE("/inventory/:productId", inventoryHandler.Untrack)
+
+	// Product images (only with a media directory)
+	if mediaHandler != nil {
+		e.GET(handlers.MediaPath+"/*", mediaHandler.ServeMedia)
+		admin.POST("/media", mediaHandler.UploadMedia)
+	}
 
 	// Health check endpoints (no auth required)
 	e.GET("/health", healthHandler.Health)
 	e.GET("/health/live", healthHandler.LivenessProbe)
 	e.GET("/health/ready", healthHandler.ReadinessProbe)
+
+	// Prometheus scrape endpoint (no auth required)
+	e.GET("/metrics", echo.WrapHandler(opts.metrics.Handler()))
 }
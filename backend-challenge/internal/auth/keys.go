@@ -0,0 +1,186 @@
+// Package auth holds the API keys clients authenticate with.
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/ratelimit"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scopes granted to API keys
+const (
+	ScopeCreateOrder    = "create_order"    // Place orders
+	ScopeReadOrders     = "read_orders"     // Look up placed orders
+	ScopeManageProducts = "manage_products" // Change the product catalog
+	ScopeAdmin          = "admin"           // Operate the service through /admin
+	ScopeAll            = "*"               // Every scope
+)
+
+var (
+	// ErrInvalidKey is returned for missing or unknown keys
+	ErrInvalidKey = errors.New("invalid or missing API key")
+	// ErrKeyExpired is returned for keys past their expiry
+	ErrKeyExpired = errors.New("API key expired")
+)
+
+// APIKey is a named client credential
+type APIKey struct {
+	Name      string    `yaml:"name"`
+	Key       string    `yaml:"key"`
+	Scopes    []string  `yaml:"scopes"`
+	ExpiresAt time.Time `yaml:"expiresAt"` // Zero never expires
+
+	// RateLimit replaces the route group's per-key limit for this key
+	RateLimit ratelimit.Limit `yaml:"rateLimit"`
+}
+
+// HasScope reports whether the key grants scope
+func (k APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope) || slices.Contains(k.Scopes, ScopeAll)
+}
+
+// Expired reports whether the key has expired at now
+func (k APIKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// KeyLookup finds the key a request presented
+type KeyLookup interface {
+	Lookup(secret string) (APIKey, error)
+}
+
+// KeySet is an immutable set of API keys
+type KeySet struct {
+	keys     []APIKey
+	digests  [][sha256.Size]byte
+	retiring int // Trailing keys kept only for a rotation grace period
+	now      func() time.Time
+}
+
+// NewKeySet validates keys and builds a set. Names and secrets must be
+// unique and every key needs a secret.
+func NewKeySet(keys []APIKey) (*KeySet, error) {
+	return newKeySet(keys, time.Now)
+}
+
+// newKeySet is NewKeySet with a custom clock
+func newKeySet(keys []APIKey, now func() time.Time) (*KeySet, error) {
+	s := &KeySet{now: now}
+	names := make(map[string]bool, len(keys))
+
+	for _, key := range keys {
+		if key.Name == "" {
+			return nil, fmt.Errorf("API key without name")
+		}
+		if names[key.Name] {
+			return nil, fmt.Errorf("duplicate API key name %q", key.Name)
+		}
+		if key.Key == "" {
+			return nil, fmt.Errorf("API key %q has no secret", key.Name)
+		}
+		digest := sha256.Sum256([]byte(key.Key))
+		if i := slices.Index(s.digests, digest); i >= 0 {
+			return nil, fmt.Errorf("API key %q reuses the secret of %q", key.Name, s.keys[i].Name)
+		}
+		names[key.Name] = true
+
+		s.keys = append(s.keys, key)
+		s.digests = append(s.digests, digest)
+	}
+	return s, nil
+}
+
+// withRetired returns a copy of s that also accepts the keys of prev it
+// dropped, until deadline at the latest. Keys already expired are left out.
+func (s *KeySet) withRetired(prev *KeySet, deadline time.Time) *KeySet {
+	merged := &KeySet{
+		keys:     slices.Clone(s.keys),
+		digests:  slices.Clone(s.digests),
+		retiring: s.retiring,
+		now:      s.now,
+	}
+	if prev == nil {
+		return merged
+	}
+
+	now := s.now()
+	for i, key := range prev.keys {
+		if slices.Contains(s.digests, prev.digests[i]) || key.Expired(now) || !deadline.After(now) {
+			continue
+		}
+		if key.ExpiresAt.IsZero() || key.ExpiresAt.After(deadline) {
+			key.ExpiresAt = deadline
+		}
+		merged.keys = append(merged.keys, key)
+		merged.digests = append(merged.digests, prev.digests[i])
+		merged.retiring++
+	}
+	return merged
+}
+
+// Lookup returns the key matching secret. Every key is compared in constant
+// time, so timing reveals neither the secret nor which key matched.
+func (s *KeySet) Lookup(secret string) (APIKey, error) {
+	if secret == "" {
+		return APIKey{}, ErrInvalidKey
+	}
+
+	digest := sha256.Sum256([]byte(secret))
+	match := -1
+	for i := range s.digests {
+		if subtle.ConstantTimeCompare(digest[:], s.digests[i][:]) == 1 {
+			match = i
+		}
+	}
+
+	if match < 0 {
+		return APIKey{}, ErrInvalidKey
+	}
+	if s.keys[match].Expired(s.now()) {
+		return APIKey{}, ErrKeyExpired
+	}
+	return s.keys[match], nil
+}
+
+// Len returns the number of keys in the set, retiring ones included
+func (s *KeySet) Len() int {
+	return len(s.keys)
+}
+
+// Retiring returns the number of keys only kept for a rotation grace period
+func (s *KeySet) Retiring() int {
+	return s.retiring
+}
+
+// keyFile is the layout of an API key file
+type keyFile struct {
+	Keys []APIKey `yaml:"keys"`
+}
+
+// LoadKeyFile reads API keys from a YAML (or JSON) file:
+//
+//	keys:
+//	  - name: pos-terminal
+//	    key: s3cr3t
+//	    scopes: [create_order]
+//	    expiresAt: 2026-01-01T00:00:00Z
+func LoadKeyFile(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API key file: %w", err)
+	}
+
+	var file keyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse API key file %s: %w", path, err)
+	}
+	return file.Keys, nil
+}
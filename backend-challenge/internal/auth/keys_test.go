@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySet_Lookup(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	set, err := NewKeySet([]APIKey{
+		{Name: "pos", Key: "pos-secret", Scopes: []string{ScopeCreateOrder}},
+		{Name: "support", Key: "support-secret", Scopes: []string{ScopeReadOrders}},
+		{Name: "old", Key: "old-secret", Scopes: []string{ScopeAll}, ExpiresAt: now},
+	})
+	require.NoError(t, err)
+	set.now = func() time.Time { return now }
+
+	key, err := set.Lookup("support-secret")
+	require.NoError(t, err)
+	assert.Equal(t, "support", key.Name)
+	assert.True(t, key.HasScope(ScopeReadOrders))
+	assert.False(t, key.HasScope(ScopeCreateOrder))
+
+	_, err = set.Lookup("unknown")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = set.Lookup("")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = set.Lookup("pos-secre")
+	assert.ErrorIs(t, err, ErrInvalidKey, "Prefixes do not match")
+
+	_, err = set.Lookup("old-secret")
+	assert.ErrorIs(t, err, ErrKeyExpired)
+
+	set.now = func() time.Time { return now.Add(-time.Second) }
+	key, err = set.Lookup("old-secret")
+	require.NoError(t, err)
+	assert.True(t, key.HasScope("anything"), "The wildcard grants every scope")
+}
+
+func TestNewKeySet_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []APIKey
+	}{
+		{"Missing name", []APIKey{{Key: "secret"}}},
+		{"Missing secret", []APIKey{{Name: "pos"}}},
+		{"Duplicate name", []APIKey{{Name: "pos", Key: "a"}, {Name: "pos", Key: "b"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeySet(tt.keys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	content := `
+keys:
+  - name: pos-terminal
+    key: pos-secret
+    scopes: [create_order]
+    expiresAt: 2026-01-01T00:00:00Z
+    rateLimit: 300/m
+  - name: support
+    key: support-secret
+    scopes: [read_orders]
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	keys, err := LoadKeyFile(path)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "pos-terminal", keys[0].Name)
+	assert.Equal(t, []string{ScopeCreateOrder}, keys[0].Scopes)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), keys[0].ExpiresAt)
+	assert.True(t, keys[1].ExpiresAt.IsZero())
+	assert.Equal(t, ratelimit.Limit{Requests: 300, Period: time.Minute}, keys[0].RateLimit)
+	assert.True(t, keys[1].RateLimit.Unlimited(), "Keys without a limit use the route group's")
+
+	_, err = LoadKeyFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("keys: [not, a, key"), 0o600))
+	_, err = LoadKeyFile(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+)
+
+// KeyRing serves the current API key set and swaps in new sets on reload.
+// Lookups read an atomic snapshot and never take a lock.
+//
+// Keys removed by a reload keep working for the grace period, so clients can
+// move to a rotated key without a window where neither key is accepted.
+type KeyRing struct {
+	current atomic.Pointer[KeySet]
+	load    func() ([]APIKey, error)
+	grace   time.Duration
+	now     func() time.Time
+
+	reloadMutex sync.Mutex // Serializes reloads
+}
+
+// NewKeyRing loads the initial key set with load, which is called again on
+// every reload. Keys dropped by a reload stay valid for grace.
+func NewKeyRing(load func() ([]APIKey, error), grace time.Duration) (*KeyRing, error) {
+	r := &KeyRing{load: load, grace: grace, now: time.Now}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Lookup returns the key matching secret in the current set
+func (r *KeyRing) Lookup(secret string) (APIKey, error) {
+	return r.current.Load().Lookup(secret)
+}
+
+// Keys returns the current key set
+func (r *KeyRing) Keys() *KeySet {
+	return r.current.Load()
+}
+
+// Reload loads the keys again and swaps them in. On error the current keys
+// stay in place.
+func (r *KeyRing) Reload() error {
+	r.reloadMutex.Lock()
+	defer r.reloadMutex.Unlock()
+
+	keys, err := r.load()
+	if err != nil {
+		return fmt.Errorf("failed to load API keys: %w", err)
+	}
+	next, err := newKeySet(keys, r.now)
+	if err != nil {
+		return fmt.Errorf("invalid API keys: %w", err)
+	}
+
+	r.current.Store(next.withRetired(r.current.Load(), r.now().Add(r.grace)))
+	return nil
+}
+
+// Watch reloads the ring whenever the file at path changes, checking every
+// interval until ctx is done. Failed reloads are logged and retried on the
+// next change, to the logger of ctx.
+func (r *KeyRing) Watch(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger := logging.FromContext(ctx, slog.Default())
+	last := statFile(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := statFile(path)
+		if current == last {
+			continue
+		}
+		last = current
+
+		if err := r.Reload(); err != nil {
+			logger.Error("API key reload failed, keeping current keys", "path", path, "error", err)
+			continue
+		}
+		keys := r.Keys()
+		logger.Info("API keys reloaded", "path", path, "keys", keys.Len(), "retiring", keys.Retiring())
+	}
+}
+
+// fileVersion identifies a version of a file by size and modification time
+type fileVersion struct {
+	size    int64
+	modTime time.Time
+}
+
+// statFile returns the version of the file at path, zero if it is missing
+func statFile(path string) fileVersion {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}
+	}
+	return fileVersion{size: info.Size(), modTime: info.ModTime()}
+}
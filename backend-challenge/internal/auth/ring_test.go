@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRing_ReloadWithGracePeriod(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	current := []APIKey{{Name: "pos", Key: "old-secret", Scopes: []string{ScopeCreateOrder}}}
+	var loadErr error
+
+	ring, err := NewKeyRing(func() ([]APIKey, error) { return current, loadErr }, 10*time.Minute)
+	require.NoError(t, err)
+	ring.now = func() time.Time { return now }
+	ring.current.Load().now = ring.now
+
+	// Rotate the secret of the same integration
+	current = []APIKey{{Name: "pos", Key: "new-secret", Scopes: []string{ScopeCreateOrder}}}
+	require.NoError(t, ring.Reload())
+	assert.Equal(t, 2, ring.Keys().Len())
+	assert.Equal(t, 1, ring.Keys().Retiring())
+
+	key, err := ring.Lookup("new-secret")
+	require.NoError(t, err)
+	assert.Equal(t, "pos", key.Name)
+	key, err = ring.Lookup("old-secret")
+	require.NoError(t, err, "The old key overlaps during the grace period")
+	assert.Equal(t, "pos", key.Name)
+
+	// A failed reload keeps the current keys
+	loadErr = errors.New("disk on fire")
+	assert.Error(t, ring.Reload())
+	_, err = ring.Lookup("old-secret")
+	assert.NoError(t, err)
+	loadErr = nil
+
+	// Reloading again does not extend the old key's grace period
+	now = now.Add(9 * time.Minute)
+	require.NoError(t, ring.Reload())
+	_, err = ring.Lookup("old-secret")
+	assert.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	_, err = ring.Lookup("old-secret")
+	assert.ErrorIs(t, err, ErrKeyExpired)
+	_, err = ring.Lookup("new-secret")
+	assert.NoError(t, err)
+
+	require.NoError(t, ring.Reload())
+	assert.Equal(t, 1, ring.Keys().Len(), "Expired retiring keys are dropped")
+}
+
+func TestKeyRing_RejectsInvalidKeys(t *testing.T) {
+	_, err := NewKeyRing(func() ([]APIKey, error) {
+		return []APIKey{{Name: "a", Key: "same"}, {Name: "b", Key: "same"}}, nil
+	}, time.Minute)
+	assert.ErrorContains(t, err, "reuses the secret")
+}
+
+func TestKeyRing_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	write := func(secret string) {
+		content := "keys:\n  - name: pos\n    key: " + secret + "\n    scopes: [create_order]\n"
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	write("first-secret")
+
+	ring, err := NewKeyRing(func() ([]APIKey, error) { return LoadKeyFile(path) }, 0)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ring.Watch(ctx, path, 10*time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	write("second-secret-longer")
+
+	assert.Eventually(t, func() bool {
+		_, err := ring.Lookup("second-secret-longer")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = ring.Lookup("first-secret")
+	assert.ErrorIs(t, err, ErrInvalidKey, "Without a grace period the old key stops at once")
+}
+
+func TestKeyRing_ConcurrentLookupAndReload(t *testing.T) {
+	ring, err := NewKeyRing(func() ([]APIKey, error) {
+		return []APIKey{{Name: "pos", Key: "secret", Scopes: []string{ScopeAll}}}, nil
+	}, time.Minute)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if _, err := ring.Lookup("secret"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		require.NoError(t, ring.Reload())
+	}
+	wg.Wait()
+}
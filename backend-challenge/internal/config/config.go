@@ -1,23 +1,305 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/auth"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/ratelimit"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/tracing"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Config holds application configuration
 type Config struct {
-	Port     string
-	LogLevel string
-	APIKey   string
+	Port      string `yaml:"port"`
+	LogLevel  string `yaml:"logLevel"`  // debug, info, warn or error
+	LogFormat string `yaml:"logFormat"` // json or text
+	APIKey    string `yaml:"apiKey"`    // Single key with every scope, empty to disable
+
+	// APIKeys are named keys with scopes and optional expiry
+	APIKeys []auth.APIKey `yaml:"apiKeys"`
+
+	// APIKeysFile is a YAML file of more keys, see auth.LoadKeyFile.
+	// It is watched and reloaded on change or SIGHUP.
+	APIKeysFile string `yaml:"apiKeysFile"`
+
+	// APIKeysGracePeriod is how long keys removed by a reload keep working
+	APIKeysGracePeriod time.Duration `yaml:"apiKeysGracePeriod"`
+
+	// APIKeysReloadInterval is how often APIKeysFile is checked for changes,
+	// zero to only reload on SIGHUP
+	APIKeysReloadInterval time.Duration `yaml:"apiKeysReloadInterval"`
+
+	// CouponSources lists where promo coupon files are loaded from.
+	// Empty means the published challenge files.
+	CouponSources []string `yaml:"couponSources"`
+
+	// PromoSnapshotPath is where the computed valid-code set is persisted
+	// for fast warm starts. Empty disables the snapshot.
+	PromoSnapshotPath string `yaml:"promoSnapshotPath"`
+
+	// CouponCacheDir keeps downloaded coupon files between loads so unchanged
+	// files are reused and interrupted downloads resume. Empty uses a temp dir.
+	CouponCacheDir string `yaml:"couponCacheDir"`
+
+	// CouponDownloadConcurrency limits how many sources are fetched at once
+	CouponDownloadConcurrency int `yaml:"couponDownloadConcurrency"`
+
+	// CouponManifest is an optional sha256sum style file of expected checksums
+	CouponManifest string `yaml:"couponManifest"`
+
+	// PromoMinWeight is the summed source weight a code needs to be valid
+	PromoMinWeight int `yaml:"promoMinWeight"`
+
+	// PromoPartialPolicy decides what is served when some coupon files are
+	// missing: fail_closed, snapshot or degrade
+	PromoPartialPolicy string `yaml:"promoPartialPolicy"`
+
+	// PromoRules maps promo codes to discount rules, on top of the
+	// published HAPPYHOURS and BUYGETONE codes
+	PromoRules map[string]string `yaml:"promoRules"`
+
+	// PromoTermsFile is a YAML file of promo code dates and redemption caps,
+	// see services.LoadPromoTermsFile. Empty leaves every code unrestricted.
+	PromoTermsFile string `yaml:"promoTermsFile"`
+
+	// ProductsFile is the JSON or YAML file the product catalog is kept in,
+	// created with the default catalog if missing. Empty keeps the default
+	// catalog in memory only.
+	ProductsFile string `yaml:"productsFile"`
+
+	// MediaDir is the directory product images are served from under
+	// /media and uploaded to. Empty disables both.
+	MediaDir string `yaml:"mediaDir"`
+
+	// OrderStorePath is the embedded database file orders are kept in.
+	// Empty keeps orders in memory only.
+	OrderStorePath string `yaml:"orderStorePath"`
+
+	// IdempotencyTTL is how long an Idempotency-Key replays its response
+	IdempotencyTTL time.Duration `yaml:"idempotencyTTL"`
+
+	// ShutdownTimeout is how long in-flight requests and background work get
+	// to finish after SIGTERM before the process exits
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+
+	// TraceExporter is where spans are sent: none, stdout or otlp. The OTLP
+	// endpoint is set with the standard OTEL_EXPORTER_OTLP_* variables.
+	TraceExporter string `yaml:"traceExporter"`
+
+	// TraceSampleRatio is the share of new traces recorded, 0 to 1. Requests
+	// continuing a trace follow the caller's sampling decision.
+	TraceSampleRatio float64 `yaml:"traceSampleRatio"`
+
+	// RateLimits maps "<group>.key" and "<group>.ip" to token bucket limits
+	// such as "60/m", see ratelimit.ParseLimit
+	RateLimits map[string]string `yaml:"rateLimits"`
 }
 
-// Load loads configuration from environment variables with defaults
-func Load() *Config {
-	return &Config{
-		Port:     getEnv("PORT", "8080"),
-		LogLevel: getEnv("LOG_LEVEL", "info"),
-		APIKey:   getEnv("API_KEY", "apitest"),
+// Route groups with their own rate limits
+const (
+	RateLimitGroupOrders   = "orders"
+	RateLimitGroupProducts = "products"
+)
+
+// Load loads configuration with defaults, then the optional CONFIG_FILE,
+// then environment variables, each overriding the previous
+func Load() (*Config, error) {
+	cfg := &Config{
+		Port:      "8080",
+		LogLevel:  "info",
+		LogFormat: logging.FormatJSON,
+		APIKey:    "apitest",
+
+		CouponDownloadConcurrency: 3,
+		PromoMinWeight:            2,
+		PromoPartialPolicy:        "degrade",
+		IdempotencyTTL:            24 * time.Hour,
+		ShutdownTimeout:           20 * time.Second,
+		TraceExporter:             tracing.ExporterNone,
+		TraceSampleRatio:          1,
+		APIKeysGracePeriod:        15 * time.Minute,
+		APIKeysReloadInterval:     5 * time.Second,
+		RateLimits: map[string]string{
+			RateLimitGroupOrders + ".key": "60/m",
+			RateLimitGroupOrders + ".ip":  "120/m",
+		},
+	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.Port = getEnv("PORT", cfg.Port)
+	cfg.LogLevel = getEnv("LOG_LEVEL", cfg.LogLevel)
+	cfg.LogFormat = getEnv("LOG_FORMAT", cfg.LogFormat)
+	cfg.APIKey = getEnv("API_KEY", cfg.APIKey)
+	cfg.APIKeysFile = getEnv("API_KEYS_FILE", cfg.APIKeysFile)
+	cfg.CouponSources = getEnvList("COUPON_SOURCES", cfg.CouponSources)
+	cfg.PromoSnapshotPath = getEnv("PROMO_SNAPSHOT_PATH", cfg.PromoSnapshotPath)
+	cfg.CouponCacheDir = getEnv("COUPON_CACHE_DIR", cfg.CouponCacheDir)
+	cfg.CouponManifest = getEnv("COUPON_MANIFEST", cfg.CouponManifest)
+	cfg.PromoPartialPolicy = getEnv("PROMO_PARTIAL_POLICY", cfg.PromoPartialPolicy)
+	cfg.PromoTermsFile = getEnv("PROMO_TERMS_FILE", cfg.PromoTermsFile)
+	cfg.OrderStorePath = getEnv("ORDER_STORE_PATH", cfg.OrderStorePath)
+	cfg.ProductsFile = getEnv("PRODUCTS_FILE", cfg.ProductsFile)
+	cfg.MediaDir = getEnv("MEDIA_DIR", cfg.MediaDir)
+	cfg.TraceExporter = getEnv("TRACE_EXPORTER", cfg.TraceExporter)
+
+	rules, err := getEnvMap("PROMO_RULES", cfg.PromoRules)
+	if err != nil {
+		return nil, err
+	}
+	cfg.PromoRules = rules
+
+	concurrency, err := getEnvInt("COUPON_DOWNLOAD_CONCURRENCY", cfg.CouponDownloadConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	cfg.CouponDownloadConcurrency = concurrency
+
+	minWeight, err := getEnvInt("PROMO_MIN_WEIGHT", cfg.PromoMinWeight)
+	if err != nil {
+		return nil, err
+	}
+	if minWeight < 1 {
+		return nil, fmt.Errorf("invalid PROMO_MIN_WEIGHT %d: must be at least 1", minWeight)
+	}
+	cfg.PromoMinWeight = minWeight
+
+	ttl, err := getEnvDuration("IDEMPOTENCY_TTL", cfg.IdempotencyTTL)
+	if err != nil {
+		return nil, err
 	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid IDEMPOTENCY_TTL %s: must be positive", ttl)
+	}
+	cfg.IdempotencyTTL = ttl
+
+	if _, err := logging.ParseLevel(cfg.LogLevel); err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL %s: must be debug, info, warn or error", cfg.LogLevel)
+	}
+	if err := logging.ValidateFormat(cfg.LogFormat); err != nil {
+		return nil, fmt.Errorf("invalid LOG_FORMAT %s: must be json or text", cfg.LogFormat)
+	}
+
+	if err := tracing.ValidateExporter(cfg.TraceExporter); err != nil {
+		return nil, fmt.Errorf("invalid TRACE_EXPORTER %s: must be none, stdout or otlp", cfg.TraceExporter)
+	}
+	sampleRatio, err := getEnvFloat("TRACE_SAMPLE_RATIO", cfg.TraceSampleRatio)
+	if err != nil {
+		return nil, err
+	}
+	if sampleRatio < 0 || sampleRatio > 1 {
+		return nil, fmt.Errorf("invalid TRACE_SAMPLE_RATIO %g: must be between 0 and 1", sampleRatio)
+	}
+	cfg.TraceSampleRatio = sampleRatio
+
+	shutdownTimeout, err := getEnvDuration("SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if shutdownTimeout <= 0 {
+		return nil, fmt.Errorf("invalid SHUTDOWN_TIMEOUT %s: must be positive", shutdownTimeout)
+	}
+	cfg.ShutdownTimeout = shutdownTimeout
+
+	grace, err := getEnvDuration("API_KEYS_GRACE_PERIOD", cfg.APIKeysGracePeriod)
+	if err != nil {
+		return nil, err
+	}
+	if grace < 0 {
+		return nil, fmt.Errorf("invalid API_KEYS_GRACE_PERIOD %s: must not be negative", grace)
+	}
+	cfg.APIKeysGracePeriod = grace
+
+	interval, err := getEnvDuration("API_KEYS_RELOAD_INTERVAL", cfg.APIKeysReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+	if interval < 0 {
+		return nil, fmt.Errorf("invalid API_KEYS_RELOAD_INTERVAL %s: must not be negative", interval)
+	}
+	cfg.APIKeysReloadInterval = interval
+
+	// RATE_LIMITS overrides single entries, keeping the others
+	rateLimits, err := getEnvMap("RATE_LIMITS", nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, spec := range rateLimits {
+		if cfg.RateLimits == nil {
+			cfg.RateLimits = make(map[string]string)
+		}
+		cfg.RateLimits[name] = spec
+	}
+	for name, spec := range cfg.RateLimits {
+		group, kind, ok := strings.Cut(name, ".")
+		if !ok || group == "" || (kind != "key" && kind != "ip") {
+			return nil, fmt.Errorf("invalid rate limit name %q: expected <group>.key or <group>.ip", name)
+		}
+		if _, err := ratelimit.ParseLimit(spec); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// RateLimit returns the per API key and per IP limits of a route group.
+// Groups without configured limits are unlimited.
+func (c *Config) RateLimit(group string) (perKey, perIP ratelimit.Limit) {
+	// Entries were validated by Load
+	perKey, _ = ratelimit.ParseLimit(c.RateLimits[group+".key"])
+	perIP, _ = ratelimit.ParseLimit(c.RateLimits[group+".ip"])
+	return perKey, perIP
+}
+
+// DefaultKeyScopes are the scopes of APIKey: placing and reading orders,
+// what the single key granted before scopes. The default key is public, so
+// admin and catalog changes need an explicitly configured key.
+var DefaultKeyScopes = []string{auth.ScopeCreateOrder, auth.ScopeReadOrders}
+
+// LoadAPIKeys collects every configured API key: APIKey as "default" with
+// DefaultKeyScopes, then APIKeys, then the keys in APIKeysFile
+func (c *Config) LoadAPIKeys() ([]auth.APIKey, error) {
+	var keys []auth.APIKey
+	if c.APIKey != "" {
+		keys = append(keys, auth.APIKey{Name: "default", Key: c.APIKey, Scopes: slices.Clone(DefaultKeyScopes)})
+	}
+	keys = append(keys, c.APIKeys...)
+
+	if c.APIKeysFile != "" {
+		fileKeys, err := auth.LoadKeyFile(c.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+	}
+	return keys, nil
+}
+
+// loadFile merges settings from a YAML (or JSON) file into the config
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
 }
 
 // getEnv gets environment variable with fallback
@@ -27,3 +309,79 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getEnvInt gets an integer environment variable with fallback
+func getEnvInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return n, nil
+}
+
+// getEnvFloat gets a decimal environment variable with fallback
+func getEnvFloat(key string, fallback float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return f, nil
+}
+
+// getEnvDuration gets a duration environment variable such as "24h" with fallback
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return d, nil
+}
+
+// getEnvMap gets a comma separated list of key=value pairs with fallback
+func getEnvMap(key string, fallback map[string]string) (map[string]string, error) {
+	list := getEnvList(key, nil)
+	if list == nil {
+		return fallback, nil
+	}
+
+	m := make(map[string]string, len(list))
+	for _, item := range list {
+		k, v, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(k) == "" || strings.TrimSpace(v) == "" {
+			return nil, fmt.Errorf("invalid %s entry %q: expected key=value", key, item)
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return m, nil
+}
+
+// getEnvList gets a comma separated environment variable with fallback
+func getEnvList(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
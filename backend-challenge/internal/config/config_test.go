@@ -0,0 +1,280 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Defaults(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("PORT", "")
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("LOG_FORMAT", "")
+	t.Setenv("API_KEY", "")
+	t.Setenv("COUPON_SOURCES", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "8080", cfg.Port)
+	assert.Equal(t, "info", cfg.LogLevel)
+	assert.Equal(t, "json", cfg.LogFormat)
+	assert.Equal(t, "apitest", cfg.APIKey)
+	assert.Empty(t, cfg.CouponSources)
+}
+
+func TestLoad_FileThenEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+port: "9090"
+logLevel: debug
+couponSources:
+  - /data/couponbase1.gz
+  - https://mirror.local/couponbase2.gz
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("PORT", "")
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("COUPON_SOURCES", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "9090", cfg.Port, "File value should override default")
+	assert.Equal(t, "warn", cfg.LogLevel, "Env value should override file")
+	assert.Equal(t, []string{"/data/couponbase1.gz", "https://mirror.local/couponbase2.gz"}, cfg.CouponSources)
+
+	t.Setenv("COUPON_SOURCES", "dir:///data/coupons, ,/tmp/extra.gz")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir:///data/coupons", "/tmp/extra.gz"}, cfg.CouponSources)
+}
+
+func TestLoad_InvalidFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+
+	_, err := Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_CouponDownloadSettings(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("COUPON_CACHE_DIR", "/var/cache/coupons")
+	t.Setenv("COUPON_MANIFEST", "/etc/coupons.sha256")
+	t.Setenv("COUPON_DOWNLOAD_CONCURRENCY", "5")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "/var/cache/coupons", cfg.CouponCacheDir)
+	assert.Equal(t, "/etc/coupons.sha256", cfg.CouponManifest)
+	assert.Equal(t, 5, cfg.CouponDownloadConcurrency)
+
+	t.Setenv("COUPON_DOWNLOAD_CONCURRENCY", "many")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_PromoPolicy(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("PROMO_MIN_WEIGHT", "")
+	t.Setenv("PROMO_PARTIAL_POLICY", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 2, cfg.PromoMinWeight)
+	assert.Equal(t, "degrade", cfg.PromoPartialPolicy)
+
+	t.Setenv("PROMO_MIN_WEIGHT", "3")
+	t.Setenv("PROMO_PARTIAL_POLICY", "fail_closed")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.PromoMinWeight)
+	assert.Equal(t, "fail_closed", cfg.PromoPartialPolicy)
+
+	t.Setenv("PROMO_MIN_WEIGHT", "0")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_IdempotencyTTL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("idempotencyTTL: 90m\n"), 0o644))
+
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("IDEMPOTENCY_TTL", "")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, cfg.IdempotencyTTL)
+
+	t.Setenv("CONFIG_FILE", path)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, cfg.IdempotencyTTL)
+
+	t.Setenv("IDEMPOTENCY_TTL", "10m")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, cfg.IdempotencyTTL)
+
+	for _, value := range []string{"soon", "-1h", "0s"} {
+		t.Setenv("IDEMPOTENCY_TTL", value)
+		_, err = Load()
+		assert.Error(t, err, value)
+	}
+}
+
+func TestLoad_ShutdownTimeout(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("SHUTDOWN_TIMEOUT", "")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 20*time.Second, cfg.ShutdownTimeout)
+
+	t.Setenv("SHUTDOWN_TIMEOUT", "45s")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 45*time.Second, cfg.ShutdownTimeout)
+
+	for _, value := range []string{"later", "-5s", "0s"} {
+		t.Setenv("SHUTDOWN_TIMEOUT", value)
+		_, err = Load()
+		assert.Error(t, err, value)
+	}
+}
+
+func TestLoad_Logging(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("LOG_LEVEL", "DEBUG")
+	t.Setenv("LOG_FORMAT", "text")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "DEBUG", cfg.LogLevel)
+	assert.Equal(t, "text", cfg.LogFormat)
+
+	t.Setenv("LOG_LEVEL", "verbose")
+	_, err = Load()
+	assert.ErrorContains(t, err, "invalid LOG_LEVEL verbose")
+
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("LOG_FORMAT", "xml")
+	_, err = Load()
+	assert.ErrorContains(t, err, "invalid LOG_FORMAT xml")
+}
+
+func TestLoad_Tracing(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "none", cfg.TraceExporter, "Tracing works without a collector by default")
+	assert.Equal(t, 1.0, cfg.TraceSampleRatio)
+
+	t.Setenv("TRACE_EXPORTER", "otlp")
+	t.Setenv("TRACE_SAMPLE_RATIO", "0.25")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "otlp", cfg.TraceExporter)
+	assert.Equal(t, 0.25, cfg.TraceSampleRatio)
+
+	t.Setenv("TRACE_SAMPLE_RATIO", "1.5")
+	_, err = Load()
+	assert.ErrorContains(t, err, "invalid TRACE_SAMPLE_RATIO 1.5")
+
+	t.Setenv("TRACE_SAMPLE_RATIO", "")
+	t.Setenv("TRACE_EXPORTER", "zipkin")
+	_, err = Load()
+	assert.ErrorContains(t, err, "invalid TRACE_EXPORTER zipkin")
+}
+
+func TestLoad_PromoRules(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("PROMO_RULES", "WELCOME1=happy_hours, SPRING24 = buy_get_one")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"WELCOME1": "happy_hours", "SPRING24": "buy_get_one"}, cfg.PromoRules)
+
+	t.Setenv("PROMO_RULES", "WELCOME1")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "keys.yaml")
+	require.NoError(t, os.WriteFile(keyFile, []byte("keys:\n  - name: pos\n    key: pos-secret\n    scopes: [create_order]\n"), 0o644))
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"apiKeys:\n  - name: reporting\n    key: report-secret\n    scopes: [read_orders]\n"), 0o644))
+
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("API_KEY", "")
+	t.Setenv("API_KEYS_FILE", keyFile)
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	keys, err := cfg.LoadAPIKeys()
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	assert.Equal(t, "default", keys[0].Name)
+	assert.Equal(t, "apitest", keys[0].Key, "API_KEY stays the default key")
+	assert.Equal(t, []string{"create_order", "read_orders"}, keys[0].Scopes, "The public default key cannot administer")
+	assert.Equal(t, "reporting", keys[1].Name)
+	assert.Equal(t, "pos", keys[2].Name)
+
+	cfg.APIKey = ""
+	cfg.APIKeysFile = filepath.Join(dir, "missing.yaml")
+	_, err = cfg.LoadAPIKeys()
+	assert.Error(t, err)
+}
+
+func TestLoad_APIKeyReloadSettings(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("API_KEYS_GRACE_PERIOD", "")
+	t.Setenv("API_KEYS_RELOAD_INTERVAL", "")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, cfg.APIKeysGracePeriod)
+	assert.Equal(t, 5*time.Second, cfg.APIKeysReloadInterval)
+
+	t.Setenv("API_KEYS_GRACE_PERIOD", "1h")
+	t.Setenv("API_KEYS_RELOAD_INTERVAL", "0s")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, cfg.APIKeysGracePeriod)
+	assert.Zero(t, cfg.APIKeysReloadInterval)
+
+	t.Setenv("API_KEYS_GRACE_PERIOD", "-1m")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_RateLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("rateLimits:\n  products.ip: 600/m\n"), 0o644))
+
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("RATE_LIMITS", "orders.key=10/s#burst=20")
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	perKey, perIP := cfg.RateLimit(RateLimitGroupOrders)
+	assert.Equal(t, ratelimit.Limit{Requests: 10, Period: time.Second, Burst: 20}, perKey)
+	assert.Equal(t, ratelimit.Limit{Requests: 120, Period: time.Minute}, perIP, "Defaults stay unless overridden")
+
+	perKey, perIP = cfg.RateLimit(RateLimitGroupProducts)
+	assert.True(t, perKey.Unlimited())
+	assert.Equal(t, ratelimit.Limit{Requests: 600, Period: time.Minute}, perIP)
+
+	for _, value := range []string{"orders.key=fast", "orders.user=10/s", "orders=10/s"} {
+		t.Setenv("RATE_LIMITS", value)
+		_, err = Load()
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AdminHandler handles operational requests for the promo code service
+type AdminHandler struct {
+	promoService *services.PromoCodeService
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(promoService *services.PromoCodeService) *AdminHandler {
+	return &AdminHandler{
+		promoService: promoService,
+	}
+}
+
+// ReloadPromoCodes starts a background reload of the coupon files
+func (h *AdminHandler) ReloadPromoCodes(c echo.Context) error {
+	err := h.promoService.ForceReload(c.Request().Context())
+	if errors.Is(err, services.ErrServiceStopped) {
+		return c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Code:    503,
+			Type:    "error",
+			Message: "The service is shutting down",
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusConflict, models.APIResponse{
+			Code:    409,
+			Type:    "error",
+			Message: "A promo code load is already running",
+		})
+	}
+
+	return c.JSON(http.StatusAccepted, models.APIResponse{
+		Code:    202,
+		Type:    "info",
+		Message: "Promo code reload started",
+	})
+}
+
+// PromoDownloads returns the download progress of the current or last load
+func (h *AdminHandler) PromoDownloads(c echo.Context) error {
+	response := models.PromoDownloads{
+		Loading:   h.promoService.IsLoading(),
+		Downloads: []models.DownloadProgress{},
+	}
+
+	for _, progress := range h.promoService.DownloadProgress() {
+		download := models.DownloadProgress{
+			Source:         progress.Source,
+			File:           progress.File,
+			State:          progress.State,
+			BytesComplete:  progress.BytesComplete,
+			Size:           progress.Size,
+			Percent:        progress.Percent,
+			BytesPerSecond: progress.BytesPerSecond,
+			Resumed:        progress.Resumed,
+			UpdatedAt:      progress.UpdatedAt,
+			Error:          progress.Error,
+		}
+		if !progress.ETA.IsZero() && progress.State == services.DownloadRunning {
+			download.ETA = &progress.ETA
+		}
+		if !progress.StartedAt.IsZero() {
+			download.StartedAt = &progress.StartedAt
+		}
+		response.Downloads = append(response.Downloads, download)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// PromoLoadErrors returns the last load error and recent failed loads
+func (h *AdminHandler) PromoLoadErrors(c echo.Context) error {
+	last, history := h.promoService.LoadErrors()
+
+	response := models.PromoLoadErrors{History: make([]models.PromoLoadError, 0, len(history))}
+	for _, loadErr := range history {
+		response.History = append(response.History, models.PromoLoadError{
+			At:          loadErr.At,
+			DurationMs:  loadErr.Duration.Milliseconds(),
+			Error:       loadErr.Error,
+			Partial:     loadErr.Partial,
+			FilesLoaded: loadErr.FilesLoaded,
+			FilesFailed: loadErr.FilesFailed,
+		})
+	}
+	if last != nil {
+		response.LastError = &response.History[0]
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// CheckPromoCode reports whether a code is valid and which files contain it
+func (h *AdminHandler) CheckPromoCode(c echo.Context) error {
+	check, err := h.promoService.CheckCode(c.Request().Context(), c.Param("code"))
+	if errors.Is(err, services.ErrInvalidCodeFormat) {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid promo code format: " + err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to check promo code",
+		})
+	}
+
+	response := models.PromoCodeCheck{
+		Code:       check.Code,
+		Valid:      check.Valid,
+		DataSource: check.DataSource,
+		MinWeight:  check.MinWeight,
+		Weight:     check.Weight,
+		FoundIn:    promoCodeFiles(check.FoundIn),
+	}
+	if len(check.Unchecked) > 0 {
+		response.Unchecked = promoCodeFiles(check.Unchecked)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// promoCodeFiles converts coupon files to their API representation
+func promoCodeFiles(files []services.CouponFile) []models.PromoCodeFile {
+	result := make([]models.PromoCodeFile, 0, len(files))
+	for _, file := range files {
+		result = append(result, models.PromoCodeFile{Name: file.Name, Source: file.Source, Weight: file.Weight})
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/services"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCouponFile writes a gzipped coupon file into dir
+func writeCouponFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o644))
+}
+
+// newAdminServer routes the admin endpoints the way main does, without auth
+func newAdminServer(promoService *services.PromoCodeService) *echo.Echo {
+	handler := NewAdminHandler(promoService)
+	e := echo.New()
+	e.POST("/admin/promo/reload", handler.ReloadPromoCodes)
+	e.GET("/admin/promo/downloads", handler.PromoDownloads)
+	e.GET("/admin/promo/errors", handler.PromoLoadErrors)
+	e.GET("/admin/promo/codes/:code", handler.CheckPromoCode)
+	return e
+}
+
+func TestAdminHandler_ReloadAndErrors(t *testing.T) {
+	promoService := services.NewPromoCodeService(
+		services.WithCouponSources(services.NewFileSource(filepath.Join(t.TempDir(), "missing.gz"))))
+	e := newAdminServer(promoService)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/admin/promo/errors")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"lastError":null,"history":[]}`, rec.Body.String())
+
+	rec = serve(http.MethodPost, "/admin/promo/reload")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	require.Eventually(t, func() bool { return !promoService.IsLoading() }, 2*time.Second, 5*time.Millisecond)
+
+	rec = serve(http.MethodGet, "/admin/promo/errors")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var loadErrors models.PromoLoadErrors
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &loadErrors))
+	require.NotNil(t, loadErrors.LastError)
+	assert.Contains(t, loadErrors.LastError.Error, "no coupon files fetched")
+	assert.Len(t, loadErrors.History, 1)
+	assert.Equal(t, 1, loadErrors.LastError.FilesFailed)
+
+	rec = serve(http.MethodGet, "/admin/promo/downloads")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"loading":false,"downloads":[]}`, rec.Body.String(), "Local files are not downloaded")
+
+	require.NoError(t, promoService.Shutdown(context.Background()))
+	rec = serve(http.MethodPost, "/admin/promo/reload")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "No reloads while shutting down")
+}
+
+func TestAdminHandler_CheckPromoCode(t *testing.T) {
+	dir := t.TempDir()
+	writeCouponFile(t, dir, "couponbase1.gz", "HAPPYHRS FIFTYOFF")
+	writeCouponFile(t, dir, "couponbase2.gz", "HAPPYHRS")
+
+	promoService := services.NewPromoCodeService(services.WithCouponSources(services.NewDirectorySource(dir)))
+	require.NoError(t, promoService.ForceReload(context.Background()))
+	require.Eventually(t, func() bool { return !promoService.IsLoading() }, 2*time.Second, 5*time.Millisecond)
+	e := newAdminServer(promoService)
+
+	tests := []struct {
+		name           string
+		code           string
+		expectedStatus int
+		valid          bool
+		files          int
+	}{
+		{"Valid code", "happyhrs", http.StatusOK, true, 2},
+		{"Code in one file", "FIFTYOFF", http.StatusOK, false, 1},
+		{"Unknown code", "NOTACODE", http.StatusOK, false, 0},
+		{"Bad format", "abc", http.StatusBadRequest, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/promo/codes/"+tt.code, nil))
+			require.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var check models.PromoCodeCheck
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &check))
+			assert.Equal(t, tt.valid, check.Valid)
+			assert.Len(t, check.FoundIn, tt.files)
+			assert.Equal(t, tt.files, check.Weight)
+			assert.Equal(t, 2, check.MinWeight)
+		})
+	}
+}
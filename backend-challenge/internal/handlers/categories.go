@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CategoryHandler handles product category requests
+type CategoryHandler struct {
+	products  repository.ProductRepository
+	inventory repository.InventoryStore
+	logger    *slog.Logger
+}
+
+// NewCategoryHandler creates a new category handler
+func NewCategoryHandler(products repository.ProductRepository, inventory repository.InventoryStore, logger *slog.Logger) *CategoryHandler {
+	return &CategoryHandler{
+		products:  products,
+		inventory: inventory,
+		logger:    logger,
+	}
+}
+
+// ListCategories returns every category in display order
+func (h *CategoryHandler) ListCategories(c echo.Context) error {
+	categories, err := h.products.Categories(c.Request().Context())
+	if err != nil {
+		requestLogger(c, h.logger).Error("Failed to list categories", "error", err)
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to list categories",
+		})
+	}
+	return c.JSON(http.StatusOK, categories)
+}
+
+// ListCategoryProducts returns the products of one category. It takes the
+// query parameters of ListProducts, except category.
+func (h *CategoryHandler) ListCategoryProducts(c echo.Context) error {
+	category, err := h.products.Category(c.Request().Context(), c.Param("slug"))
+	if errors.Is(err, repository.ErrCategoryNotFound) {
+		return c.JSON(http.StatusNotFound, models.APIResponse{
+			Code:    404,
+			Type:    "error",
+			Message: "Category not found",
+		})
+	}
+	if err != nil {
+		requestLogger(c, h.logger).Error("Failed to get category", "slug", c.Param("slug"), "error", err)
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to get category",
+		})
+	}
+
+	query, err := parseProductQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: err.Error(),
+		})
+	}
+	query.Category = category.Name
+	return listProductPage(c, h.logger, h.products, h.inventory, query)
+}
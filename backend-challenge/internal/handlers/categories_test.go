@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategoryHandler_ListCategories(t *testing.T) {
+	handler := NewCategoryHandler(testProducts(t), repository.NewMemoryInventoryStore(), logging.Discard())
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/api/category", nil), rec)
+
+	require.NoError(t, handler.ListCategories(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"sortOrder":10`)
+
+	var categories []models.Category
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &categories))
+	require.Len(t, categories, 7)
+	assert.Equal(t, models.Category{Slug: "waffle", Name: "Waffle", SortOrder: 10}, categories[0])
+	assert.Equal(t, "dessert", categories[6].Slug)
+	for i := 1; i < len(categories); i++ {
+		assert.Less(t, categories[i-1].SortOrder, categories[i].SortOrder, "Categories are in display order")
+	}
+}
+
+func TestCategoryHandler_ListCategoryProducts(t *testing.T) {
+	handler := NewCategoryHandler(testProducts(t), repository.NewMemoryInventoryStore(), logging.Discard())
+	e := echo.New()
+	e.GET("/api/category/:slug/products", handler.ListCategoryProducts)
+
+	list := func(target string) (*httptest.ResponseRecorder, []string) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			return rec, nil
+		}
+		var products []models.Product
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &products))
+		ids := make([]string, 0, len(products))
+		for _, p := range products {
+			ids = append(ids, p.ID)
+		}
+		return rec, ids
+	}
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedIDs    []string
+	}{
+		{"Category products", "/api/category/waffle/products", http.StatusOK, []string{"1", "2"}},
+		{"Sorted", "/api/category/waffle/products?sort=price", http.StatusOK, []string{"2", "1"}},
+		{"Category parameter is ignored", "/api/category/toast/products?category=waffle", http.StatusOK, []string{"4"}},
+		{"Unknown category", "/api/category/drinks/products", http.StatusNotFound, nil},
+		{"Slugs are exact", "/api/category/Waffle/products", http.StatusNotFound, nil},
+		{"Invalid sort", "/api/category/waffle/products?sort=rating", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, ids := list(tt.target)
+			assert.Equal(t, tt.expectedStatus, rec.Code, rec.Body.String())
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, tt.expectedIDs, ids)
+			}
+		})
+	}
+
+	// Pages link to the next one under the category path
+	rec, ids := list("/api/category/waffle/products?limit=1")
+	assert.Equal(t, []string{"1"}, ids)
+	assert.Contains(t, rec.Header().Get("Link"), "/api/category/waffle/products?")
+}
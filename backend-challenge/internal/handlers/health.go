@@ -37,12 +37,23 @@ func (h *HealthHandler) Health(c echo.Context) error {
 			CodesLoaded:   promoStatus.CodesLoaded,
 			IsFullyLoaded: promoStatus.IsFullyLoaded,
 			LastError:     promoStatus.LastError,
+			Policy: models.PromoPolicyStatus{
+				MinWeight:     promoStatus.Policy.MinWeight,
+				PartialPolicy: promoStatus.Policy.PartialPolicy,
+				SourceWeights: promoStatus.Policy.SourceWeights,
+				FilesLoaded:   promoStatus.Policy.FilesLoaded,
+				FilesFailed:   promoStatus.Policy.FilesFailed,
+				Partial:       promoStatus.Policy.Partial,
+			},
 		},
 	}
 
 	// Determine overall health status
 	httpStatus := http.StatusOK
-	if promoStatus.CodesLoaded == 0 {
+	if promoStatus.Status == "degraded" {
+		response.Status = "degraded"
+		// Still return 200 OK - the partial policy decides what is served
+	} else if promoStatus.CodesLoaded == 0 {
 		response.Status = "starting"
 		// Still return 200 OK for container health checks
 	} else if !promoStatus.IsFullyLoaded && promoStatus.LastError != "" {
@@ -60,10 +71,12 @@ func (h *HealthHandler) LivenessProbe(c echo.Context) error {
 	})
 }
 
-// ReadinessProbe endpoint for container readiness checks
+// ReadinessProbe endpoint for container readiness checks. Orders do not
+// need promo codes, so the service is ready once the promo service serves
+// codes (mock or real) or a load decided what to serve, even no codes at
+// all under the fail_closed policy.
 func (h *HealthHandler) ReadinessProbe(c echo.Context) error {
-	// Service is ready if it has any promo codes (mock or real)
-	if h.promoService.GetValidCodesCount() > 0 {
+	if h.promoService.GetServiceStatus().Status != "initializing" {
 		return c.JSON(http.StatusOK, map[string]string{
 			"status": "ready",
 		})
@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/services"
 
@@ -120,3 +124,22 @@ func TestHealthHandler_ReadinessProbe(t *testing.T) {
 		})
 	}
 }
+
+func TestHealthHandler_ReadinessProbeFailClosed(t *testing.T) {
+	dir := t.TempDir()
+	writeCouponFile(t, dir, "couponbase1.gz", "HAPPYHRS")
+	promoService := services.NewPromoCodeService(
+		services.WithCouponSources(services.NewDirectorySource(dir), services.NewFileSource(filepath.Join(dir, "missing.gz"))),
+		services.WithPolicy(services.PromoPolicy{MinWeight: 1, PartialPolicy: services.PartialFailClosed}),
+		services.WithLogger(logging.Discard()),
+	)
+	require.NoError(t, promoService.Initialize(context.Background()))
+	defer promoService.Shutdown(context.Background())
+	require.Eventually(t, func() bool { return !promoService.IsLoading() }, 5*time.Second, 10*time.Millisecond)
+	require.Zero(t, promoService.GetValidCodesCount(), "A file is missing, so every code is rejected")
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/health/ready", nil), rec)
+	require.NoError(t, NewHealthHandler(promoService).ReadinessProbe(c))
+	assert.Equal(t, http.StatusOK, rec.Code, "Orders are still taken without promo codes")
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxRestockQuantity bounds a single restock, catching typos such as 10000
+// for 100 before they make a product look unlimited
+const maxRestockQuantity = 100000
+
+// InventoryHandler handles stock level requests
+type InventoryHandler struct {
+	products  repository.ProductRepository
+	inventory repository.InventoryStore
+	logger    *slog.Logger
+}
+
+// NewInventoryHandler creates a new inventory handler
+func NewInventoryHandler(products repository.ProductRepository, inventory repository.InventoryStore, logger *slog.Logger) *InventoryHandler {
+	return &InventoryHandler{
+		products:  products,
+		inventory: inventory,
+		logger:    logger,
+	}
+}
+
+// ListStock returns the stock level of every product in the catalog
+func (h *InventoryHandler) ListStock(c echo.Context) error {
+	ctx := c.Request().Context()
+	page, err := h.products.List(ctx, repository.ProductQuery{})
+	if err != nil {
+		requestLogger(c, h.logger).Error("Failed to list products", "error", err)
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to list products",
+		})
+	}
+	levels, err := h.inventory.Levels(ctx)
+	if err != nil {
+		return stockLevelsError(c, h.logger, err)
+	}
+
+	stock := make([]models.StockLevel, 0, len(page.Products))
+	for _, product := range page.Products {
+		level, tracked := levels[product.ID]
+		stock = append(stock, models.StockLevel{
+			ProductID: product.ID,
+			Name:      product.Name,
+			Tracked:   tracked,
+			Stock:     level,
+		})
+	}
+	return c.JSON(http.StatusOK, stock)
+}
+
+// Restock adds units to a product's stock. Products are tracked from
+// their first restock on.
+func (h *InventoryHandler) Restock(c echo.Context) error {
+	productID := c.Param("productId")
+	if !utils.IsValidID(productID) {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid product ID format",
+		})
+	}
+
+	var req models.RestockRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid request body",
+		})
+	}
+	if req.Quantity > maxRestockQuantity {
+		return c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
+			Code:    422,
+			Type:    "error",
+			Message: fmt.Sprintf("quantity must be at most %d", maxRestockQuantity),
+		})
+	}
+
+	ctx := c.Request().Context()
+	product, err := h.products.Get(ctx, productID)
+	if err != nil {
+		return productError(c, h.logger, productID, err)
+	}
+
+	level, err := h.inventory.Restock(ctx, productID, req.Quantity)
+	if errors.Is(err, repository.ErrInvalidQuantity) {
+		return c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
+			Code:    422,
+			Type:    "error",
+			Message: err.Error(),
+		})
+	}
+	if err != nil {
+		requestLogger(c, h.logger).Error("Failed to restock product", "product_id", productID, "error", err)
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to restock product",
+		})
+	}
+
+	requestLogger(c, h.logger).Info("Product restocked", "product_id", productID, "quantity", req.Quantity, "stock", level)
+	return c.JSON(http.StatusOK, models.StockLevel{
+		ProductID: product.ID,
+		Name:      product.Name,
+		Tracked:   true,
+		Stock:     level,
+	})
+}
+
+// SetStock replaces a product's stock, e.g. to correct a count after a
+// stocktake or a mistyped restock. The product is tracked from then on.
+func (h *InventoryHandler) SetStock(c echo.Context) error {
+	productID := c.Param("productId")
+	if !utils.IsValidID(productID) {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid product ID format",
+		})
+	}
+
+	var req models.SetStockRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid request body",
+		})
+	}
+	if req.Stock == nil {
+		return c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
+			Code:    422,
+			Type:    "error",
+			Message: "stock is required",
+		})
+	}
+	if *req.Stock > maxRestockQuantity {
+		return c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
+			Code:    422,
+			Type:    "error",
+			Message: fmt.Sprintf("stock must be at most %d", maxRestockQuantity),
+		})
+	}
+
+	ctx := c.Request().Context()
+	product, err := h.products.Get(ctx, productID)
+	if err != nil {
+		return productError(c, h.logger, productID, err)
+	}
+
+	err = h.inventory.SetStock(ctx, productID, *req.Stock)
+	if errors.Is(err, repository.ErrInvalidStock) {
+		return c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
+			Code:    422,
+			Type:    "error",
+			Message: err.Error(),
+		})
+	}
+	if err != nil {
+		requestLogger(c, h.logger).Error("Failed to set stock", "product_id", productID, "error", err)
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to set stock",
+		})
+	}
+
+	requestLogger(c, h.logger).Info("Product stock set", "product_id", productID, "stock", *req.Stock)
+	return c.JSON(http.StatusOK, models.StockLevel{
+		ProductID: product.ID,
+		Name:      product.Name,
+		Tracked:   true,
+		Stock:     *req.Stock,
+	})
+}
+
+// Untrack stops tracking a product's stock, so it can be ordered without
+// limit again
+func (h *InventoryHandler) Untrack(c echo.Context) error {
+	productID := c.Param("productId")
+	if !utils.IsValidID(productID) {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid product ID format",
+		})
+	}
+
+	ctx := c.Request().Context()
+	product, err := h.products.Get(ctx, productID)
+	if err != nil {
+		return productError(c, h.logger, productID, err)
+	}
+
+	if err := h.inventory.Untrack(ctx, productID); err != nil {
+		requestLogger(c, h.logger).Error("Failed to untrack product", "product_id", productID, "error", err)
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to untrack product",
+		})
+	}
+
+	requestLogger(c, h.logger).Info("Product stock untracked", "product_id", productID)
+	return c.JSON(http.StatusOK, models.StockLevel{
+		ProductID: product.ID,
+		Name:      product.Name,
+	})
+}
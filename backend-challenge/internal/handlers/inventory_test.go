@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryHandler_Restock(t *testing.T) {
+	products := testProducts(t)
+	inventory := repository.NewMemoryInventoryStore()
+	handler := NewInventoryHandler(products, inventory, logging.Discard())
+	e := echo.New()
+	e.GET("/admin/inventory", handler.ListStock)
+	e.POST("/admin/inventory/:productId/restock", handler.Restock)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name           string
+		productID      string
+		body           string
+		expectedStatus int
+		expectedStock  int
+	}{
+		{"First restock starts tracking", "3", `{"quantity":5}`, http.StatusOK, 5},
+		{"Restock adds to the stock", "3", `{"quantity":7}`, http.StatusOK, 12},
+		{"Zero quantity", "3", `{"quantity":0}`, http.StatusUnprocessableEntity, 0},
+		{"Negative quantity", "3", `{"quantity":-4}`, http.StatusUnprocessableEntity, 0},
+		{"Huge quantity", "3", `{"quantity":1000000}`, http.StatusUnprocessableEntity, 0},
+		{"Quantity as string", "3", `{"quantity":"5"}`, http.StatusBadRequest, 0},
+		{"Unknown product", "99", `{"quantity":5}`, http.StatusNotFound, 0},
+		{"Invalid product ID", "abc", `{"quantity":5}`, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(http.MethodPost, "/admin/inventory/"+tt.productID+"/restock", tt.body)
+			assert.Equal(t, tt.expectedStatus, rec.Code, rec.Body.String())
+			if tt.expectedStatus == http.StatusOK {
+				var level models.StockLevel
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &level))
+				assert.Equal(t, models.StockLevel{ProductID: "3", Name: "Pancake Stack", Tracked: true, Stock: tt.expectedStock}, level)
+			}
+		})
+	}
+
+	rec := serve(http.MethodGet, "/admin/inventory", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var stock []models.StockLevel
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stock))
+	require.Len(t, stock, 8, "Every product is listed")
+	assert.Equal(t, models.StockLevel{ProductID: "1", Name: "Chicken Waffle"}, stock[0])
+	assert.Equal(t, models.StockLevel{ProductID: "3", Name: "Pancake Stack", Tracked: true, Stock: 12}, stock[2])
+}
+
+func TestInventoryHandler_SetStockAndUntrack(t *testing.T) {
+	ctx := context.Background()
+	inventory := repository.NewMemoryInventoryStore()
+	_, err := inventory.Restock(ctx, "3", 1000)
+	require.NoError(t, err)
+	handler := NewInventoryHandler(testProducts(t), inventory, logging.Discard())
+	e := echo.New()
+	e.PUT("/admin/inventory/:productId", handler.SetStock)
+	e.DELETE("/admin/inventory/:productId", handler.Untrack)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	levels := func() map[string]int {
+		levels, err := inventory.Levels(ctx)
+		require.NoError(t, err)
+		return levels
+	}
+
+	tests := []struct {
+		name           string
+		productID      string
+		body           string
+		expectedStatus int
+	}{
+		{"Over-restocked count is corrected", "3", `{"stock":10}`, http.StatusOK},
+		{"Zero stock", "3", `{"stock":0}`, http.StatusOK},
+		{"Missing stock", "3", `{}`, http.StatusUnprocessableEntity},
+		{"Negative stock", "3", `{"stock":-1}`, http.StatusUnprocessableEntity},
+		{"Huge stock", "3", `{"stock":1000000}`, http.StatusUnprocessableEntity},
+		{"Unknown product", "99", `{"stock":5}`, http.StatusNotFound},
+		{"Non-canonical product ID", "03", `{"stock":5}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(http.MethodPut, "/admin/inventory/"+tt.productID, tt.body)
+			assert.Equal(t, tt.expectedStatus, rec.Code, rec.Body.String())
+		})
+	}
+	assert.Equal(t, map[string]int{"3": 0}, levels(), "Rejected changes keep the last level")
+
+	rec := serve(http.MethodDelete, "/admin/inventory/3", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var level models.StockLevel
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &level))
+	assert.Equal(t, models.StockLevel{ProductID: "3", Name: "Pancake Stack"}, level)
+	assert.Empty(t, levels())
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/admin/inventory/99", "").Code)
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MediaPath is the URL path media files are served under
+const MediaPath = "/media"
+
+// MaxMediaUploadSize limits the size of an uploaded file
+const MaxMediaUploadSize = 5 << 20
+
+// Cache lifetimes of served media. Uploaded files are named by their
+// content hash, so they never change and can be cached for good.
+const (
+	mediaMaxAge          = 24 * 60 * 60
+	uploadedMediaMaxAge  = 365 * 24 * 60 * 60
+	uploadHashNameLength = 16
+)
+
+// mediaTypes maps accepted upload content types to file extensions
+var mediaTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// uploadedName matches the names UploadMedia gives files
+var uploadedName = regexp.MustCompile(fmt.Sprintf(`^[0-9a-f]{%d}\.(jpg|png|gif|webp)$`, uploadHashNameLength))
+
+// MediaHandler serves and stores product images in a directory
+type MediaHandler struct {
+	dir    string
+	logger *slog.Logger
+}
+
+// NewMediaHandler creates a media handler for dir
+func NewMediaHandler(dir string, logger *slog.Logger) *MediaHandler {
+	return &MediaHandler{
+		dir:    dir,
+		logger: logger,
+	}
+}
+
+// ServeMedia serves a file of the media directory with cache headers
+func (h *MediaHandler) ServeMedia(c echo.Context) error {
+	name := path.Clean("/" + c.Param("*"))
+	if strings.Contains(name, "/.") {
+		return mediaNotFound(c) // Hidden files, including unfinished uploads
+	}
+
+	// http.Dir refuses paths escaping the directory
+	file, err := http.Dir(h.dir).Open(name)
+	if err != nil {
+		return mediaNotFound(c)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		return mediaNotFound(c)
+	}
+
+	header := c.Response().Header()
+	if uploadedName.MatchString(info.Name()) {
+		header.Set(echo.HeaderCacheControl, fmt.Sprintf("public, max-age=%d, immutable", uploadedMediaMaxAge))
+		header.Set("ETag", `"`+strings.TrimSuffix(info.Name(), path.Ext(info.Name()))+`"`)
+	} else {
+		header.Set(echo.HeaderCacheControl, fmt.Sprintf("public, max-age=%d", mediaMaxAge))
+	}
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+
+	// ServeContent answers conditional and range requests
+	http.ServeContent(c.Response(), c.Request(), info.Name(), info.ModTime(), file)
+	return nil
+}
+
+// UploadMedia stores the image sent in the multipart field "file" and
+// returns the URL it is served at
+func (h *MediaHandler) UploadMedia(c echo.Context) error {
+	// Leave room for the multipart framing around the file
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxMediaUploadSize+64<<10)
+
+	header, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || (err == nil && header.Size > MaxMediaUploadSize) {
+		return c.JSON(http.StatusRequestEntityTooLarge, models.APIResponse{
+			Code:    413,
+			Type:    "error",
+			Message: fmt.Sprintf("File must be at most %d MB", MaxMediaUploadSize>>20),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Multipart field \"file\" is required",
+		})
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Failed to read uploaded file",
+		})
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, MaxMediaUploadSize+1))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Failed to read uploaded file",
+		})
+	}
+
+	// Trust the content, not the client's file name or content type
+	contentType := http.DetectContentType(data)
+	ext, ok := mediaTypes[contentType]
+	if !ok {
+		return c.JSON(http.StatusUnsupportedMediaType, models.APIResponse{
+			Code:    415,
+			Type:    "error",
+			Message: "Only JPEG, PNG, GIF and WebP images can be uploaded",
+		})
+	}
+
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:])[:uploadHashNameLength] + ext
+	if err := h.store(name, data); err != nil {
+		requestLogger(c, h.logger).Error("Failed to store upload", "name", name, "error", err)
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to store file",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, models.MediaUpload{
+		URL:         MediaPath + "/" + name,
+		Name:        name,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	})
+}
+
+// store writes a file into the media directory unless it is already there
+func (h *MediaHandler) store(name string, data []byte) error {
+	target := filepath.Join(h.dir, name)
+	if _, err := os.Stat(target); err == nil {
+		return nil // Same name, same content
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err := os.MkdirAll(h.dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(h.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// mediaNotFound responds to a missing media file
+func mediaNotFound(c echo.Context) error {
+	return c.JSON(http.StatusNotFound, models.APIResponse{
+		Code:    404,
+		Type:    "error",
+		Message: "File not found",
+	})
+}
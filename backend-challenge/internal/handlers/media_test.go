@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngHeader is enough of a PNG file for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// newMediaServer routes the media endpoints the way main does, without auth
+func newMediaServer(dir string) *echo.Echo {
+	handler := NewMediaHandler(dir, logging.Discard())
+	e := echo.New()
+	e.GET(MediaPath+"/*", handler.ServeMedia)
+	e.POST("/admin/media", handler.UploadMedia)
+	return e
+}
+
+// uploadRequest builds a multipart upload of content in the given field
+func uploadRequest(t *testing.T, field, filename string, content []byte) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile(field, filename)
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/media", &body)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	return req
+}
+
+func TestMediaHandler_UploadAndServe(t *testing.T) {
+	dir := t.TempDir()
+	e := newMediaServer(dir)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, uploadRequest(t, "file", "waffle.jpg", pngHeader))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var upload models.MediaUpload
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &upload))
+	assert.Equal(t, "image/png", upload.ContentType, "The content decides the type, not the file name")
+	assert.Regexp(t, `^/media/[0-9a-f]{16}\.png$`, upload.URL)
+	assert.Equal(t, int64(len(pngHeader)), upload.Size)
+
+	// Uploading the same content again returns the same file
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, uploadRequest(t, "file", "other.png", pngHeader))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var again models.MediaUpload
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &again))
+	assert.Equal(t, upload.URL, again.URL)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, upload.URL, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, pngHeader, rec.Body.Bytes())
+	assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "public, max-age=31536000, immutable", rec.Header().Get(echo.HeaderCacheControl))
+	assert.Equal(t, "nosniff", rec.Header().Get(echo.HeaderXContentTypeOptions))
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	// Revalidation is answered without a body
+	req := httptest.NewRequest(http.MethodGet, upload.URL, nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+}
+
+func TestMediaHandler_ServeMedia(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "menu"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "menu", "waffle.png"), pngHeader, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".upload-123"), pngHeader, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(dir), "secret.txt"), []byte("secret"), 0o644))
+	e := newMediaServer(dir)
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+	}{
+		{"File in a subdirectory", "/media/menu/waffle.png", http.StatusOK},
+		{"Missing file", "/media/menu/pancake.png", http.StatusNotFound},
+		{"Directory", "/media/menu", http.StatusNotFound},
+		{"Hidden file", "/media/.upload-123", http.StatusNotFound},
+		{"Path traversal", "/media/../secret.txt", http.StatusNotFound},
+		{"Encoded path traversal", "/media/..%2fsecret.txt", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "public, max-age=86400", rec.Header().Get(echo.HeaderCacheControl))
+			}
+		})
+	}
+}
+
+func TestMediaHandler_RejectsInvalidUploads(t *testing.T) {
+	e := newMediaServer(t.TempDir())
+
+	tests := []struct {
+		name           string
+		req            *http.Request
+		expectedStatus int
+	}{
+		{"Not an image", uploadRequest(t, "file", "menu.png", []byte("<html><script>alert(1)</script>")), http.StatusUnsupportedMediaType},
+		{"Wrong field", uploadRequest(t, "image", "waffle.png", pngHeader), http.StatusBadRequest},
+		{"Too large", uploadRequest(t, "file", "huge.png", append(pngHeader, make([]byte, MaxMediaUploadSize)...)), http.StatusRequestEntityTooLarge},
+		{"Not multipart", httptest.NewRequest(http.MethodPost, "/admin/media", bytes.NewReader(pngHeader)), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, tt.req)
+			assert.Equal(t, tt.expectedStatus, rec.Code, rec.Body.String())
+		})
+	}
+}
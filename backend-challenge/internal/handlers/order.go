@@ -1,39 +1,87 @@
 package handlers
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/metrics"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/pricing"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/services"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/tracing"
 	"github.com/ilyulev/kart-challenge/backend-api/pkg/utils"
 
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxCustomerIDLength bounds the customerId stored with orders and redemptions
+const maxCustomerIDLength = 64
+
+// maxItemQuantity bounds the quantity of an order item, far above any real
+// order and far below quantities that overflow the order total
+const maxItemQuantity = 10000
+
+// Reasons orders are rejected for in orders_rejected_total, besides
+// out_of_stock and the promo code reasons prefixed with promo_
+const (
+	rejectedInvalidRequest = "invalid_request"
+	rejectedError          = "error"
 )
 
 // OrderHandler handles order-related requests
 type OrderHandler struct {
-	promoService   *services.PromoCodeService
-	productHandler *ProductHandler
+	promoService *services.PromoCodeService
+	products     repository.ProductRepository
+	inventory    repository.InventoryStore
+	pricing      *pricing.Engine
+	orders       repository.OrderRepository
+	redemptions  *services.PromoRedemptions
+	logger       *slog.Logger
+
+	ordersPlaced   *metrics.Counter
+	ordersRejected *metrics.CounterVec
 }
 
-// NewOrderHandler creates a new order handler
-func NewOrderHandler(promoService *services.PromoCodeService) *OrderHandler {
+// NewOrderHandler creates a new order handler, registering the order
+// metrics in reg
+func NewOrderHandler(promoService *services.PromoCodeService, pricingEngine *pricing.Engine, products repository.ProductRepository,
+	inventory repository.InventoryStore, orders repository.OrderRepository, redemptions *services.PromoRedemptions,
+	logger *slog.Logger, reg *metrics.Registry) *OrderHandler {
 	return &OrderHandler{
-		promoService:   promoService,
-		productHandler: NewProductHandler(),
+		promoService: promoService,
+		products:     products,
+		inventory:    inventory,
+		pricing:      pricingEngine,
+		orders:       orders,
+		redemptions:  redemptions,
+		logger:       logger,
+
+		ordersPlaced:   reg.NewCounter("orders_placed_total", "Orders placed"),
+		ordersRejected: reg.NewCounterVec("orders_rejected_total", "Orders not placed, by reason", "reason"),
 	}
 }
 
-// PlaceOrder processes a new order
+// PlaceOrder processes a new order. Every step runs in its own span, so
+// traces show where an order spends its time.
 func (h *OrderHandler) PlaceOrder(c echo.Context) error {
+	ctx := c.Request().Context()
+
 	// Parse request body
 	var orderReq models.OrderRequest
 	if err := c.Bind(&orderReq); err != nil {
+		h.ordersRejected.With(rejectedInvalidRequest).Inc()
 		return c.JSON(http.StatusBadRequest, models.APIResponse{
 			Code:    400,
 			Type:    "error",
@@ -42,7 +90,11 @@ func (h *OrderHandler) PlaceOrder(c echo.Context) error {
 	}
 
 	// Validate request
-	if err := h.validateOrderRequest(&orderReq); err != nil {
+	_, span := tracing.Start(ctx, "order.validate_request", attribute.Int("order.items", len(orderReq.Items)))
+	err := h.validateOrderRequest(&orderReq)
+	tracing.End(span, err)
+	if err != nil {
+		h.ordersRejected.With(rejectedInvalidRequest).Inc()
 		return c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
 			Code:    422,
 			Type:    "error",
@@ -51,45 +103,286 @@ func (h *OrderHandler) PlaceOrder(c echo.Context) error {
 	}
 
 	// Validate and collect products
-	orderProducts, err := h.validateAndCollectProducts(orderReq.Items)
-	if err != nil {
+	spanCtx, span := tracing.Start(ctx, "order.load_products")
+	orderProducts, err := h.validateAndCollectProducts(spanCtx, orderReq.Items)
+	tracing.End(span, err)
+	var unknown unknownProductError
+	if errors.As(err, &unknown) {
+		h.ordersRejected.With(rejectedInvalidRequest).Inc()
 		return c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
 			Code:    422,
 			Type:    "error",
 			Message: err.Error(),
 		})
 	}
+	if err != nil {
+		requestLogger(c, h.logger).Error("Failed to load ordered products", "error", err)
+		h.ordersRejected.With(rejectedError).Inc()
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to load products",
+		})
+	}
+
+	// Key items by the catalog's product ID, so pricing, stock and the saved
+	// order all name a product the same way
+	for i := range orderReq.Items {
+		orderReq.Items[i].ProductID = orderProducts[i].ID
+	}
 
 	// Validate promo code if provided
 	if orderReq.CouponCode != "" {
-		if !h.promoService.IsValidPromoCode(orderReq.CouponCode) {
+		_, span := tracing.Start(ctx, "promo.validate")
+		valid := h.promoService.IsValidPromoCode(orderReq.CouponCode)
+		span.SetAttributes(attribute.Bool("promo.valid", valid))
+		span.End()
+		if !valid {
+			h.ordersRejected.With("promo_" + models.ReasonPromoInvalid).Inc()
 			return c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
 				Code:    422,
 				Type:    "error",
 				Message: "Invalid promo code",
+				Reason:  models.ReasonPromoInvalid,
 			})
 		}
 	}
 
 	// Generate order ID
 	orderID := h.generateOrderID()
+	logging.With(ctx, "order_id", orderID)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("order.id", orderID))
+
+	// Price the order, applying the discount rule mapped to the promo code
+	_, span = tracing.Start(ctx, "order.price")
+	quote, err := h.pricing.Price(pricingItems(orderReq.Items, orderProducts), orderReq.CouponCode)
+	tracing.End(span, err)
+	if err != nil {
+		h.ordersRejected.With(rejectedInvalidRequest).Inc()
+		return c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
+			Code:    422,
+			Type:    "error",
+			Message: err.Error(),
+		})
+	}
 
 	// Create order
 	order := models.Order{
-		ID:       orderID,
-		Items:    orderReq.Items,
-		Products: orderProducts,
+		ID:         orderID,
+		Items:      orderReq.Items,
+		Products:   orderProducts,
+		CouponCode: strings.ToUpper(orderReq.CouponCode),
+		CustomerID: orderReq.CustomerID,
+		Subtotal:   quote.Subtotal,
+		Discounts:  make([]models.DiscountLine, 0, len(quote.Discounts)),
+		Total:      quote.Total,
+		CreatedAt:  time.Now().UTC(),
+	}
+	for _, discount := range quote.Discounts {
+		order.Discounts = append(order.Discounts, models.DiscountLine{
+			Code:        discount.Code,
+			Rule:        discount.Rule,
+			Description: discount.Description,
+			Amount:      discount.Amount,
+		})
+	}
+
+	// Reserve stock of every item, or of none if any falls short
+	spanCtx, span = tracing.Start(ctx, "order.reserve_stock")
+	err = h.inventory.Reserve(spanCtx, order.Items)
+	tracing.End(span, err)
+	if err != nil {
+		return h.stockRejection(c, err)
+	}
+	for i := range order.Products {
+		order.Products[i].Available = true // In stock when ordered
+	}
+
+	// Redeem the promo code, checking its dates and usage caps
+	if order.CouponCode != "" {
+		spanCtx, span := tracing.Start(ctx, "order.redeem_promo")
+		err := h.redemptions.Redeem(spanCtx, order.CouponCode, order.CustomerID, order.ID)
+		tracing.End(span, err)
+		if err != nil {
+			h.releaseStock(c, order)
+			return h.promoRejection(c, order, err)
+		}
+	}
+
+	// Persist the order so it can be looked up later
+	spanCtx, span = tracing.Start(ctx, "order.save")
+	err = h.orders.Save(spanCtx, order)
+	tracing.End(span, err)
+	if err != nil {
+		logger := requestLogger(c, h.logger)
+		logger.Error("Failed to save order", "error", err)
+		h.ordersRejected.With(rejectedError).Inc()
+		// The order was not placed, so it must not hold stock or count against the caps
+		h.releaseStock(c, order)
+		if order.CouponCode != "" {
+			if err := h.redemptions.Cancel(ctx, order.CouponCode, order.ID); err != nil {
+				logger.Error("Failed to cancel redemption", "coupon_code", order.CouponCode, "error", err)
+			}
+		}
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to save order",
+		})
+	}
+
+	h.ordersPlaced.Inc()
+	requestLogger(c, h.logger).Info("Order placed",
+		"items", len(order.Items), "coupon_code", order.CouponCode, "total", order.Total)
+	return c.JSON(http.StatusOK, order)
+}
+
+// GetOrder returns a placed order by ID
+func (h *OrderHandler) GetOrder(c echo.Context) error {
+	orderID := c.Param("orderId")
+
+	// Validate order ID
+	if !utils.IsValidOrderID(orderID) {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid order ID format",
+		})
+	}
+
+	logging.With(c.Request().Context(), "order_id", orderID)
+
+	order, err := h.orders.Get(c.Request().Context(), orderID)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return c.JSON(http.StatusNotFound, models.APIResponse{
+			Code:    404,
+			Type:    "error",
+			Message: "Order not found",
+		})
+	}
+	if err != nil {
+		requestLogger(c, h.logger).Error("Failed to load order", "error", err)
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to load order",
+		})
 	}
 
-	// Return successful order
 	return c.JSON(http.StatusOK, order)
 }
 
+// ListOrders returns placed orders newest first, a page at a time
+func (h *OrderHandler) ListOrders(c echo.Context) error {
+	opts := repository.ListOptions{After: c.QueryParam("after")}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > repository.MaxPageSize {
+			return c.JSON(http.StatusBadRequest, models.APIResponse{
+				Code:    400,
+				Type:    "error",
+				Message: fmt.Sprintf("limit must be between 1 and %d", repository.MaxPageSize),
+			})
+		}
+		opts.Limit = n
+	}
+	if opts.After != "" && !utils.IsValidOrderID(opts.After) {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid after cursor",
+		})
+	}
+
+	page, err := h.orders.List(c.Request().Context(), opts)
+	if err != nil {
+		requestLogger(c, h.logger).Error("Failed to list orders", "error", err)
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to list orders",
+		})
+	}
+
+	return c.JSON(http.StatusOK, models.OrderList{
+		Orders:     page.Orders,
+		NextCursor: page.NextCursor,
+	})
+}
+
+// stockRejection responds to an order whose stock could not be reserved,
+// listing the items that are short
+func (h *OrderHandler) stockRejection(c echo.Context, err error) error {
+	var stockErr *repository.StockError
+	if !errors.As(err, &stockErr) {
+		requestLogger(c, h.logger).Error("Failed to reserve stock", "error", err)
+		h.ordersRejected.With(rejectedError).Inc()
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to reserve stock",
+		})
+	}
+
+	h.ordersRejected.With(models.ReasonOutOfStock).Inc()
+	return c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
+		Code:      422,
+		Type:      "error",
+		Message:   err.Error(),
+		Reason:    models.ReasonOutOfStock,
+		Shortages: stockErr.Shortages,
+	})
+}
+
+// releaseStock puts the stock reserved for an order that was not placed back
+func (h *OrderHandler) releaseStock(c echo.Context, order models.Order) {
+	if err := h.inventory.Release(c.Request().Context(), order.Items); err != nil {
+		requestLogger(c, h.logger).Error("Failed to release stock", "error", err)
+	}
+}
+
+// promoRejection responds to a promo code that could not be redeemed
+func (h *OrderHandler) promoRejection(c echo.Context, order models.Order, err error) error {
+	var reason string
+	switch {
+	case errors.Is(err, services.ErrPromoNotStarted):
+		reason = models.ReasonPromoNotStarted
+	case errors.Is(err, services.ErrPromoExpired):
+		reason = models.ReasonPromoExpired
+	case errors.Is(err, services.ErrPromoExhausted):
+		reason = models.ReasonPromoExhausted
+	case errors.Is(err, services.ErrPromoAlreadyUsed):
+		reason = models.ReasonPromoAlreadyUsed
+	case errors.Is(err, services.ErrCustomerRequired):
+		reason = models.ReasonCustomerRequired
+	default:
+		requestLogger(c, h.logger).Error("Failed to redeem promo code", "coupon_code", order.CouponCode, "error", err)
+		h.ordersRejected.With(rejectedError).Inc()
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to redeem promo code",
+		})
+	}
+
+	h.ordersRejected.With("promo_" + reason).Inc()
+	return c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
+		Code:    422,
+		Type:    "error",
+		Message: err.Error(),
+		Reason:  reason,
+	})
+}
+
 // validateOrderRequest validates the order request
 func (h *OrderHandler) validateOrderRequest(orderReq *models.OrderRequest) error {
 	if len(orderReq.Items) == 0 {
 		return fmt.Errorf("order must contain at least one item")
 	}
+	if len(orderReq.CustomerID) > maxCustomerIDLength {
+		return fmt.Errorf("customerId must be at most %d characters", maxCustomerIDLength)
+	}
 
 	for _, item := range orderReq.Items {
 		if item.ProductID == "" {
@@ -98,6 +391,9 @@ func (h *OrderHandler) validateOrderRequest(orderReq *models.OrderRequest) error
 		if item.Quantity <= 0 {
 			return fmt.Errorf("quantity must be greater than 0")
 		}
+		if item.Quantity > maxItemQuantity {
+			return fmt.Errorf("quantity must be at most %d", maxItemQuantity)
+		}
 		if !utils.IsValidID(item.ProductID) {
 			return fmt.Errorf("invalid productId format: %s", item.ProductID)
 		}
@@ -106,21 +402,45 @@ func (h *OrderHandler) validateOrderRequest(orderReq *models.OrderRequest) error
 	return nil
 }
 
+// unknownProductError is returned for ordered products not in the catalog
+type unknownProductError string
+
+func (id unknownProductError) Error() string {
+	return fmt.Sprintf("product with ID %s not found", string(id))
+}
+
 // validateAndCollectProducts validates items and collects corresponding products
-func (h *OrderHandler) validateAndCollectProducts(items []models.OrderItem) ([]models.Product, error) {
+func (h *OrderHandler) validateAndCollectProducts(ctx context.Context, items []models.OrderItem) ([]models.Product, error) {
 	var orderProducts []models.Product
 
 	for _, item := range items {
-		product, exists := h.productHandler.GetProductByID(item.ProductID)
-		if !exists {
-			return nil, fmt.Errorf("product with ID %s not found", item.ProductID)
+		product, err := h.products.Get(ctx, item.ProductID)
+		if errors.Is(err, repository.ErrProductNotFound) {
+			return nil, unknownProductError(item.ProductID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load product %s: %w", item.ProductID, err)
 		}
-		orderProducts = append(orderProducts, *product)
+		orderProducts = append(orderProducts, product)
 	}
 
 	return orderProducts, nil
 }
 
+// pricingItems pairs order items with their products for the pricing engine
+func pricingItems(items []models.OrderItem, products []models.Product) []pricing.Item {
+	priced := make([]pricing.Item, len(items))
+	for i, item := range items {
+		priced[i] = pricing.Item{
+			ProductID: item.ProductID,
+			Name:      products[i].Name,
+			UnitPrice: products[i].Price,
+			Quantity:  item.Quantity,
+		}
+	}
+	return priced
+}
+
 // generateOrderID creates unique IDs without requiring mutex
 func (h *OrderHandler) generateOrderID() string {
 	// Option 1: Timestamp + Random (recommended for this use case)
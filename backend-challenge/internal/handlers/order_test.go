@@ -2,14 +2,24 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/ilyulev/kart-challenge/backend-api/internal/auth"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/metrics"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/middleware"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/pricing"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/services"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/tracing"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -21,7 +31,8 @@ func TestOrderHandler_PlaceOrder(t *testing.T) {
 	promoService := services.NewPromoCodeService()
 	promoService.LoadMockPromoCodes()
 
-	handler := NewOrderHandler(promoService)
+	handler := NewOrderHandler(promoService, pricing.NewEngine(), testProducts(t), repository.NewMemoryInventoryStore(),
+		repository.NewMemoryOrderRepository(), testRedemptions(t), logging.Discard(), metrics.NewRegistry())
 
 	tests := []struct {
 		name           string
@@ -107,6 +118,28 @@ func TestOrderHandler_PlaceOrder(t *testing.T) {
 			expectedStatus: http.StatusUnprocessableEntity,
 			shouldHaveID:   false,
 		},
+		{
+			name: "Quantity above the limit",
+			requestBody: models.OrderRequest{
+				Items: []models.OrderItem{
+					{ProductID: "1", Quantity: 10001},
+				},
+			},
+			apiKey:         "apitest",
+			expectedStatus: http.StatusUnprocessableEntity,
+			shouldHaveID:   false,
+		},
+		{
+			name: "Quantity overflowing the total",
+			requestBody: models.OrderRequest{
+				Items: []models.OrderItem{
+					{ProductID: "1", Quantity: 2000000000000000000},
+				},
+			},
+			apiKey:         "apitest",
+			expectedStatus: http.StatusUnprocessableEntity,
+			shouldHaveID:   false,
+		},
 		{
 			name: "Invalid promo code",
 			requestBody: models.OrderRequest{
@@ -137,7 +170,7 @@ func TestOrderHandler_PlaceOrder(t *testing.T) {
 			c := e.NewContext(req, rec)
 
 			// Execute
-			handlerChain := middleware.APIKeyAuth()(handler.PlaceOrder)
+			handlerChain := middleware.APIKeyAuth(testKeys(t), auth.ScopeCreateOrder)(handler.PlaceOrder)
 			err = handlerChain(c) // ✅ Middleware runs first!
 
 			// Assert
@@ -155,9 +188,138 @@ func TestOrderHandler_PlaceOrder(t *testing.T) {
 	}
 }
 
+func TestOrderHandler_PlaceOrderAppliesDiscounts(t *testing.T) {
+	promoService := services.NewPromoCodeService()
+	promoService.LoadMockPromoCodes()
+	handler := NewOrderHandler(promoService, pricing.NewEngine(), testProducts(t), repository.NewMemoryInventoryStore(),
+		repository.NewMemoryOrderRepository(), testRedemptions(t), logging.Discard(), metrics.NewRegistry())
+
+	tests := []struct {
+		name      string
+		coupon    string
+		discounts int
+		subtotal  string
+		total     string
+	}{
+		{"No coupon", "", 0, "45.96", "45.96"},
+		{"Valid code without rule", "HAPPYHRS", 0, "45.96", "45.96"},
+		{"HAPPYHOURS 18% off", "HAPPYHOURS", 1, "45.96", "37.69"},
+		{"BUYGETONE cheapest free", "buygetone", 1, "45.96", "36.97"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(models.OrderRequest{
+				CouponCode: tt.coupon,
+				Items: []models.OrderItem{
+					{ProductID: "1", Quantity: 2}, // 12.99
+					{ProductID: "2", Quantity: 1}, // 10.99
+					{ProductID: "3", Quantity: 1}, // 8.99
+				},
+			})
+			require.NoError(t, err)
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/api/order", bytes.NewBuffer(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			require.NoError(t, handler.PlaceOrder(e.NewContext(req, rec)))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var order models.Order
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &order))
+			assert.Equal(t, tt.subtotal, order.Subtotal.Decimal())
+			assert.Equal(t, tt.total, order.Total.Decimal())
+			assert.Len(t, order.Discounts, tt.discounts)
+			assert.Contains(t, rec.Body.String(), `"discounts":[`, "Discounts should be an array, never null")
+		})
+	}
+}
+
+func TestOrderHandler_GetAndListOrders(t *testing.T) {
+	promoService := services.NewPromoCodeService()
+	handler := NewOrderHandler(promoService, pricing.NewEngine(), testProducts(t), repository.NewMemoryInventoryStore(),
+		repository.NewMemoryOrderRepository(), testRedemptions(t), logging.Discard(), metrics.NewRegistry())
+	e := echo.New()
+	e.POST("/api/order", handler.PlaceOrder)
+	e.GET("/api/order", handler.ListOrders, middleware.APIKeyAuth(testKeys(t), auth.ScopeReadOrders))
+	e.GET("/api/order/:orderId", handler.GetOrder, middleware.APIKeyAuth(testKeys(t), auth.ScopeReadOrders))
+
+	serve := func(method, target, apiKey string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if apiKey != "" {
+			req.Header.Set("api_key", apiKey)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var placed []models.Order
+	for i := 0; i < 3; i++ {
+		body, _ := json.Marshal(models.OrderRequest{Items: []models.OrderItem{{ProductID: "1", Quantity: i + 1}}})
+		rec := serve(http.MethodPost, "/api/order", "", body)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var order models.Order
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &order))
+		placed = append(placed, order)
+	}
+
+	t.Run("Get placed order", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/api/order/"+placed[1].ID, "apitest", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var order models.Order
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &order))
+		assert.Equal(t, placed[1].ID, order.ID)
+		assert.Equal(t, placed[1].Total, order.Total)
+	})
+
+	t.Run("Lookup errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/api/order/ORD-000000-000000-00000000", "apitest", nil).Code)
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/api/order/1", "apitest", nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/api/order/"+placed[0].ID, "", nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/api/order", "wrongkey", nil).Code)
+	})
+
+	t.Run("List pages", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/api/order?limit=2", "apitest", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var first models.OrderList
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &first))
+		assert.Len(t, first.Orders, 2)
+		require.NotEmpty(t, first.NextCursor)
+
+		rec = serve(http.MethodGet, "/api/order?limit=2&after="+first.NextCursor, "apitest", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var second models.OrderList
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &second))
+		assert.Len(t, second.Orders, 1)
+		assert.Empty(t, second.NextCursor)
+
+		seen := map[string]bool{}
+		for _, order := range append(first.Orders, second.Orders...) {
+			seen[order.ID] = true
+		}
+		assert.Len(t, seen, 3, "Every order is listed once")
+	})
+
+	t.Run("Invalid list parameters", func(t *testing.T) {
+		for _, query := range []string{"limit=0", "limit=abc", "limit=101", "after=bad"} {
+			assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/api/order?"+query, "apitest", nil).Code, query)
+		}
+	})
+}
+
 func TestOrderHandler_generateOrderID(t *testing.T) {
 	promoService := services.NewPromoCodeService()
-	handler := NewOrderHandler(promoService)
+	handler := NewOrderHandler(promoService, pricing.NewEngine(), testProducts(t), repository.NewMemoryInventoryStore(),
+		repository.NewMemoryOrderRepository(), testRedemptions(t), logging.Discard(), metrics.NewRegistry())
 
 	// Generate multiple IDs to test uniqueness
 	ids := make(map[string]bool)
@@ -172,3 +334,288 @@ func TestOrderHandler_generateOrderID(t *testing.T) {
 		ids[id] = true
 	}
 }
+
+// testKeys returns a key set holding the "apitest" key with every scope
+func testKeys(t *testing.T) *auth.KeySet {
+	keys, err := auth.NewKeySet([]auth.APIKey{{Name: "test", Key: "apitest", Scopes: []string{auth.ScopeAll}}})
+	require.NoError(t, err)
+	return keys
+}
+
+func TestOrderHandler_PlaceOrderRedemptionLimits(t *testing.T) {
+	promoService := services.NewPromoCodeService()
+	promoService.LoadMockPromoCodes()
+
+	now := time.Now()
+	redemptions := testRedemptions(t,
+		services.PromoTerms{Code: "HAPPYHRS", EndsAt: now.Add(-time.Hour)},
+		services.PromoTerms{Code: "FIFTYOFF", StartsAt: now.Add(time.Hour)},
+		services.PromoTerms{Code: "WELCOME1", MaxRedemptions: 1},
+		services.PromoTerms{Code: "NEWUSER2", MaxPerCustomer: 1},
+	)
+	handler := NewOrderHandler(promoService, pricing.NewEngine(), testProducts(t), repository.NewMemoryInventoryStore(),
+		repository.NewMemoryOrderRepository(), redemptions, logging.Discard(), metrics.NewRegistry())
+
+	place := func(coupon, customerID string) (int, models.APIResponse) {
+		body, _ := json.Marshal(models.OrderRequest{
+			CouponCode: coupon,
+			CustomerID: customerID,
+			Items:      []models.OrderItem{{ProductID: "1", Quantity: 1}},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/order", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.PlaceOrder(echo.New().NewContext(req, rec)))
+
+		var resp models.APIResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	tests := []struct {
+		name           string
+		coupon         string
+		customerID     string
+		expectedStatus int
+		expectedReason string
+	}{
+		{"Unknown code", "NOTACODE", "", http.StatusUnprocessableEntity, models.ReasonPromoInvalid},
+		{"Expired code", "HAPPYHRS", "", http.StatusUnprocessableEntity, models.ReasonPromoExpired},
+		{"Code not started", "FIFTYOFF", "", http.StatusUnprocessableEntity, models.ReasonPromoNotStarted},
+		{"Capped code first use", "WELCOME1", "", http.StatusOK, ""},
+		{"Capped code exhausted", "WELCOME1", "", http.StatusUnprocessableEntity, models.ReasonPromoExhausted},
+		{"Per customer code without customer", "NEWUSER2", "", http.StatusUnprocessableEntity, models.ReasonCustomerRequired},
+		{"Per customer code first use", "NEWUSER2", "alice", http.StatusOK, ""},
+		{"Per customer code used again", "NEWUSER2", "alice", http.StatusUnprocessableEntity, models.ReasonPromoAlreadyUsed},
+		{"Per customer code other customer", "NEWUSER2", "bob", http.StatusOK, ""},
+		{"Code without terms", "DISCOUNT", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := place(tt.coupon, tt.customerID)
+			assert.Equal(t, tt.expectedStatus, status)
+			assert.Equal(t, tt.expectedReason, resp.Reason)
+		})
+	}
+}
+
+func TestOrderHandler_PlaceOrderConcurrentRedemptions(t *testing.T) {
+	promoService := services.NewPromoCodeService()
+	promoService.LoadMockPromoCodes()
+	orders := repository.NewMemoryOrderRepository()
+	redemptions := testRedemptions(t, services.PromoTerms{Code: "WELCOME1", MaxRedemptions: 5})
+	handler := NewOrderHandler(promoService, pricing.NewEngine(), testProducts(t), repository.NewMemoryInventoryStore(),
+		orders, redemptions, logging.Discard(), metrics.NewRegistry())
+
+	const attempts = 40
+	var wg sync.WaitGroup
+	statuses := make([]int, attempts)
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/api/order",
+				bytes.NewBufferString(`{"couponCode":"WELCOME1","items":[{"productId":"1","quantity":1}]}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			assert.NoError(t, handler.PlaceOrder(echo.New().NewContext(req, rec)))
+			statuses[i] = rec.Code
+		}()
+	}
+	wg.Wait()
+
+	placed := 0
+	for _, status := range statuses {
+		if status == http.StatusOK {
+			placed++
+		} else {
+			assert.Equal(t, http.StatusUnprocessableEntity, status)
+		}
+	}
+	assert.Equal(t, 5, placed, "Exactly the capped number of orders get the code")
+
+	page, err := orders.List(context.Background(), repository.ListOptions{Limit: repository.MaxPageSize})
+	require.NoError(t, err)
+	assert.Len(t, page.Orders, 5)
+}
+
+func TestOrderHandler_PlaceOrderStock(t *testing.T) {
+	promoService := services.NewPromoCodeService()
+	promoService.LoadMockPromoCodes()
+	ctx := context.Background()
+	inventory := repository.NewMemoryInventoryStore()
+	_, err := inventory.Restock(ctx, "1", 3)
+	require.NoError(t, err)
+	_, err = inventory.Restock(ctx, "2", 1)
+	require.NoError(t, err)
+	handler := NewOrderHandler(promoService, pricing.NewEngine(), testProducts(t), inventory,
+		repository.NewMemoryOrderRepository(), testRedemptions(t, services.PromoTerms{Code: "NEWUSER2", MaxPerCustomer: 1}), logging.Discard(), metrics.NewRegistry())
+
+	place := func(body string) (*httptest.ResponseRecorder, models.APIResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/api/order", bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.PlaceOrder(echo.New().NewContext(req, rec)))
+
+		var resp models.APIResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+	levels := func() map[string]int {
+		levels, err := inventory.Levels(ctx)
+		require.NoError(t, err)
+		return levels
+	}
+
+	// Every short item is named and nothing is reserved
+	rec, resp := place(`{"items":[{"productId":"2","quantity":2},{"productId":"1","quantity":2},{"productId":"3","quantity":50},{"productId":"1","quantity":2}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, models.ReasonOutOfStock, resp.Reason)
+	assert.Equal(t, []models.StockShortage{
+		{ProductID: "1", Requested: 4, Available: 3},
+		{ProductID: "2", Requested: 2, Available: 1},
+	}, resp.Shortages)
+	assert.Equal(t, map[string]int{"1": 3, "2": 1}, levels())
+
+	// Orders take stock; untracked products are unlimited
+	rec, _ = place(`{"items":[{"productId":"1","quantity":2},{"productId":"2","quantity":1},{"productId":"3","quantity":50}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var order models.Order
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &order))
+	assert.True(t, order.Products[0].Available)
+	assert.Equal(t, map[string]int{"1": 1, "2": 0}, levels())
+
+	// Orders rejected for their promo code give the stock back
+	rec, resp = place(`{"couponCode":"NEWUSER2","items":[{"productId":"1","quantity":1}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, models.ReasonCustomerRequired, resp.Reason)
+	assert.Equal(t, map[string]int{"1": 1, "2": 0}, levels())
+}
+
+func TestOrderHandler_PlaceOrderNonCanonicalProductIDs(t *testing.T) {
+	promoService := services.NewPromoCodeService()
+	promoService.LoadMockPromoCodes()
+	ctx := context.Background()
+	inventory := repository.NewMemoryInventoryStore()
+	_, err := inventory.Restock(ctx, "8", 1)
+	require.NoError(t, err)
+	handler := NewOrderHandler(promoService, pricing.NewEngine(), testProducts(t), inventory,
+		repository.NewMemoryOrderRepository(), testRedemptions(t), logging.Discard(), metrics.NewRegistry())
+
+	place := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/order", bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.PlaceOrder(echo.New().NewContext(req, rec)))
+		return rec
+	}
+
+	// Other spellings of product 8 would bypass its stock
+	for _, body := range []string{
+		`{"items":[{"productId":"08","quantity":100}]}`,
+		`{"items":[{"productId":"+8","quantity":5}]}`,
+		`{"items":[{"productId":"8","quantity":1},{"productId":"08","quantity":1}]}`,
+	} {
+		assert.Equal(t, http.StatusUnprocessableEntity, place(body).Code, body)
+	}
+	levels, err := inventory.Levels(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"8": 1}, levels)
+
+	rec := place(`{"items":[{"productId":"8","quantity":2}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "Stock still applies")
+	assert.Equal(t, http.StatusOK, place(`{"items":[{"productId":"8","quantity":1}]}`).Code)
+}
+
+func TestOrderHandler_PlaceOrderSpans(t *testing.T) {
+	promoService := services.NewPromoCodeService()
+	promoService.LoadMockPromoCodes()
+	handler := NewOrderHandler(promoService, pricing.NewEngine(), testProducts(t), repository.NewMemoryInventoryStore(),
+		repository.NewMemoryOrderRepository(), testRedemptions(t), logging.Discard(), metrics.NewRegistry())
+	provider, exporter := tracing.NewInMemory()
+
+	e := echo.New()
+	e.Use(middleware.Tracing(provider))
+	e.POST("/api/order", handler.PlaceOrder)
+
+	place := func(body string) []string {
+		exporter.Reset()
+		req := httptest.NewRequest(http.MethodPost, "/api/order", bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := exporter.GetSpans()
+		require.NotEmpty(t, spans)
+		server := spans[len(spans)-1]
+		require.Equal(t, "POST /api/order", server.Name)
+
+		var names []string
+		for _, span := range spans[:len(spans)-1] {
+			assert.Equal(t, server.SpanContext.SpanID(), span.Parent.SpanID(), span.Name)
+			names = append(names, span.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{
+		"order.validate_request", "order.load_products", "promo.validate",
+		"order.price", "order.reserve_stock", "order.redeem_promo", "order.save",
+	}, place(`{"couponCode":"HAPPYHRS","items":[{"productId":"1","quantity":2}]}`))
+
+	assert.Equal(t, []string{"order.validate_request", "order.load_products", "promo.validate"},
+		place(`{"couponCode":"NOTVALID1","items":[{"productId":"1","quantity":1}]}`), "Rejected orders stop early")
+}
+
+// TestOrderHandler_PlaceOrderConcurrentStock races orders for the last units
+// of a product; run with -race to check the reservation path for data races
+func TestOrderHandler_PlaceOrderConcurrentStock(t *testing.T) {
+	promoService := services.NewPromoCodeService()
+	promoService.LoadMockPromoCodes()
+	orders := repository.NewMemoryOrderRepository()
+	inventory := repository.NewMemoryInventoryStore()
+	_, err := inventory.Restock(context.Background(), "8", 10)
+	require.NoError(t, err)
+	handler := NewOrderHandler(promoService, pricing.NewEngine(), testProducts(t), inventory, orders, testRedemptions(t), logging.Discard(), metrics.NewRegistry())
+
+	const attempts = 60
+	var wg sync.WaitGroup
+	statuses := make([]int, attempts)
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Orders of one or two units, so the last unit is fought over too
+			body := fmt.Sprintf(`{"items":[{"productId":"8","quantity":%d},{"productId":"1","quantity":1}]}`, 1+i%2)
+			req := httptest.NewRequest(http.MethodPost, "/api/order", bytes.NewBufferString(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			assert.NoError(t, handler.PlaceOrder(echo.New().NewContext(req, rec)))
+			statuses[i] = rec.Code
+		}()
+	}
+	wg.Wait()
+
+	for _, status := range statuses {
+		assert.Contains(t, []int{http.StatusOK, http.StatusUnprocessableEntity}, status)
+	}
+
+	// The units sold add up to exactly the stock, never more
+	page, err := orders.List(context.Background(), repository.ListOptions{Limit: repository.MaxPageSize})
+	require.NoError(t, err)
+	sold := 0
+	for _, order := range page.Orders {
+		sold += order.Items[0].Quantity
+	}
+	assert.Equal(t, 10, sold)
+	levels, err := inventory.Levels(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, levels["8"])
+}
+
+// testRedemptions returns a redemption service with terms over an in-memory store
+func testRedemptions(t *testing.T, terms ...services.PromoTerms) *services.PromoRedemptions {
+	redemptions, err := services.NewPromoRedemptions(terms, repository.NewMemoryRedemptionStore())
+	require.NoError(t, err)
+	return redemptions
+}
@@ -1,9 +1,19 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
 	"github.com/ilyulev/kart-challenge/backend-api/pkg/utils"
 
 	"github.com/labstack/echo/v4"
@@ -11,31 +21,177 @@ import (
 
 // ProductHandler handles product-related requests
 type ProductHandler struct {
-	products []models.Product
+	products  repository.ProductRepository
+	inventory repository.InventoryStore
+	logger    *slog.Logger
 }
 
 // NewProductHandler creates a new product handler
-func NewProductHandler() *ProductHandler {
-	// In production, this would come from a database
-	products := []models.Product{
-		{ID: "1", Name: "Chicken Waffle", Price: 12.99, Category: "Waffle"},
-		{ID: "2", Name: "Belgian Waffle", Price: 10.99, Category: "Waffle"},
-		{ID: "3", Name: "Pancake Stack", Price: 8.99, Category: "Pancake"},
-		{ID: "4", Name: "Avocado Toast", Price: 9.99, Category: "Toast"},
-		{ID: "5", Name: "Caesar Salad", Price: 11.99, Category: "Salad"},
-		{ID: "6", Name: "Burger Deluxe", Price: 14.99, Category: "Burger"},
-		{ID: "7", Name: "Fish & Chips", Price: 13.99, Category: "Main"},
-		{ID: "8", Name: "Chocolate Cake", Price: 6.99, Category: "Dessert"},
-	}
-
+func NewProductHandler(products repository.ProductRepository, inventory repository.InventoryStore, logger *slog.Logger) *ProductHandler {
 	return &ProductHandler{
-		products: products,
+		products:  products,
+		inventory: inventory,
+		logger:    logger,
 	}
 }
 
-// ListProducts returns all available products
+// maxSearchLength bounds the q parameter of product listings
+const maxSearchLength = 100
+
+// ListProducts returns the products matching the query parameters, a page
+// at a time. The next page is linked in the Link header.
 func (h *ProductHandler) ListProducts(c echo.Context) error {
-	return c.JSON(http.StatusOK, h.products)
+	query, err := parseProductQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: err.Error(),
+		})
+	}
+	return listProductPage(c, h.logger, h.products, h.inventory, query)
+}
+
+// listProductPage responds with a page of products, linking the next page
+// in the Link header
+func listProductPage(c echo.Context, logger *slog.Logger, products repository.ProductRepository, inventory repository.InventoryStore,
+	query repository.ProductQuery) error {
+	page, err := products.List(c.Request().Context(), query)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid after cursor",
+		})
+	}
+	if err != nil {
+		requestLogger(c, logger).Error("Failed to list products", "error", err)
+		return c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Code:    500,
+			Type:    "error",
+			Message: "Failed to list products",
+		})
+	}
+
+	if err := markAvailable(c.Request().Context(), inventory, page.Products); err != nil {
+		return stockLevelsError(c, logger, err)
+	}
+	if links := pageLinks(c.Request().URL, query.After, page.NextCursor); links != "" {
+		c.Response().Header().Set("Link", links)
+	}
+	return c.JSON(http.StatusOK, page.Products)
+}
+
+// markAvailable sets the available flag of products from their stock
+func markAvailable(ctx context.Context, inventory repository.InventoryStore, products []models.Product) error {
+	levels, err := inventory.Levels(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range products {
+		level, tracked := levels[products[i].ID]
+		products[i].Available = !tracked || level > 0
+	}
+	return nil
+}
+
+// requestLogger returns the logger of the request, see
+// middleware.RequestLogger, or logger if it has none
+func requestLogger(c echo.Context, logger *slog.Logger) *slog.Logger {
+	return logging.FromContext(c.Request().Context(), logger)
+}
+
+// stockLevelsError responds to stock levels that could not be read
+func stockLevelsError(c echo.Context, logger *slog.Logger, err error) error {
+	requestLogger(c, logger).Error("Failed to load stock levels", "error", err)
+	return c.JSON(http.StatusInternalServerError, models.APIResponse{
+		Code:    500,
+		Type:    "error",
+		Message: "Failed to load stock levels",
+	})
+}
+
+// respondProduct responds with a product, flagged available if in stock
+func (h *ProductHandler) respondProduct(c echo.Context, status int, product models.Product) error {
+	products := []models.Product{product}
+	if err := markAvailable(c.Request().Context(), h.inventory, products); err != nil {
+		return stockLevelsError(c, h.logger, err)
+	}
+	return c.JSON(status, products[0])
+}
+
+// parseProductQuery reads the filter, sort and paging parameters of a listing
+func parseProductQuery(c echo.Context) (repository.ProductQuery, error) {
+	query := repository.ProductQuery{
+		Category: strings.TrimSpace(c.QueryParam("category")),
+		Search:   strings.TrimSpace(c.QueryParam("q")),
+		Sort:     c.QueryParam("sort"),
+		Limit:    repository.MaxPageSize,
+		After:    c.QueryParam("after"),
+	}
+
+	if len(query.Search) > maxSearchLength {
+		return query, fmt.Errorf("q must be at most %d characters", maxSearchLength)
+	}
+	if !repository.IsValidSort(query.Sort) {
+		return query, fmt.Errorf("sort must be one of price, -price or name")
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > repository.MaxPageSize {
+			return query, fmt.Errorf("limit must be between 1 and %d", repository.MaxPageSize)
+		}
+		query.Limit = n
+	}
+
+	for _, bound := range []struct {
+		param  string
+		target **money.Money
+	}{
+		{"minPrice", &query.MinPrice},
+		{"maxPrice", &query.MaxPrice},
+	} {
+		value := c.QueryParam(bound.param)
+		if value == "" {
+			continue
+		}
+		price, err := money.Parse(value, money.DefaultCurrency)
+		if err != nil || price.IsNegative() {
+			return query, fmt.Errorf("%s must be a non-negative amount such as 12.50", bound.param)
+		}
+		*bound.target = &price
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil {
+		if order, _ := query.MinPrice.Cmp(*query.MaxPrice); order > 0 {
+			return query, fmt.Errorf("minPrice must not be above maxPrice")
+		}
+	}
+
+	return query, nil
+}
+
+// pageLinks builds a Link header pointing at the first and next pages of a
+// listing, keeping its other query parameters
+func pageLinks(current *url.URL, after, next string) string {
+	link := func(cursor, rel string) string {
+		u := *current
+		params := u.Query()
+		params.Del("after")
+		if cursor != "" {
+			params.Set("after", cursor)
+		}
+		u.RawQuery = params.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+	}
+
+	var links []string
+	if next != "" {
+		links = append(links, link(next, "next"))
+	}
+	if after != "" {
+		links = append(links, link("", "first"))
+	}
+	return strings.Join(links, ", ")
 }
 
 // GetProduct returns a specific product by ID
@@ -51,27 +207,191 @@ func (h *ProductHandler) GetProduct(c echo.Context) error {
 		})
 	}
 
-	// Find product
-	for _, product := range h.products {
-		if product.ID == productID {
-			return c.JSON(http.StatusOK, product)
-		}
+	product, err := h.products.Get(c.Request().Context(), productID)
+	if err != nil {
+		return productError(c, h.logger, productID, err)
+	}
+	return h.respondProduct(c, http.StatusOK, product)
+}
+
+// CreateProduct adds a product to the catalog. Without an ID the next free
+// one is assigned.
+func (h *ProductHandler) CreateProduct(c echo.Context) error {
+	var product models.Product
+	if err := c.Bind(&product); err != nil {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid request body",
+		})
+	}
+
+	created, err := h.products.Create(c.Request().Context(), product)
+	if err != nil {
+		return productError(c, h.logger, product.ID, err)
+	}
+	return h.respondProduct(c, http.StatusCreated, created)
+}
+
+// UpdateProduct replaces a product. The body names the version it is based on.
+func (h *ProductHandler) UpdateProduct(c echo.Context) error {
+	productID := c.Param("productId")
+	if !utils.IsValidID(productID) {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid product ID format",
+		})
+	}
+
+	var product models.Product
+	if err := c.Bind(&product); err != nil {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid request body",
+		})
+	}
+	if product.ID != "" && product.ID != productID {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Product ID in the body does not match the URL",
+		})
+	}
+	if product.Version < 1 {
+		return versionRequired(c)
+	}
+	product.ID = productID
+
+	updated, err := h.products.Update(c.Request().Context(), product)
+	if err != nil {
+		return productError(c, h.logger, productID, err)
+	}
+	return h.respondProduct(c, http.StatusOK, updated)
+}
+
+// PatchProduct changes the given fields of a product. The body names the
+// version it is based on.
+func (h *ProductHandler) PatchProduct(c echo.Context) error {
+	productID := c.Param("productId")
+	if !utils.IsValidID(productID) {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid product ID format",
+		})
+	}
+
+	var patch models.ProductPatch
+	if err := c.Bind(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid request body",
+		})
+	}
+	if patch.Version < 1 {
+		return versionRequired(c)
+	}
+
+	ctx := c.Request().Context()
+	product, err := h.products.Get(ctx, productID)
+	if err != nil {
+		return productError(c, h.logger, productID, err)
+	}
+
+	// Update fails if the product changed after it was read, so the patch
+	// is never applied over someone else's change
+	product.Version = patch.Version
+	if patch.Name != nil {
+		product.Name = *patch.Name
+	}
+	if patch.Price != nil {
+		product.Price = *patch.Price
+	}
+	if patch.Category != nil {
+		product.Category = *patch.Category
+	}
+	if patch.Description != nil {
+		product.Description = *patch.Description
+	}
+	if patch.Image != nil {
+		product.Image = patch.Image
+	}
+
+	updated, err := h.products.Update(ctx, product)
+	if err != nil {
+		return productError(c, h.logger, productID, err)
+	}
+	return h.respondProduct(c, http.StatusOK, updated)
+}
+
+// DeleteProduct removes a product. The version it is based on is passed as
+// the version query parameter.
+func (h *ProductHandler) DeleteProduct(c echo.Context) error {
+	productID := c.Param("productId")
+	if !utils.IsValidID(productID) {
+		return c.JSON(http.StatusBadRequest, models.APIResponse{
+			Code:    400,
+			Type:    "error",
+			Message: "Invalid product ID format",
+		})
+	}
+
+	version, err := strconv.Atoi(c.QueryParam("version"))
+	if err != nil || version < 1 {
+		return versionRequired(c)
 	}
 
-	// Product not found
-	return c.JSON(http.StatusNotFound, models.APIResponse{
-		Code:    404,
+	if err := h.products.Delete(c.Request().Context(), productID, version); err != nil {
+		return productError(c, h.logger, productID, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// versionRequired rejects a change that does not name the version it is based on
+func versionRequired(c echo.Context) error {
+	return c.JSON(http.StatusBadRequest, models.APIResponse{
+		Code:    400,
 		Type:    "error",
-		Message: "Product not found",
+		Message: "version of the product being changed is required",
 	})
 }
 
-// GetProductByID helper method for internal use
-func (h *ProductHandler) GetProductByID(id string) (*models.Product, bool) {
-	for _, product := range h.products {
-		if product.ID == id {
-			return &product, true
-		}
+// productError responds to a failed product repository call
+func productError(c echo.Context, logger *slog.Logger, productID string, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrProductNotFound):
+		return c.JSON(http.StatusNotFound, models.APIResponse{
+			Code:    404,
+			Type:    "error",
+			Message: "Product not found",
+		})
+	case errors.Is(err, repository.ErrProductExists):
+		return c.JSON(http.StatusConflict, models.APIResponse{
+			Code:    409,
+			Type:    "error",
+			Message: fmt.Sprintf("Product %s already exists", productID),
+		})
+	case errors.Is(err, repository.ErrVersionConflict):
+		return c.JSON(http.StatusConflict, models.APIResponse{
+			Code:    409,
+			Type:    "error",
+			Message: "Product was changed by someone else, reload it and retry",
+		})
+	case errors.Is(err, repository.ErrInvalidProduct):
+		return c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
+			Code:    422,
+			Type:    "error",
+			Message: err.Error(),
+		})
 	}
-	return nil, false
+
+	requestLogger(c, logger).Error("Failed to access product", "product_id", productID, "error", err)
+	return c.JSON(http.StatusInternalServerError, models.APIResponse{
+		Code:    500,
+		Type:    "error",
+		Message: "Failed to access products",
+	})
 }
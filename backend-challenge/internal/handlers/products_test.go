@@ -4,9 +4,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
 	"testing"
 
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -20,7 +25,7 @@ func TestProductHandler_ListProducts(t *testing.T) {
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
-	handler := NewProductHandler()
+	handler := NewProductHandler(testProducts(t), repository.NewMemoryInventoryStore(), logging.Discard())
 
 	// Execute
 	err := handler.ListProducts(c)
@@ -34,17 +39,95 @@ func TestProductHandler_ListProducts(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.Greater(t, len(products), 0, "Should return at least one product")
+	assert.Contains(t, rec.Body.String(), `"price":12.99`, "Prices stay JSON numbers")
 
 	// Check first product structure
 	if len(products) > 0 {
 		product := products[0]
 		assert.NotEmpty(t, product.ID, "Product ID should not be empty")
 		assert.NotEmpty(t, product.Name, "Product name should not be empty")
-		assert.Greater(t, product.Price, 0.0, "Product price should be greater than 0")
+		assert.True(t, product.Price.IsPositive(), "Product price should be greater than 0")
 		assert.NotEmpty(t, product.Category, "Product category should not be empty")
 	}
 }
 
+func TestProductHandler_ListProductsQuery(t *testing.T) {
+	handler := NewProductHandler(testProducts(t), repository.NewMemoryInventoryStore(), logging.Discard())
+	e := echo.New()
+	e.GET("/api/product", handler.ListProducts)
+
+	list := func(query string) (*httptest.ResponseRecorder, []string) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/product?"+query, nil))
+
+		var products []models.Product
+		_ = json.Unmarshal(rec.Body.Bytes(), &products)
+		ids := []string{}
+		for _, p := range products {
+			ids = append(ids, p.ID)
+		}
+		return rec, ids
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"Category", "category=Waffle", []string{"1", "2"}},
+		{"Search", "q=cake", []string{"3", "8"}},
+		{"Price range", "minPrice=9.99&maxPrice=12", []string{"2", "4", "5"}},
+		{"Sorted by price", "sort=-price&maxPrice=10", []string{"4", "3", "8"}},
+		{"Sorted by name", "sort=name&category=waffle", []string{"2", "1"}},
+		{"No match", "q=soup", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, ids := list(tt.query)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.expected, ids)
+			assert.Empty(t, rec.Header().Get("Link"))
+		})
+	}
+
+	t.Run("Pages linked through the Link header", func(t *testing.T) {
+		rec, ids := list("sort=price&category=Waffle&limit=1")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"2"}, ids)
+
+		link := rec.Header().Get("Link")
+		match := regexp.MustCompile(`^<(/api/product\?[^>]+)>; rel="next"$`).FindStringSubmatch(link)
+		require.Len(t, match, 2, link)
+		next, err := url.Parse(match[1])
+		require.NoError(t, err)
+		assert.Equal(t, "Waffle", next.Query().Get("category"), "Filters are kept")
+		assert.Equal(t, "price", next.Query().Get("sort"))
+
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, match[1], nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"id":"1"`)
+		assert.Equal(t, `</api/product?category=Waffle&limit=1&sort=price>; rel="first"`, rec.Header().Get("Link"))
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		for _, query := range []string{
+			"sort=category", "limit=0", "limit=101", "limit=ten", "minPrice=abc", "maxPrice=-1",
+			"minPrice=1.001", "minPrice=10&maxPrice=5", "after=garbage", "q=" + strings.Repeat("a", 101),
+		} {
+			rec, _ := list(query)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+
+			var resp models.APIResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp), query)
+			assert.Equal(t, 400, resp.Code)
+			assert.Equal(t, "error", resp.Type)
+			assert.NotEmpty(t, resp.Message)
+		}
+	})
+}
+
 func TestProductHandler_GetProduct(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -69,7 +152,7 @@ func TestProductHandler_GetProduct(t *testing.T) {
 			c.SetParamNames("productId")
 			c.SetParamValues(tt.productID)
 
-			handler := NewProductHandler()
+			handler := NewProductHandler(testProducts(t), repository.NewMemoryInventoryStore(), logging.Discard())
 
 			// Execute
 			err := handler.GetProduct(c)
@@ -92,17 +175,148 @@ func TestProductHandler_GetProduct(t *testing.T) {
 	}
 }
 
-func TestProductHandler_GetProductByID(t *testing.T) {
-	handler := NewProductHandler()
+func TestProductHandler_CRUD(t *testing.T) {
+	handler := NewProductHandler(testProducts(t), repository.NewMemoryInventoryStore(), logging.Discard())
+	e := echo.New()
+	e.POST("/api/product", handler.CreateProduct)
+	e.GET("/api/product/:productId", handler.GetProduct)
+	e.PUT("/api/product/:productId", handler.UpdateProduct)
+	e.PATCH("/api/product/:productId", handler.PatchProduct)
+	e.DELETE("/api/product/:productId", handler.DeleteProduct)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder) models.Product {
+		var product models.Product
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+		return product
+	}
+
+	// Create without an ID gets the next one
+	rec := serve(http.MethodPost, "/api/product", `{"name":"Waffle Fries","price":4.5,"category":"Main"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	created := decode(rec)
+	assert.Equal(t, "9", created.ID)
+	assert.Equal(t, 1, created.Version)
+	assert.Equal(t, "4.50", created.Price.Decimal())
+
+	// Replace at the current version
+	rec = serve(http.MethodPut, "/api/product/9", `{"name":"Curly Fries","price":4.99,"category":"Main","version":1}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 2, decode(rec).Version)
 
-	// Test existing product
-	product, exists := handler.GetProductByID("1")
-	assert.True(t, exists, "Product with ID '1' should exist")
-	assert.NotNil(t, product, "Product should not be nil")
-	assert.Equal(t, "1", product.ID)
+	// A stale version is rejected and changes nothing
+	rec = serve(http.MethodPatch, "/api/product/9", `{"price":1.99,"version":1}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
 
-	// Test non-existing product
-	product, exists = handler.GetProductByID("999")
-	assert.False(t, exists, "Product with ID '999' should not exist")
-	assert.Nil(t, product, "Product should be nil for non-existing ID")
+	rec = serve(http.MethodPatch, "/api/product/9", `{"price":5.49,"version":2}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	patched := decode(rec)
+	assert.Equal(t, "Curly Fries", patched.Name, "Omitted fields are kept")
+	assert.Equal(t, "5.49", patched.Price.Decimal())
+	assert.Equal(t, 3, patched.Version)
+
+	rec = serve(http.MethodPatch, "/api/product/9", `{"description":"Seasoned","image":{"thumbnail":"/media/fries.png","desktop":"https://cdn.example.com/fries.png"},"version":3}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	patched = decode(rec)
+	assert.Equal(t, "Seasoned", patched.Description)
+	require.NotNil(t, patched.Image)
+	assert.Equal(t, "/media/fries.png", patched.Image.Thumbnail)
+	assert.Equal(t, "5.49", patched.Price.Decimal())
+
+	assert.Equal(t, http.StatusConflict, serve(http.MethodDelete, "/api/product/9?version=3", "").Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/api/product/9?version=4", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/api/product/9", "").Code)
+}
+
+func TestProductHandler_RejectsInvalidChanges(t *testing.T) {
+	handler := NewProductHandler(testProducts(t), repository.NewMemoryInventoryStore(), logging.Discard())
+	e := echo.New()
+	e.POST("/api/product", handler.CreateProduct)
+	e.PUT("/api/product/:productId", handler.UpdateProduct)
+	e.PATCH("/api/product/:productId", handler.PatchProduct)
+	e.DELETE("/api/product/:productId", handler.DeleteProduct)
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		expectedStatus int
+	}{
+		{"Create with taken ID", http.MethodPost, "/api/product", `{"id":"1","name":"Dup","price":1,"category":"Main"}`, http.StatusConflict},
+		{"Create without name", http.MethodPost, "/api/product", `{"price":1,"category":"Main"}`, http.StatusUnprocessableEntity},
+		{"Create with zero price", http.MethodPost, "/api/product", `{"name":"Free","price":0,"category":"Main"}`, http.StatusUnprocessableEntity},
+		{"Create with price as string", http.MethodPost, "/api/product", `{"name":"Text","price":"1.00","category":"Main"}`, http.StatusBadRequest},
+		{"Create with non-numeric ID", http.MethodPost, "/api/product", `{"id":"abc","name":"X","price":1,"category":"Main"}`, http.StatusUnprocessableEntity},
+		{"Update without version", http.MethodPut, "/api/product/1", `{"name":"X","price":1,"category":"Main"}`, http.StatusBadRequest},
+		{"Update with other ID in body", http.MethodPut, "/api/product/1", `{"id":"2","name":"X","price":1,"category":"Main","version":1}`, http.StatusBadRequest},
+		{"Update missing product", http.MethodPut, "/api/product/99", `{"name":"X","price":1,"category":"Main","version":1}`, http.StatusNotFound},
+		{"Update with invalid ID", http.MethodPut, "/api/product/abc", `{"name":"X","price":1,"category":"Main","version":1}`, http.StatusBadRequest},
+		{"Patch to empty name", http.MethodPatch, "/api/product/1", `{"name":" ","version":1}`, http.StatusUnprocessableEntity},
+		{"Patch with relative image path", http.MethodPatch, "/api/product/1", `{"image":{"mobile":"waffle.png"},"version":1}`, http.StatusUnprocessableEntity},
+		{"Patch without version", http.MethodPatch, "/api/product/1", `{"name":"X"}`, http.StatusBadRequest},
+		{"Delete without version", http.MethodDelete, "/api/product/1", "", http.StatusBadRequest},
+		{"Delete missing product", http.MethodDelete, "/api/product/99?version=1", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expectedStatus, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestProductHandler_Available(t *testing.T) {
+	products := testProducts(t)
+	inventory := repository.NewMemoryInventoryStore()
+	handler := NewProductHandler(products, inventory, logging.Discard())
+	e := echo.New()
+	e.GET("/api/product", handler.ListProducts)
+	e.GET("/api/product/:productId", handler.GetProduct)
+
+	get := func(target string, v any) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), v))
+	}
+
+	// Sell out product 2 and keep stock of product 3
+	ctx := t.Context()
+	_, err := inventory.Restock(ctx, "2", 1)
+	require.NoError(t, err)
+	require.NoError(t, inventory.Reserve(ctx, []models.OrderItem{{ProductID: "2", Quantity: 1}}))
+	_, err = inventory.Restock(ctx, "3", 1)
+	require.NoError(t, err)
+
+	var list []models.Product
+	get("/api/product", &list)
+	available := make(map[string]bool)
+	for _, p := range list {
+		available[p.ID] = p.Available
+	}
+	assert.Equal(t, map[string]bool{"1": true, "2": false, "3": true, "4": true, "5": true, "6": true, "7": true, "8": true}, available)
+
+	var product models.Product
+	get("/api/product/2", &product)
+	assert.False(t, product.Available)
+	get("/api/product/1", &product)
+	assert.True(t, product.Available, "Untracked products are available")
+}
+
+// testProducts returns an in-memory repository holding the default catalog
+func testProducts(t *testing.T) *repository.MemoryProductRepository {
+	products, err := repository.NewMemoryProductRepository(repository.DefaultCategories(), repository.DefaultProducts())
+	require.NoError(t, err)
+	return products
 }
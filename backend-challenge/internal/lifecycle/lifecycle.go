@@ -0,0 +1,140 @@
+// Package lifecycle runs the service's components under a root context and
+// stops them in order on shutdown.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+)
+
+// StopFunc stops a component, giving up when ctx is done
+type StopFunc func(ctx context.Context) error
+
+// component is a registered part of the service
+type component struct {
+	name string
+	stop StopFunc
+}
+
+// Manager owns the root context of the service. Background tasks run under
+// it, and components registered while starting up are stopped in reverse
+// order, so each stops before the components it depends on.
+type Manager struct {
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timeout time.Duration // Shutdown deadline shared by every component
+	logger  *slog.Logger
+
+	mu         sync.Mutex
+	components []component
+	tasks      sync.WaitGroup
+}
+
+// NewManager creates a manager whose Shutdown takes at most timeout
+func NewManager(timeout time.Duration, logger *slog.Logger) *Manager {
+	ctx, cancel := context.WithCancelCause(logging.NewContext(context.Background(), logger))
+	return &Manager{
+		ctx:     ctx,
+		cancel:  cancel,
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// Context returns the root context, cancelled when shutdown begins. It
+// carries the logger, see logging.FromContext.
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Register adds a component to stop on shutdown. Components are stopped in
+// reverse order of registration.
+func (m *Manager) Register(name string, stop StopFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.components = append(m.components, component{name: name, stop: stop})
+}
+
+// Go runs task in the background. Its context is the root context, and
+// Shutdown waits for the task to return.
+func (m *Manager) Go(name string, task func(ctx context.Context)) {
+	m.tasks.Add(1)
+	go func() {
+		defer m.tasks.Done()
+		task(m.ctx)
+		if m.ctx.Err() == nil {
+			m.logger.Warn("Background task stopped before shutdown", "task", name)
+		}
+	}()
+}
+
+// Fail begins shutdown because of err, e.g. a server that stopped serving.
+// Wait returns err.
+func (m *Manager) Fail(err error) {
+	m.cancel(err)
+}
+
+// Wait blocks until one of signals arrives or Fail is called. It returns
+// the error passed to Fail, nil for a signal.
+func (m *Manager) Wait(signals ...os.Signal) error {
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	defer signal.Stop(received)
+
+	select {
+	case sig := <-received:
+		m.logger.Info("Shutting down", "signal", sig.String())
+		return nil
+	case <-m.ctx.Done():
+		err := context.Cause(m.ctx)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	}
+}
+
+// Shutdown cancels the root context, which stops background tasks, then
+// stops the components in reverse order. Every step shares one deadline;
+// a component that misses it does not keep the others from stopping.
+func (m *Manager) Shutdown() error {
+	m.cancel(context.Canceled)
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	m.mu.Lock()
+	components := m.components
+	m.components = nil
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		m.logger.Info("Stopping component", "component", c.name)
+		if err := c.stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", c.name, err))
+		}
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		m.tasks.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("background tasks still running: %w", ctx.Err()))
+	}
+
+	return errors.Join(errs...)
+}
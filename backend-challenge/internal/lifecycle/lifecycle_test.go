@@ -0,0 +1,144 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_StopsComponentsInReverseOrder(t *testing.T) {
+	m := NewManager(time.Second, logging.Discard())
+
+	var mu sync.Mutex
+	var stopped []string
+	for _, name := range []string{"store", "service", "server"} {
+		m.Register(name, func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			stopped = append(stopped, name)
+			return nil
+		})
+	}
+
+	require.NoError(t, m.Shutdown())
+	assert.Equal(t, []string{"server", "service", "store"}, stopped)
+	assert.Error(t, m.Context().Err(), "The root context is cancelled")
+}
+
+func TestManager_ShutdownCancelsBackgroundTasks(t *testing.T) {
+	m := NewManager(time.Second, logging.Discard())
+
+	var running sync.WaitGroup
+	running.Add(1)
+	var sawCancel bool
+	m.Go("watcher", func(ctx context.Context) {
+		running.Done()
+		<-ctx.Done()
+		sawCancel = true
+	})
+	running.Wait()
+
+	require.NoError(t, m.Shutdown())
+	assert.True(t, sawCancel, "Shutdown waits for background tasks")
+}
+
+func TestManager_ShutdownDeadline(t *testing.T) {
+	m := NewManager(50*time.Millisecond, logging.Discard())
+
+	var storeStopped bool
+	m.Register("store", func(ctx context.Context) error {
+		storeStopped = true
+		return nil
+	})
+	m.Register("stuck", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	m.Register("broken", func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+
+	started := time.Now()
+	err := m.Shutdown()
+	assert.Less(t, time.Since(started), time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "failed to stop stuck")
+	assert.ErrorContains(t, err, "failed to stop broken: boom")
+	assert.True(t, storeStopped, "Components after a failed one are still stopped")
+}
+
+func TestManager_Wait(t *testing.T) {
+	m := NewManager(time.Second, logging.Discard())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	}()
+	assert.NoError(t, m.Wait(syscall.SIGUSR1))
+	assert.NoError(t, m.Context().Err(), "Signals leave shutting down to the caller")
+
+	errPortInUse := errors.New("port in use")
+	m.Fail(errPortInUse)
+	assert.ErrorIs(t, m.Wait(syscall.SIGUSR1), errPortInUse)
+	assert.Error(t, m.Context().Err())
+}
+
+func TestManager_DrainsInFlightRequests(t *testing.T) {
+	m := NewManager(5*time.Second, logging.Discard())
+
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	started := make(chan struct{})
+	e.GET("/order", func(c echo.Context) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond) // Still running when shutdown begins
+		return c.String(http.StatusOK, "placed")
+	})
+	m.Register("HTTP server", e.Shutdown)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	e.Listener = listener
+	go func() {
+		if err := e.Start(""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			m.Fail(err)
+		}
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/order")
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		done <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	require.NoError(t, m.Shutdown())
+	r := <-done
+	require.NoError(t, r.err)
+	assert.Equal(t, "placed", r.body, "The in-flight request completes")
+
+	_, err = http.Get("http://" + listener.Addr().String() + "/order")
+	assert.Error(t, err, "New connections are refused after shutdown")
+}
@@ -0,0 +1,103 @@
+// Package logging builds the service's structured logger and carries a
+// logger with request or task fields through a context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Output formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// ParseLevel parses debug, info, warn or error, in any case
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// ValidateFormat checks that format is json or text
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatJSON, FormatText:
+		return nil
+	}
+	return fmt.Errorf("unknown log format %q", format)
+}
+
+// New creates a logger writing records of at least level to w
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	minLevel, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: minLevel}
+
+	switch format {
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	}
+	return nil, ValidateFormat(format)
+}
+
+// Discard returns a logger that drops every record, for tests
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// contextKey is the context key of a scopedLogger
+type contextKey struct{}
+
+// scopedLogger is the logger of one request or task. It is shared by
+// everything the context is passed to, so fields added deep in a handler
+// also appear in the request log written by the middleware.
+type scopedLogger struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+}
+
+// NewContext returns a context carrying logger, for FromContext and With
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &scopedLogger{logger: logger})
+}
+
+// FromContext returns the logger of ctx, or fallback if it carries none
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	scoped, ok := ctx.Value(contextKey{}).(*scopedLogger)
+	if !ok {
+		return fallback
+	}
+	scoped.mu.Lock()
+	defer scoped.mu.Unlock()
+	return scoped.logger
+}
+
+// With adds fields to the logger of ctx, given as slog key-value pairs.
+// Contexts without a logger are left alone.
+func With(ctx context.Context, args ...any) {
+	scoped, ok := ctx.Value(contextKey{}).(*scopedLogger)
+	if !ok {
+		return
+	}
+	scoped.mu.Lock()
+	defer scoped.mu.Unlock()
+	scoped.logger = scoped.logger.With(args...)
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected slog.Level
+		wantErr  bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"INFO", slog.LevelInfo, false},
+		{" warn ", slog.LevelWarn, false},
+		{"warning", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"", 0, true},
+		{"verbose", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			level, err := ParseLevel(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, level)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, "warn")
+	require.NoError(t, err)
+
+	logger.Info("Dropped")
+	logger.Warn("Kept", "order_id", "42")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record), "One JSON record: %s", buf.String())
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "Kept", record["msg"])
+	assert.Equal(t, "42", record["order_id"])
+
+	buf.Reset()
+	logger, err = New(&buf, FormatText, "debug")
+	require.NoError(t, err)
+	logger.Debug("Shown", "key", "pos-terminal")
+	assert.Contains(t, buf.String(), `level=DEBUG msg=Shown key=pos-terminal`)
+
+	_, err = New(&buf, "xml", "info")
+	assert.Error(t, err)
+	_, err = New(&buf, FormatJSON, "loud")
+	assert.Error(t, err)
+}
+
+func TestContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	base := slog.New(slog.NewTextHandler(&buf, nil))
+
+	assert.Same(t, base, FromContext(context.Background(), base), "Contexts without a logger use the fallback")
+	With(context.Background(), "ignored", true) // Must not panic
+
+	ctx := NewContext(context.Background(), base.With("request_id", "abc"))
+	With(ctx, "api_key", "pos-terminal")
+	With(ctx, "order_id", "42")
+	FromContext(ctx, base).Info("Order placed")
+
+	line := strings.TrimSpace(buf.String())
+	assert.Contains(t, line, "msg=\"Order placed\" request_id=abc api_key=pos-terminal order_id=42")
+}
@@ -0,0 +1,278 @@
+// Package metrics is a small registry of counters, gauges and histograms,
+// exposed in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Metric types, as written in the TYPE line
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// DefaultBuckets are histogram buckets for request latencies in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// namePattern matches valid metric and label names
+var namePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Registry holds metric families and writes them in the text format.
+// Registering a name twice or an invalid name panics, as it is a
+// programming error.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// family is a metric with all its label combinations
+type family struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64      // Upper bounds of histograms, +Inf excluded
+	value   func() float64 // Set for gauges read at scrape time
+
+	mu     sync.Mutex
+	series map[string]*series // Keyed by the joined label values
+}
+
+// series is one label combination of a family
+type series struct {
+	labelValues []string
+	counter     *Counter
+	gauge       *Gauge
+	histogram   *Histogram
+}
+
+// register adds a family, panicking on invalid or duplicate names
+func (r *Registry) register(f *family) *family {
+	if !namePattern.MatchString(f.name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", f.name))
+	}
+	for _, label := range f.labels {
+		if !namePattern.MatchString(label) || label == "le" {
+			panic(fmt.Sprintf("metrics: invalid label name %q of %s", label, f.name))
+		}
+	}
+	f.series = make(map[string]*series)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.families[f.name]; exists {
+		panic(fmt.Sprintf("metrics: %s registered twice", f.name))
+	}
+	r.families[f.name] = f
+	return f
+}
+
+// with returns the series of labelValues, creating it on first use
+func (f *family) with(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		switch f.typ {
+		case typeCounter:
+			s.counter = &Counter{}
+		case typeGauge:
+			s.gauge = &Gauge{}
+		case typeHistogram:
+			s.histogram = newHistogram(f.buckets)
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// NewCounter registers a counter without labels
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.register(&family{name: name, help: help, typ: typeCounter}).with(nil).counter
+}
+
+// NewCounterVec registers a counter partitioned by labels
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{r.register(&family{name: name, help: help, typ: typeCounter, labels: labels})}
+}
+
+// NewGauge registers a gauge without labels
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return r.register(&family{name: name, help: help, typ: typeGauge}).with(nil).gauge
+}
+
+// NewGaugeVec registers a gauge partitioned by labels
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{r.register(&family{name: name, help: help, typ: typeGauge, labels: labels})}
+}
+
+// NewGaugeFunc registers a gauge whose value is read from value when scraped
+func (r *Registry) NewGaugeFunc(name, help string, value func() float64) {
+	r.register(&family{name: name, help: help, typ: typeGauge, value: value})
+}
+
+// NewHistogram registers a histogram without labels. buckets are the
+// upper bounds of the buckets, in increasing order.
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	return r.register(&family{name: name, help: help, typ: typeHistogram, buckets: checkBuckets(name, buckets)}).with(nil).histogram
+}
+
+// NewHistogramVec registers a histogram partitioned by labels
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{r.register(&family{
+		name: name, help: help, typ: typeHistogram, labels: labels, buckets: checkBuckets(name, buckets),
+	})}
+}
+
+// checkBuckets panics unless buckets increase, dropping a trailing +Inf
+func checkBuckets(name string, buckets []float64) []float64 {
+	if n := len(buckets); n > 0 && math.IsInf(buckets[n-1], 1) {
+		buckets = buckets[:n-1]
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			panic(fmt.Sprintf("metrics: buckets of %s must increase", name))
+		}
+	}
+	return append([]float64(nil), buckets...)
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	bits atomic.Uint64
+}
+
+// Inc adds one
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds v, which must not be negative
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	addFloat(&c.bits, v)
+}
+
+// Value returns the current count
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	family *family
+}
+
+// With returns the counter of the label values, in the order of the labels
+func (v *CounterVec) With(labelValues ...string) *Counter {
+	return v.family.with(labelValues).counter
+}
+
+// Gauge is a value that goes up and down
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+// Set sets the value
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+// Add adds v, which may be negative
+func (g *Gauge) Add(v float64) {
+	addFloat(&g.bits, v)
+}
+
+// Value returns the current value
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct {
+	family *family
+}
+
+// With returns the gauge of the label values, in the order of the labels
+func (v *GaugeVec) With(labelValues ...string) *Gauge {
+	return v.family.with(labelValues).gauge
+}
+
+// Histogram counts observations in buckets
+type Histogram struct {
+	mu      sync.Mutex
+	bounds  []float64
+	counts  []uint64 // Per bucket, not cumulative; the last is +Inf
+	sum     float64
+	samples uint64
+}
+
+// newHistogram creates a histogram with the given upper bounds
+func newHistogram(bounds []float64) *Histogram {
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+// Observe records a value
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v) // First bound >= v
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.sum += v
+	h.samples++
+}
+
+// snapshot returns the cumulative bucket counts, sum and count
+func (h *Histogram) snapshot() ([]uint64, float64, uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cumulative := make([]uint64, len(h.counts))
+	var total uint64
+	for i, n := range h.counts {
+		total += n
+		cumulative[i] = total
+	}
+	return cumulative, h.sum, h.samples
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	family *family
+}
+
+// With returns the histogram of the label values, in the order of the labels
+func (v *HistogramVec) With(labelValues ...string) *Histogram {
+	return v.family.with(labelValues).histogram
+}
+
+// addFloat atomically adds v to the float64 stored in bits
+func addFloat(bits *atomic.Uint64, v float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	reg := NewRegistry()
+	placed := reg.NewCounter("orders_placed_total", "Orders placed")
+	rejected := reg.NewCounterVec("orders_rejected_total", "Orders rejected, by reason", "reason")
+	codes := reg.NewGauge("promo_codes_loaded", "Valid promo codes")
+	reg.NewGaugeFunc("build_info", "Always 1", func() float64 { return 1 })
+	latency := reg.NewHistogramVec("request_duration_seconds", "Request latency", []float64{0.1, 1}, "route")
+	reg.NewCounterVec("unused_total", "Never incremented", "reason")
+
+	placed.Add(3)
+	rejected.With("out_of_stock").Inc()
+	rejected.With(`odd "reason"` + "\n").Inc()
+	codes.Set(1500)
+	codes.Add(-500)
+	latency.With("/api/order").Observe(0.05)
+	latency.With("/api/order").Observe(0.1) // Upper bounds are inclusive
+	latency.With("/api/order").Observe(3)
+
+	var out strings.Builder
+	require.NoError(t, reg.WriteText(&out))
+	assert.Equal(t, `# HELP build_info Always 1
+# TYPE build_info gauge
+build_info 1
+# HELP orders_placed_total Orders placed
+# TYPE orders_placed_total counter
+orders_placed_total 3
+# HELP orders_rejected_total Orders rejected, by reason
+# TYPE orders_rejected_total counter
+orders_rejected_total{reason="odd \"reason\"\n"} 1
+orders_rejected_total{reason="out_of_stock"} 1
+# HELP promo_codes_loaded Valid promo codes
+# TYPE promo_codes_loaded gauge
+promo_codes_loaded 1000
+# HELP request_duration_seconds Request latency
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{route="/api/order",le="0.1"} 2
+request_duration_seconds_bucket{route="/api/order",le="1"} 2
+request_duration_seconds_bucket{route="/api/order",le="+Inf"} 3
+request_duration_seconds_sum{route="/api/order"} 3.15
+request_duration_seconds_count{route="/api/order"} 3
+`, out.String())
+
+	samples, err := ParseText(strings.NewReader(out.String()))
+	require.NoError(t, err)
+	assert.Len(t, samples, 10)
+	value, ok := Find(samples, "orders_rejected_total", "reason", `odd "reason"`+"\n")
+	assert.True(t, ok, "Escaped values round trip")
+	assert.Equal(t, 1.0, value)
+	value, ok = Find(samples, "request_duration_seconds_bucket", "route", "/api/order", "le", "+Inf")
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, value)
+	_, ok = Find(samples, "unused_total")
+	assert.False(t, ok)
+}
+
+func TestRegistry_Misuse(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("requests_total", "Requests")
+	vec := reg.NewCounterVec("errors_total", "Errors", "kind")
+
+	assert.Panics(t, func() { reg.NewGauge("requests_total", "Again") }, "Duplicate name")
+	assert.Panics(t, func() { reg.NewCounter("requests-total", "Dash") }, "Invalid name")
+	assert.Panics(t, func() { reg.NewCounterVec("bad_total", "Reserved label", "le") })
+	assert.Panics(t, func() { reg.NewHistogram("latency", "Unsorted", []float64{1, 0.5}) })
+	assert.Panics(t, func() { vec.With("a", "b") }, "Wrong label count")
+	assert.Panics(t, func() { vec.With("a").Add(-1) }, "Counters only go up")
+}
+
+func TestRegistry_ConcurrentUpdates(t *testing.T) {
+	reg := NewRegistry()
+	counter := reg.NewCounterVec("hits_total", "Hits", "result")
+	histogram := reg.NewHistogram("latency_seconds", "Latency", DefaultBuckets)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				counter.With("hit").Inc()
+				histogram.Observe(0.2)
+			}
+			reg.WriteText(&strings.Builder{})
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5000.0, counter.With("hit").Value())
+	_, _, count := histogram.snapshot()
+	assert.Equal(t, uint64(5000), count)
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("scrapes_total", "Scrapes").Inc()
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "scrapes_total 1\n")
+}
+
+func TestParseText_Invalid(t *testing.T) {
+	for _, input := range []string{
+		"no_value",
+		`open{label="x" 1`,
+		`unterminated{label="x} 1`,
+		"bad_value abc",
+	} {
+		_, err := ParseText(strings.NewReader(input))
+		assert.Error(t, err, input)
+	}
+}
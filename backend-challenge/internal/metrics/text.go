@@ -0,0 +1,236 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of the Prometheus text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteText writes every metric in the Prometheus text format, families
+// sorted by name and series by label values. Vectors without series yet
+// are left out.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	var buf bytes.Buffer
+	for _, f := range families {
+		f.write(&buf)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Handler serves the registry's metrics, for a Prometheus scrape
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteText(w)
+	})
+}
+
+// write writes the family, unless it has no series
+func (f *family) write(buf *bytes.Buffer) {
+	if f.value != nil {
+		writeHeader(buf, f)
+		writeSample(buf, f.name, nil, nil, f.value())
+		return
+	}
+
+	f.mu.Lock()
+	all := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		all = append(all, s)
+	}
+	f.mu.Unlock()
+	if len(all) == 0 {
+		return
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].labelValues, "\xff") < strings.Join(all[j].labelValues, "\xff")
+	})
+
+	writeHeader(buf, f)
+	for _, s := range all {
+		switch {
+		case s.counter != nil:
+			writeSample(buf, f.name, f.labels, s.labelValues, s.counter.Value())
+		case s.gauge != nil:
+			writeSample(buf, f.name, f.labels, s.labelValues, s.gauge.Value())
+		case s.histogram != nil:
+			cumulative, sum, count := s.histogram.snapshot()
+			labels := append(append([]string(nil), f.labels...), "le")
+			for i, n := range cumulative {
+				bound := math.Inf(1)
+				if i < len(f.buckets) {
+					bound = f.buckets[i]
+				}
+				values := append(append([]string(nil), s.labelValues...), formatFloat(bound))
+				writeSample(buf, f.name+"_bucket", labels, values, float64(n))
+			}
+			writeSample(buf, f.name+"_sum", f.labels, s.labelValues, sum)
+			writeSample(buf, f.name+"_count", f.labels, s.labelValues, float64(count))
+		}
+	}
+}
+
+// writeHeader writes the HELP and TYPE lines of a family
+func writeHeader(buf *bytes.Buffer, f *family) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help)
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", f.name, help, f.name, f.typ)
+}
+
+// labelEscaper escapes label values
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeSample writes one sample line
+func writeSample(buf *bytes.Buffer, name string, labels, values []string, value float64) {
+	buf.WriteString(name)
+	if len(labels) > 0 {
+		buf.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(buf, `%s="%s"`, label, labelEscaper.Replace(values[i]))
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(value))
+	buf.WriteByte('\n')
+}
+
+// formatFloat formats a sample value the way Prometheus does
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Sample is one line of the text format
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// ParseText reads samples in the text format, as written by WriteText.
+// Comments are skipped; timestamps are not supported.
+func ParseText(r io.Reader) ([]Sample, error) {
+	var samples []Sample
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		sample, err := parseSample(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+// parseSample parses `name{label="value",...} value`
+func parseSample(text string) (Sample, error) {
+	sample := Sample{Labels: map[string]string{}}
+
+	end := strings.IndexAny(text, "{ ")
+	if end <= 0 {
+		return sample, fmt.Errorf("invalid sample %q", text)
+	}
+	sample.Name, text = text[:end], text[end:]
+
+	if strings.HasPrefix(text, "{") {
+		text = text[1:]
+		for !strings.HasPrefix(text, "}") {
+			eq := strings.Index(text, `="`)
+			if eq <= 0 {
+				return sample, fmt.Errorf("invalid labels of %s", sample.Name)
+			}
+			label := strings.TrimPrefix(text[:eq], ",")
+			value, rest, err := unquoteLabel(text[eq+2:])
+			if err != nil {
+				return sample, fmt.Errorf("label %s of %s: %w", label, sample.Name, err)
+			}
+			sample.Labels[label] = value
+			text = strings.TrimPrefix(rest, ",")
+		}
+		text = text[1:]
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value of %s: %w", sample.Name, err)
+	}
+	sample.Value = value
+	return sample, nil
+}
+
+// unquoteLabel reads an escaped label value up to its closing quote
+func unquoteLabel(text string) (string, string, error) {
+	var value strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '"':
+			return value.String(), text[i+1:], nil
+		case '\\':
+			i++
+			if i == len(text) {
+				break
+			}
+			if text[i] == 'n' {
+				value.WriteByte('\n')
+			} else {
+				value.WriteByte(text[i])
+			}
+		default:
+			value.WriteByte(text[i])
+		}
+	}
+	return "", "", fmt.Errorf("unterminated value")
+}
+
+// Find returns the value of the sample with name and at least the given
+// labels, given as name-value pairs
+func Find(samples []Sample, name string, labels ...string) (float64, bool) {
+	for _, sample := range samples {
+		if sample.Name != name {
+			continue
+		}
+		matches := true
+		for i := 0; i+1 < len(labels); i += 2 {
+			if sample.Labels[labels[i]] != labels[i+1] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return sample.Value, true
+		}
+	}
+	return 0, false
+}
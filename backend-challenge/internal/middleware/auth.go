@@ -1,28 +1,58 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/ilyulev/kart-challenge/backend-api/internal/auth"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
 
 	"github.com/labstack/echo/v4"
 )
 
-// APIKeyAuth middleware validates API key for protected endpoints
-func APIKeyAuth() echo.MiddlewareFunc {
+// APIKeyHeader is the request header carrying the API key
+const APIKeyHeader = "api_key"
+
+// apiKeyContextKey is where the authenticated key is kept in the echo context
+const apiKeyContextKey = "apiKey"
+
+// APIKeyAuth middleware validates the API key for protected endpoints.
+// Unknown or expired keys get 401, valid keys without scope get 403.
+func APIKeyAuth(keys auth.KeyLookup, scope string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			// Check API key
-			apiKey := c.Request().Header.Get("api_key")
-			if apiKey != "apitest" {
+			key, err := keys.Lookup(c.Request().Header.Get(APIKeyHeader))
+			if err != nil {
+				message := "Invalid or missing API key"
+				if errors.Is(err, auth.ErrKeyExpired) {
+					message = "API key expired"
+				}
 				return c.JSON(http.StatusUnauthorized, models.APIResponse{
 					Code:    401,
 					Type:    "error",
-					Message: "Invalid or missing API key",
+					Message: message,
+				})
+			}
+
+			if !key.HasScope(scope) {
+				return c.JSON(http.StatusForbidden, models.APIResponse{
+					Code:    403,
+					Type:    "error",
+					Message: "API key lacks the " + scope + " scope",
 				})
 			}
 
+			c.Set(apiKeyContextKey, key)
+			logging.With(c.Request().Context(), "api_key", key.Name)
 			return next(c)
 		}
 	}
 }
+
+// CurrentAPIKey returns the key APIKeyAuth accepted for the request
+func CurrentAPIKey(c echo.Context) (auth.APIKey, bool) {
+	key, ok := c.Get(apiKeyContextKey).(auth.APIKey)
+	return key, ok
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/auth"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	keys, err := auth.NewKeySet([]auth.APIKey{
+		{Name: "admin", Key: "admin-secret", Scopes: []string{auth.ScopeAll}},
+		{Name: "reporting", Key: "report-secret", Scopes: []string{auth.ScopeReadOrders}},
+		{Name: "old", Key: "old-secret", Scopes: []string{auth.ScopeAll}, ExpiresAt: time.Now().Add(-time.Hour)},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		apiKey         string
+		scope          string
+		expectedStatus int
+		expectedKey    string
+	}{
+		{"Missing key", "", auth.ScopeCreateOrder, http.StatusUnauthorized, ""},
+		{"Unknown key", "nope", auth.ScopeCreateOrder, http.StatusUnauthorized, ""},
+		{"Expired key", "old-secret", auth.ScopeReadOrders, http.StatusUnauthorized, ""},
+		{"Missing scope", "report-secret", auth.ScopeCreateOrder, http.StatusForbidden, ""},
+		{"Granted scope", "report-secret", auth.ScopeReadOrders, http.StatusOK, "reporting"},
+		{"Wildcard scope", "admin-secret", auth.ScopeCreateOrder, http.StatusOK, "admin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			var seen string
+			handler := APIKeyAuth(keys, tt.scope)(func(c echo.Context) error {
+				key, ok := CurrentAPIKey(c)
+				require.True(t, ok)
+				seen = key.Name
+				return c.NoContent(http.StatusOK)
+			})
+
+			require.NoError(t, handler(c))
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedKey, seen)
+			if tt.expectedStatus == http.StatusUnauthorized && tt.apiKey == "old-secret" {
+				assert.Contains(t, rec.Body.String(), "expired")
+			}
+		})
+	}
+}
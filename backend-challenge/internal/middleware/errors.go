@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HTTPErrorHandler answers errors that reach Echo with a models.APIResponse
+// body, like the handlers' own errors, so they carry the request ID too:
+// 404 and 405 from the router, echo.HTTPError returned by handlers and
+// middleware, and panics caught by Recover. Other errors get a plain 500,
+// their details are only logged.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	code := http.StatusInternalServerError
+	message := http.StatusText(code)
+	if he, ok := asHTTPError(err); ok {
+		code = he.Code
+		message = http.StatusText(code)
+		if msg, ok := he.Message.(string); ok && msg != "" {
+			message = msg
+		}
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(code)
+	} else {
+		err = c.JSON(code, models.APIResponse{Code: code, Type: "error", Message: message})
+	}
+	if err != nil {
+		logging.FromContext(c.Request().Context(), slog.Default()).Warn("Failed to write error response", "error", err)
+	}
+}
+
+// responseStatus returns the status of the response to c once the handler
+// returned err. Middleware that passes errors on sees the status the error
+// handler will write, so only the outermost middleware calls c.Error.
+func responseStatus(c echo.Context, err error) int {
+	if err == nil || c.Response().Committed {
+		return c.Response().Status
+	}
+	if he, ok := asHTTPError(err); ok {
+		return he.Code
+	}
+	return http.StatusInternalServerError
+}
+
+// asHTTPError returns the echo.HTTPError in err's chain, preferring one it
+// wraps as Echo does
+func asHTTPError(err error) (*echo.HTTPError, bool) {
+	var he *echo.HTTPError
+	if !errors.As(err, &he) {
+		return nil, false
+	}
+	if internal, ok := he.Internal.(*echo.HTTPError); ok {
+		he = internal
+	}
+	return he, true
+}
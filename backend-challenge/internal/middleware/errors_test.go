@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/requestid"
+
+	"github.com/labstack/echo/v4"
+	echomiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPErrorHandler(t *testing.T) {
+	e := echo.New()
+	e.JSONSerializer = RequestIDSerializer{}
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.Use(RequestID())
+	e.Use(echomiddleware.Recover())
+
+	e.GET("/order", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid order ID")
+	})
+	e.GET("/broken", func(c echo.Context) error {
+		return errors.New("database down")
+	})
+	e.GET("/panic", func(c echo.Context) error {
+		panic("nil map")
+	})
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		code    int
+		message string
+	}{
+		{"Unknown route", http.MethodGet, "/wp-login.php", http.StatusNotFound, "Not Found"},
+		{"Wrong method", http.MethodPost, "/order", http.StatusMethodNotAllowed, "Method Not Allowed"},
+		{"HTTP error", http.MethodGet, "/order", http.StatusBadRequest, "Invalid order ID"},
+		{"Other errors are not leaked", http.MethodGet, "/broken", http.StatusInternalServerError, "Internal Server Error"},
+		{"Recovered panic", http.MethodGet, "/panic", http.StatusInternalServerError, "Internal Server Error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(requestid.Header, "pos-7-42")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.code, rec.Code)
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, map[string]any{
+				"code":      float64(tt.code),
+				"type":      "error",
+				"message":   tt.message,
+				"requestId": "pos-7-42",
+			}, body)
+		})
+	}
+
+	t.Run("HEAD requests get no body", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/wp-login.php", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+}
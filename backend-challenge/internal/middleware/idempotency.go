@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// IdempotencyKeyHeader is the request header clients send to make retries safe
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader marks responses replayed from the store
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLen bounds the keys clients may send
+const maxIdempotencyKeyLen = 255
+
+// Idempotency replays the stored response when a request is repeated with the
+// same Idempotency-Key and body. Reusing a key with a different body is
+// rejected with 422. Requests without the header pass through untouched.
+// Keys are scoped by API key, so clients cannot see each other's responses.
+func Idempotency(store repository.IdempotencyStore, ttl time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLen {
+				return c.JSON(http.StatusBadRequest, models.APIResponse{
+					Code:    400,
+					Type:    "error",
+					Message: "Idempotency-Key must be at most 255 characters",
+				})
+			}
+
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, models.APIResponse{
+					Code:    400,
+					Type:    "error",
+					Message: "Invalid request body",
+				})
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := c.Request().Context()
+			storeKey := scopedKey(c, key)
+			fingerprint := requestFingerprint(c.Request(), body)
+
+			record, reserved, err := store.Reserve(ctx, storeKey, fingerprint, ttl)
+			if err != nil {
+				logging.FromContext(ctx, slog.Default()).Error("Idempotency store unavailable", "error", err)
+				return c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+					Code:    503,
+					Type:    "error",
+					Message: "Idempotency store unavailable, retry later",
+				})
+			}
+
+			if !reserved {
+				return replay(c, record, fingerprint)
+			}
+
+			// Capture the response while it is written
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			completed := false
+			defer func() {
+				// Panics and errors leave nothing worth replaying
+				if !completed {
+					store.Release(ctx, storeKey)
+				}
+			}()
+
+			if err := next(c); err != nil {
+				return err
+			}
+
+			// Server errors are not final, let the client retry them
+			status := c.Response().Status
+			if status >= http.StatusInternalServerError {
+				return nil
+			}
+
+			err = store.Complete(ctx, storeKey, repository.IdempotencyRecord{
+				Status:      status,
+				ContentType: c.Response().Header().Get(echo.HeaderContentType),
+				Body:        recorder.body.Bytes(),
+			})
+			if err != nil {
+				logging.FromContext(ctx, slog.Default()).Error("Failed to store idempotent response", "error", err)
+				return nil
+			}
+			completed = true
+			return nil
+		}
+	}
+}
+
+// replay answers a request whose key is already known
+func replay(c echo.Context, record repository.IdempotencyRecord, fingerprint string) error {
+	if record.Fingerprint != fingerprint {
+		return c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
+			Code:    422,
+			Type:    "error",
+			Message: "Idempotency-Key was already used with a different request",
+		})
+	}
+	if !record.Completed {
+		return c.JSON(http.StatusConflict, models.APIResponse{
+			Code:    409,
+			Type:    "error",
+			Message: "A request with this Idempotency-Key is still being processed",
+		})
+	}
+
+	c.Response().Header().Set(IdempotentReplayedHeader, "true")
+	return c.Blob(record.Status, record.ContentType, record.Body)
+}
+
+// scopedKey namespaces an idempotency key by the caller's API key
+func scopedKey(c echo.Context, key string) string {
+	if apiKey, ok := CurrentAPIKey(c); ok {
+		return "key:" + apiKey.Name + ":" + key
+	}
+	sum := sha256.Sum256([]byte(c.Request().Header.Get(APIKeyHeader)))
+	return hex.EncodeToString(sum[:8]) + ":" + key
+}
+
+// requestFingerprint identifies a request by method, path and body.
+// JSON bodies are compacted so whitespace differences do not count.
+func requestFingerprint(r *http.Request, body []byte) string {
+	var compact bytes.Buffer
+	if json.Compact(&compact, body) == nil {
+		body = compact.Bytes()
+	}
+
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder copies the response body as it is written
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
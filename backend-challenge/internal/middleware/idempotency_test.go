@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// newIdempotentServer counts how often the wrapped handler really runs
+func newIdempotentServer(status int, calls *int32) *echo.Echo {
+	e := echo.New()
+	e.POST("/api/order", func(c echo.Context) error {
+		n := atomic.AddInt32(calls, 1)
+		return c.JSON(status, map[string]int32{"call": n})
+	}, Idempotency(repository.NewMemoryIdempotencyStore(), time.Hour))
+	return e
+}
+
+func post(e *echo.Echo, key, apiKey, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/order", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	req.Header.Set("api_key", apiKey)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotency_ReplaysSameRequest(t *testing.T) {
+	var calls int32
+	e := newIdempotentServer(http.StatusOK, &calls)
+
+	first := post(e, "retry-1", "apitest", `{"items":[{"productId":"1","quantity":1}]}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+
+	// Whitespace differences do not change the request
+	again := post(e, "retry-1", "apitest", `{ "items": [ {"productId": "1", "quantity": 1} ] }`)
+	assert.Equal(t, http.StatusOK, again.Code)
+	assert.Equal(t, first.Body.String(), again.Body.String())
+	assert.Equal(t, "true", again.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, echo.MIMEApplicationJSON, again.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, int32(1), calls, "The handler runs once")
+}
+
+func TestIdempotency_RejectsDifferentBody(t *testing.T) {
+	var calls int32
+	e := newIdempotentServer(http.StatusOK, &calls)
+
+	post(e, "retry-1", "apitest", `{"items":[{"productId":"1","quantity":1}]}`)
+	rec := post(e, "retry-1", "apitest", `{"items":[{"productId":"1","quantity":2}]}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"type":"error"`)
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestIdempotency_KeysAreScopedByAPIKey(t *testing.T) {
+	var calls int32
+	e := newIdempotentServer(http.StatusOK, &calls)
+
+	post(e, "retry-1", "client-a", `{}`)
+	rec := post(e, "retry-1", "client-b", `{}`)
+
+	assert.Empty(t, rec.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestIdempotency_WithoutKey(t *testing.T) {
+	var calls int32
+	e := newIdempotentServer(http.StatusOK, &calls)
+
+	post(e, "", "apitest", `{}`)
+	post(e, "", "apitest", `{}`)
+	assert.Equal(t, int32(2), calls)
+
+	rec := post(e, strings.Repeat("k", 256), "apitest", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestIdempotency_ServerErrorsAreRetried(t *testing.T) {
+	var calls int32
+	e := newIdempotentServer(http.StatusInternalServerError, &calls)
+
+	post(e, "retry-1", "apitest", `{}`)
+	rec := post(e, "retry-1", "apitest", `{}`)
+
+	assert.Empty(t, rec.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestIdempotency_ClientErrorsAreReplayed(t *testing.T) {
+	var calls int32
+	e := newIdempotentServer(http.StatusUnprocessableEntity, &calls)
+
+	post(e, "retry-1", "apitest", `{}`)
+	rec := post(e, "retry-1", "apitest", `{}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestIdempotency_ConcurrentRetryConflicts(t *testing.T) {
+	store := repository.NewMemoryIdempotencyStore()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	e := echo.New()
+	e.POST("/api/order", func(c echo.Context) error {
+		close(started)
+		<-release
+		return c.JSON(http.StatusOK, map[string]string{"id": "ORD-1"})
+	}, Idempotency(store, time.Hour))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post(e, "retry-1", "apitest", `{}`) }()
+
+	<-started
+	rec := post(e, "retry-1", "apitest", `{}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/requestid"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequestLogger logs every request once it is answered, at error level for
+// 5xx responses. It gives the request a logger carrying the request fields,
+// which handlers get with logging.FromContext and can add fields to with
+// logging.With; those fields are part of the request log too. It is the
+// outermost middleware looking at responses: it lets the error handler
+// write the response to an error, so the middleware it wraps must pass
+// errors on rather than handle them.
+func RequestLogger(logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			started := time.Now()
+			req := c.Request()
+
+			args := []any{"method", req.Method, "path", req.URL.Path, "remote_ip", c.RealIP()}
+			if id := requestid.FromContext(req.Context()); id != "" {
+				args = append(args, "request_id", id)
+			}
+			ctx := logging.NewContext(req.Context(), logger.With(args...))
+			c.SetRequest(req.WithContext(ctx))
+
+			// Let the error handler write the response, so its status is logged
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			res := c.Response()
+			level := slog.LevelInfo
+			if res.Status >= 500 {
+				level = slog.LevelError
+			}
+			logging.FromContext(ctx, logger).Log(ctx, level, "Request completed",
+				"status", res.Status,
+				"bytes_out", res.Size,
+				"latency", time.Since(started),
+			)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/auth"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLogger(t *testing.T) {
+	keys, err := auth.NewKeySet([]auth.APIKey{
+		{Name: "pos-terminal", Key: "pos-secret", Scopes: []string{auth.ScopeAll}},
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	e := echo.New()
+	e.Use(RequestID())
+	e.Use(RequestLogger(logger))
+	e.POST("/order", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		logging.With(ctx, "order_id", "42")
+		logging.FromContext(ctx, nil).Info("Order placed")
+		return c.NoContent(http.StatusOK)
+	}, APIKeyAuth(keys, auth.ScopeCreateOrder))
+	e.GET("/broken", func(c echo.Context) error {
+		return errors.New("database down")
+	})
+
+	// records returns the JSON records logged by one request
+	records := func(req *http.Request) []map[string]any {
+		buf.Reset()
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var record map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+			records = append(records, record)
+		}
+		return records
+	}
+
+	t.Run("Request fields", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/order?debug=1", nil)
+		req.Header.Set(APIKeyHeader, "pos-secret")
+		req.Header.Set(echo.HeaderXRequestID, "req-1")
+		logged := records(req)
+		require.Len(t, logged, 2, "The handler's record and one request record")
+
+		for _, record := range logged {
+			assert.Equal(t, "req-1", record["request_id"])
+			assert.Equal(t, "pos-terminal", record["api_key"])
+			assert.Equal(t, "42", record["order_id"])
+		}
+		assert.Equal(t, "Order placed", logged[0]["msg"])
+
+		request := logged[1]
+		assert.Equal(t, "Request completed", request["msg"])
+		assert.Equal(t, "INFO", request["level"])
+		assert.Equal(t, "POST", request["method"])
+		assert.Equal(t, "/order", request["path"])
+		assert.Equal(t, float64(http.StatusOK), request["status"])
+		assert.Contains(t, request, "latency")
+	})
+
+	t.Run("Rejected key", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/order", nil)
+		req.Header.Set(APIKeyHeader, "wrong")
+		logged := records(req)
+		require.Len(t, logged, 1)
+		assert.Equal(t, float64(http.StatusUnauthorized), logged[0]["status"])
+		assert.NotContains(t, logged[0], "api_key")
+		assert.Len(t, logged[0]["request_id"], 32, "Requests without an ID get a generated one")
+	})
+
+	t.Run("Server error", func(t *testing.T) {
+		logged := records(httptest.NewRequest(http.MethodGet, "/broken", nil))
+		require.Len(t, logged, 1)
+		assert.Equal(t, "ERROR", logged[0]["level"])
+		assert.Equal(t, float64(http.StatusInternalServerError), logged[0]["status"], "Errors are logged with the status sent")
+	})
+}
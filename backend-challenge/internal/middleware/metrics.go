@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/metrics"
+
+	"github.com/labstack/echo/v4"
+)
+
+// unmatchedRoute is the route label of requests no route matched, so
+// scans of random paths cannot create unbounded label values
+const unmatchedRoute = "unmatched"
+
+// Metrics counts requests and measures their latency by method, route
+// and status, registering http_requests_total and
+// http_request_duration_seconds in reg. The route is the registered path,
+// e.g. /api/order/:orderId. Errors are passed on.
+func Metrics(reg *metrics.Registry) echo.MiddlewareFunc {
+	requests := reg.NewCounterVec("http_requests_total",
+		"HTTP requests answered, by method, route and status", "method", "route", "status")
+	latency := reg.NewHistogramVec("http_request_duration_seconds",
+		"HTTP request latency in seconds, by method, route and status", metrics.DefaultBuckets, "method", "route", "status")
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			started := time.Now()
+
+			err := next(c)
+
+			route := c.Path()
+			if route == "" {
+				route = unmatchedRoute
+			}
+			status := strconv.Itoa(responseStatus(c, err))
+			method := c.Request().Method
+			requests.With(method, route, status).Inc()
+			latency.With(method, route, status).Observe(time.Since(started).Seconds())
+			return err
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/metrics"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	e := echo.New()
+	e.Use(Metrics(reg))
+	e.GET("/order/:orderId", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/broken", func(c echo.Context) error {
+		return errors.New("database down")
+	})
+
+	for _, path := range []string{"/order/1", "/order/2", "/broken", "/wp-login.php"} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var out strings.Builder
+	require.NoError(t, reg.WriteText(&out))
+	samples, err := metrics.ParseText(strings.NewReader(out.String()))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		route  string
+		status string
+		count  float64
+	}{
+		{"Route pattern, not the path", "/order/:orderId", "200", 2},
+		{"Errors counted with the status sent", "/broken", "500", 1},
+		{"Unmatched paths share one label", "unmatched", "404", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, ok := metrics.Find(samples, "http_requests_total", "method", "GET", "route", tt.route, "status", tt.status)
+			require.True(t, ok)
+			assert.Equal(t, tt.count, count)
+
+			observed, ok := metrics.Find(samples, "http_request_duration_seconds_count", "route", tt.route, "status", tt.status)
+			require.True(t, ok)
+			assert.Equal(t, tt.count, observed)
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/ratelimit"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Rate limit response headers
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// RateLimitPolicy holds the limits of one route group
+type RateLimitPolicy struct {
+	Group  string          // Buckets are kept per group
+	PerKey ratelimit.Limit // Per API key, unless the key sets its own limit
+	PerIP  ratelimit.Limit // Per client IP
+}
+
+// rateLimitStateKey is the context key of the buckets a request took from
+const rateLimitStateKey = "rate_limit"
+
+// RateLimit applies the token bucket limit per client IP. It goes before
+// APIKeyAuth, so failed authentication attempts are limited too, and
+// RateLimitKey after it. Requests over a limit get 429 with Retry-After.
+// Every response carries the X-RateLimit-* headers of the tightest bucket.
+// If the store fails, requests are let through.
+func RateLimit(store ratelimit.Store, policy RateLimitPolicy) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return takeTokens(c, store, rateBucket{policy.Group + ":ip:" + c.RealIP(), policy.PerIP}, next)
+		}
+	}
+}
+
+// RateLimitKey applies the token bucket limit per API key, or the key's own
+// limit, after APIKeyAuth. A request it denies gets back the token
+// RateLimit took, so it spends none.
+func RateLimitKey(store ratelimit.Store, policy RateLimitPolicy) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			apiKey, ok := CurrentAPIKey(c)
+			if !ok {
+				return next(c)
+			}
+			limit := policy.PerKey
+			if !apiKey.RateLimit.Unlimited() {
+				limit = apiKey.RateLimit
+			}
+			return takeTokens(c, store, rateBucket{policy.Group + ":key:" + apiKey.Name, limit}, next)
+		}
+	}
+}
+
+// rateBucket is a store key and the limit that applies to it
+type rateBucket struct {
+	key   string
+	limit ratelimit.Limit
+}
+
+// rateLimitState is what the rate limit middleware of a request has taken
+type rateLimitState struct {
+	taken    []rateBucket
+	tightest *ratelimit.Decision
+}
+
+// takeTokens takes a token from bucket and calls next, or answers 429 and
+// refunds the buckets taken earlier in the request
+func takeTokens(c echo.Context, store ratelimit.Store, bucket rateBucket, next echo.HandlerFunc) error {
+	ctx := c.Request().Context()
+	state, _ := c.Get(rateLimitStateKey).(*rateLimitState)
+	if state == nil {
+		state = &rateLimitState{}
+		c.Set(rateLimitStateKey, state)
+	}
+
+	if !bucket.limit.Unlimited() {
+		decision, err := store.Take(ctx, bucket.key, bucket.limit)
+		switch {
+		case err != nil:
+			logging.FromContext(ctx, slog.Default()).Warn("Rate limit store unavailable, allowing request", "error", err)
+		case !decision.Allowed:
+			state.tightest = &decision
+			refund(ctx, store, state.taken)
+			state.taken = nil
+		default:
+			state.taken = append(state.taken, bucket)
+			if state.tightest == nil || tighter(decision, *state.tightest) {
+				state.tightest = &decision
+			}
+		}
+	}
+
+	tightest := state.tightest
+	if tightest == nil {
+		return next(c)
+	}
+
+	header := c.Response().Header()
+	header.Set(RateLimitLimitHeader, strconv.Itoa(tightest.Limit))
+	header.Set(RateLimitRemainingHeader, strconv.Itoa(tightest.Remaining))
+	header.Set(RateLimitResetHeader, strconv.Itoa(ceilSeconds(tightest.ResetAfter)))
+
+	if !tightest.Allowed {
+		retryAfter := max(ceilSeconds(tightest.RetryAfter), 1)
+		header.Set("Retry-After", strconv.Itoa(retryAfter))
+		return c.JSON(http.StatusTooManyRequests, models.APIResponse{
+			Code:    429,
+			Type:    "error",
+			Message: fmt.Sprintf("Rate limit exceeded, retry in %d seconds", retryAfter),
+		})
+	}
+
+	return next(c)
+}
+
+// refund puts back the tokens a denied request took from buckets
+func refund(ctx context.Context, store ratelimit.Store, buckets []rateBucket) {
+	for _, bucket := range buckets {
+		if err := store.Refund(ctx, bucket.key, bucket.limit); err != nil {
+			logging.FromContext(ctx, slog.Default()).Warn("Rate limit refund failed", "key", bucket.key, "error", err)
+		}
+	}
+}
+
+// tighter reports whether decision a should be reported over b: denials
+// first, the longest wait among denials, otherwise the fewest tokens left
+func tighter(a, b ratelimit.Decision) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
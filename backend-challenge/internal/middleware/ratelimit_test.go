@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/auth"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/ratelimit"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingRateStore is a rate limit store that is always down
+type failingRateStore struct{}
+
+func (failingRateStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Decision, error) {
+	return ratelimit.Decision{}, errors.New("store down")
+}
+
+func (failingRateStore) Refund(ctx context.Context, key string, limit ratelimit.Limit) error {
+	return errors.New("store down")
+}
+
+func TestRateLimit(t *testing.T) {
+	keys, err := auth.NewKeySet([]auth.APIKey{
+		{Name: "pos", Key: "pos-secret", Scopes: []string{auth.ScopeAll}},
+		{Name: "partner", Key: "partner-secret", Scopes: []string{auth.ScopeAll},
+			RateLimit: ratelimit.Limit{Requests: 5, Period: time.Hour}},
+	})
+	require.NoError(t, err)
+
+	policy := RateLimitPolicy{
+		Group:  "orders",
+		PerKey: ratelimit.Limit{Requests: 2, Period: time.Minute},
+		PerIP:  ratelimit.Limit{Requests: 3, Period: time.Minute},
+	}
+	newServer := func(store ratelimit.Store) *echo.Echo {
+		e := echo.New()
+		e.GET("/order", func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+			RateLimit(store, policy), APIKeyAuth(keys, auth.ScopeReadOrders), RateLimitKey(store, policy))
+		return e
+	}
+	serve := func(e *echo.Echo, apiKey, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/order", nil)
+		req.Header.Set(APIKeyHeader, apiKey)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Per key", func(t *testing.T) {
+		e := newServer(ratelimit.NewMemoryStore())
+
+		rec := serve(e, "pos-secret", "10.0.0.1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get(RateLimitLimitHeader))
+		assert.Equal(t, "1", rec.Header().Get(RateLimitRemainingHeader))
+
+		assert.Equal(t, http.StatusOK, serve(e, "pos-secret", "10.0.0.2").Code)
+
+		rec = serve(e, "pos-secret", "10.0.0.3")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code, "The key limit spans IPs")
+		assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+		assert.Equal(t, "0", rec.Header().Get(RateLimitRemainingHeader))
+		assert.Equal(t, "60", rec.Header().Get(RateLimitResetHeader))
+		assert.JSONEq(t, `{"code":429,"type":"error","message":"Rate limit exceeded, retry in 30 seconds"}`, rec.Body.String())
+	})
+
+	t.Run("Key override", func(t *testing.T) {
+		e := newServer(ratelimit.NewMemoryStore())
+		for i := 0; i < 5; i++ {
+			ip := []string{"10.0.0.1", "10.0.0.2"}[i%2]
+			assert.Equal(t, http.StatusOK, serve(e, "partner-secret", ip).Code, i)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, serve(e, "partner-secret", "10.0.0.3").Code)
+	})
+
+	t.Run("Per IP", func(t *testing.T) {
+		e := newServer(ratelimit.NewMemoryStore())
+		assert.Equal(t, http.StatusOK, serve(e, "pos-secret", "10.0.0.1").Code)
+		assert.Equal(t, http.StatusOK, serve(e, "partner-secret", "10.0.0.1").Code)
+		assert.Equal(t, http.StatusOK, serve(e, "partner-secret", "10.0.0.1").Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(e, "partner-secret", "10.0.0.1").Code, "The IP limit spans keys")
+		assert.Equal(t, http.StatusOK, serve(e, "partner-secret", "10.0.0.2").Code)
+	})
+
+	t.Run("Failed authentication is limited per IP", func(t *testing.T) {
+		e := newServer(ratelimit.NewMemoryStore())
+		for i := 0; i < 3; i++ {
+			rec := serve(e, "guess-"+strconv.Itoa(i), "10.0.0.1")
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, i)
+			assert.Equal(t, strconv.Itoa(2-i), rec.Header().Get(RateLimitRemainingHeader))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, serve(e, "guess-3", "10.0.0.1").Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(e, "pos-secret", "10.0.0.1").Code, "Valid keys share the IP limit")
+		assert.Equal(t, http.StatusUnauthorized, serve(e, "guess-4", "10.0.0.2").Code)
+	})
+
+	t.Run("Denied requests spend no tokens", func(t *testing.T) {
+		e := newServer(ratelimit.NewMemoryStore())
+
+		// Denied by the IP bucket, the key bucket is left alone
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, serve(e, "partner-secret", "10.0.0.1").Code)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, serve(e, "partner-secret", "10.0.0.1").Code)
+		rec := serve(e, "partner-secret", "10.0.0.2")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get(RateLimitRemainingHeader), "Key bucket down 4, not 5")
+
+		// Denied by the key bucket, the IP token is refunded
+		assert.Equal(t, http.StatusOK, serve(e, "pos-secret", "10.0.0.3").Code)
+		assert.Equal(t, http.StatusOK, serve(e, "pos-secret", "10.0.0.3").Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(e, "pos-secret", "10.0.0.3").Code)
+		rec = serve(e, "partner-secret", "10.0.0.3")
+		assert.Equal(t, http.StatusOK, rec.Code, "IP bucket down 3, not 4")
+		assert.Equal(t, "0", rec.Header().Get(RateLimitRemainingHeader))
+	})
+
+	t.Run("Store failure lets requests through", func(t *testing.T) {
+		e := newServer(failingRateStore{})
+		for i := 0; i < 5; i++ {
+			rec := serve(e, "pos-secret", "10.0.0.1")
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Empty(t, rec.Header().Get(RateLimitLimitHeader))
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/requestid"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequestID gives every request an ID: the client's X-Request-ID when it
+// sends a valid one, a generated one otherwise. The ID is echoed in the
+// response header and stored in the request context for handlers, services
+// and repositories, see requestid.FromContext. It must run before
+// RequestLogger so the request log carries the ID.
+func RequestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			id := req.Header.Get(requestid.Header)
+			if !requestid.Valid(id) {
+				id = requestid.New()
+			}
+
+			c.Response().Header().Set(requestid.Header, id)
+			c.SetRequest(req.WithContext(requestid.NewContext(req.Context(), id)))
+			return next(c)
+		}
+	}
+}
+
+// RequestIDSerializer encodes JSON like Echo's default serializer, adding
+// the request ID to every models.APIResponse error body, so the ID a client
+// reports with an error finds its logs and trace
+type RequestIDSerializer struct {
+	echo.DefaultJSONSerializer
+}
+
+// Serialize encodes i, filling in the RequestID of error responses
+func (s RequestIDSerializer) Serialize(c echo.Context, i interface{}, indent string) error {
+	switch resp := i.(type) {
+	case models.APIResponse:
+		i = withRequestID(c, &resp)
+	case *models.APIResponse:
+		if resp != nil {
+			copied := *resp
+			i = withRequestID(c, &copied)
+		}
+	}
+	return s.DefaultJSONSerializer.Serialize(c, i, indent)
+}
+
+// withRequestID sets the RequestID of an error response that has none
+func withRequestID(c echo.Context, resp *models.APIResponse) *models.APIResponse {
+	if resp.Type == "error" && resp.RequestID == "" {
+		resp.RequestID = requestid.FromContext(c.Request().Context())
+	}
+	return resp
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/requestid"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	e := echo.New()
+	e.JSONSerializer = RequestIDSerializer{}
+	e.Use(RequestID())
+
+	var seen string
+	e.GET("/order", func(c echo.Context) error {
+		seen = requestid.FromContext(c.Request().Context())
+		return c.JSON(http.StatusOK, models.Order{ID: "ORD-1"})
+	})
+	e.GET("/missing", func(c echo.Context) error {
+		return c.JSON(http.StatusNotFound, models.APIResponse{Code: 404, Type: "error", Message: "Order not found"})
+	})
+	e.GET("/conflict", func(c echo.Context) error {
+		return c.JSON(http.StatusConflict, &models.APIResponse{Code: 409, Type: "error", Message: "Conflict"})
+	})
+
+	serve := func(path, id string) (*httptest.ResponseRecorder, map[string]any) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if id != "" {
+			req.Header.Set(requestid.Header, id)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return rec, body
+	}
+
+	t.Run("Client ID is kept", func(t *testing.T) {
+		rec, body := serve("/order", "pos-7-42")
+		assert.Equal(t, "pos-7-42", rec.Header().Get(requestid.Header))
+		assert.Equal(t, "pos-7-42", seen, "Handlers get the ID from the context")
+		assert.NotContains(t, body, "requestId", "Only error bodies carry the ID")
+	})
+
+	t.Run("Missing or invalid IDs are replaced", func(t *testing.T) {
+		for _, id := range []string{"", "forged\tid"} {
+			rec, _ := serve("/order", id)
+			generated := rec.Header().Get(requestid.Header)
+			assert.Len(t, generated, 32, "%q", id)
+			assert.Equal(t, generated, seen)
+		}
+	})
+
+	t.Run("Error bodies name the request", func(t *testing.T) {
+		rec, body := serve("/missing", "req-404")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "req-404", body["requestId"])
+		assert.Equal(t, "Order not found", body["message"])
+
+		_, body = serve("/conflict", "")
+		assert.Len(t, body["requestId"], 32, "Pointers too")
+	})
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/requestid"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/tracing"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span for every request, continuing the trace of
+// an incoming W3C traceparent header. Handlers start child spans from the
+// request context with tracing.Start. Sampled requests are logged with
+// their trace_id. Errors are recorded on the span and passed on.
+func Tracing(provider trace.TracerProvider) echo.MiddlewareFunc {
+	tracer := tracing.Tracer(provider)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := tracing.Propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			// Spans are named after the route, so paths with IDs are grouped
+			route := c.Path()
+			name := req.Method
+			if route != "" {
+				name += " " + route
+			}
+			ctx, span := tracer.Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", req.Method),
+					attribute.String("url.path", req.URL.Path),
+					attribute.String("client.address", c.RealIP()),
+				))
+			defer span.End()
+			if route != "" {
+				span.SetAttributes(attribute.String("http.route", route))
+			}
+			if id := requestid.FromContext(ctx); id != "" {
+				span.SetAttributes(attribute.String("request.id", id))
+			}
+			if span.SpanContext().IsSampled() {
+				logging.With(ctx, "trace_id", span.SpanContext().TraceID().String())
+			}
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			if err != nil {
+				span.RecordError(err)
+			}
+
+			status := responseStatus(c, err)
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+			if status >= 500 {
+				span.SetStatus(codes.Error, "")
+			}
+			return err
+		}
+	}
+}
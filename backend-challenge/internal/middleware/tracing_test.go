@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/metrics"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/tracing"
+
+	"github.com/labstack/echo/v4"
+	echomiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestServerChain runs errors through the middleware in the order
+// cmd/api installs it, so every layer sees them
+func TestServerChain(t *testing.T) {
+	provider, exporter := tracing.NewInMemory()
+	reg := metrics.NewRegistry()
+	var logs bytes.Buffer
+
+	e := echo.New()
+	e.JSONSerializer = RequestIDSerializer{}
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.Use(RequestID())
+	e.Use(RequestLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	e.Use(Tracing(provider))
+	e.Use(Metrics(reg))
+	e.Use(echomiddleware.RecoverWithConfig(echomiddleware.RecoverConfig{
+		DisablePrintStack:   true,
+		DisableErrorHandler: true,
+	}))
+	e.GET("/broken", func(c echo.Context) error {
+		return errors.New("database down")
+	})
+	e.GET("/panic", func(c echo.Context) error {
+		panic("nil map")
+	})
+	e.GET("/order/:orderId", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid order ID")
+	})
+
+	tests := []struct {
+		path    string
+		route   string
+		status  int
+		failed  bool
+		message string
+	}{
+		{"/broken", "/broken", http.StatusInternalServerError, true, "database down"},
+		{"/panic", "/panic", http.StatusInternalServerError, true, "nil map"},
+		{"/order/x", "/order/:orderId", http.StatusBadRequest, false, "code=400, message=Invalid order ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			exporter.Reset()
+			logs.Reset()
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			// The error handler wrote one body
+			assert.Equal(t, tt.status, rec.Code)
+			var body models.APIResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+			assert.Equal(t, tt.status, body.Code)
+			assert.NotEmpty(t, body.RequestID)
+			assert.Zero(t, rec.Body.Len(), "Nothing after the error body")
+
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 1)
+			require.Len(t, spans[0].Events, 1, "The error is recorded on the span")
+			assert.Contains(t, spans[0].Events[0].Attributes, attribute.String("exception.message", tt.message))
+			assert.Contains(t, spans[0].Attributes, attribute.Int("http.response.status_code", tt.status))
+			assert.Equal(t, tt.failed, spans[0].Status.Code == codes.Error)
+
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(logs.Bytes(), &record))
+			assert.Equal(t, float64(tt.status), record["status"])
+
+			var buf bytes.Buffer
+			require.NoError(t, reg.WriteText(&buf))
+			samples, err := metrics.ParseText(&buf)
+			require.NoError(t, err)
+			count, ok := metrics.Find(samples, "http_requests_total", "route", tt.route, "status", strconv.Itoa(tt.status))
+			assert.True(t, ok)
+			assert.Equal(t, 1.0, count)
+		})
+	}
+}
+
+func TestTracing(t *testing.T) {
+	provider, exporter := tracing.NewInMemory()
+	var logs bytes.Buffer
+
+	e := echo.New()
+	e.Use(RequestLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	e.Use(Tracing(provider))
+	e.GET("/order/:orderId", func(c echo.Context) error {
+		_, span := tracing.Start(c.Request().Context(), "order.load")
+		span.End()
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/broken", func(c echo.Context) error {
+		return errors.New("database down")
+	})
+
+	t.Run("Continues the caller's trace", func(t *testing.T) {
+		exporter.Reset()
+		logs.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/order/ORD-1", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 2)
+		child, server := spans[0], spans[1]
+
+		assert.Equal(t, "GET /order/:orderId", server.Name, "Named after the route, not the path")
+		assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+		assert.Contains(t, server.Attributes, attribute.String("http.route", "/order/:orderId"))
+		assert.Contains(t, server.Attributes, attribute.Int("http.response.status_code", http.StatusOK))
+		assert.Equal(t, server.SpanContext.SpanID(), child.Parent.SpanID(), "Handlers start child spans")
+
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(logs.Bytes(), &record))
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", record["trace_id"])
+	})
+
+	t.Run("Server errors fail the span", func(t *testing.T) {
+		exporter.Reset()
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/broken", nil))
+
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 1)
+		assert.False(t, spans[0].Parent.IsValid(), "Requests without traceparent start a trace")
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+		assert.Contains(t, spans[0].Attributes, attribute.Int("http.response.status_code", http.StatusInternalServerError))
+		require.Len(t, spans[0].Events, 1, "The error is recorded")
+		assert.Contains(t, spans[0].Events[0].Attributes, attribute.String("exception.message", "database down"))
+	})
+
+	t.Run("Unmatched paths", func(t *testing.T) {
+		exporter.Reset()
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/wp-login.php", nil))
+
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "GET", spans[0].Name)
+		assert.Equal(t, codes.Unset, spans[0].Status.Code, "Client errors are not span errors")
+	})
+}
@@ -1,11 +1,80 @@
 package models
 
+import (
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
+)
+
 // Product represents a food item available for order
 type Product struct {
-	ID       string  `json:"id"`
-	Name     string  `json:"name"`
-	Price    float64 `json:"price"`
-	Category string  `json:"category"`
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Price       money.Money   `json:"price"`
+	Category    string        `json:"category"`
+	Description string        `json:"description,omitempty"`
+	Image       *ProductImage `json:"image,omitempty"`
+	Available   bool          `json:"available"` // In stock, or stock is not tracked
+	Version     int           `json:"version"`   // Bumped by every change, for optimistic concurrency
+}
+
+// Category groups products on the menu
+type Category struct {
+	Slug      string `json:"slug"` // Stable identifier, e.g. "hot-drinks"
+	Name      string `json:"name"`
+	SortOrder int    `json:"sortOrder"` // Categories are listed by ascending sort order
+	Icon      string `json:"icon,omitempty"`
+}
+
+// ProductImage holds image URLs of a product for each screen size
+type ProductImage struct {
+	Thumbnail string `json:"thumbnail,omitempty"`
+	Mobile    string `json:"mobile,omitempty"`
+	Tablet    string `json:"tablet,omitempty"`
+	Desktop   string `json:"desktop,omitempty"`
+}
+
+// ProductPatch represents a partial product update; omitted fields are kept
+type ProductPatch struct {
+	Name        *string       `json:"name,omitempty"`
+	Price       *money.Money  `json:"price,omitempty"`
+	Category    *string       `json:"category,omitempty"`
+	Description *string       `json:"description,omitempty"`
+	Image       *ProductImage `json:"image,omitempty"` // Replaces every image URL
+	Version     int           `json:"version"`         // Version the change is based on
+}
+
+// StockLevel represents the stock of a product
+type StockLevel struct {
+	ProductID string `json:"productId"`
+	Name      string `json:"name"`
+	Tracked   bool   `json:"tracked"` // False until first restocked or set; untracked stock is unlimited
+	Stock     int    `json:"stock"`   // Units left, zero when untracked
+}
+
+// RestockRequest represents the request body for restocking a product
+type RestockRequest struct {
+	Quantity int `json:"quantity"` // Units added to the stock
+}
+
+// SetStockRequest represents the request body for setting a product's stock
+type SetStockRequest struct {
+	Stock *int `json:"stock"` // Units in stock, required
+}
+
+// StockShortage represents an order item with too little stock
+type StockShortage struct {
+	ProductID string `json:"productId"`
+	Requested int    `json:"requested"` // Units ordered
+	Available int    `json:"available"` // Units left in stock
+}
+
+// MediaUpload represents an uploaded media file
+type MediaUpload struct {
+	URL         string `json:"url"` // Path the file is served at
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
 }
 
 // OrderItem represents an item in an order
@@ -17,14 +86,35 @@ type OrderItem struct {
 // OrderRequest represents the request body for placing an order
 type OrderRequest struct {
 	CouponCode string      `json:"couponCode,omitempty"`
+	CustomerID string      `json:"customerId,omitempty"` // Needed for codes limited per customer
 	Items      []OrderItem `json:"items"`
 }
 
 // Order represents a completed order
 type Order struct {
-	ID       string      `json:"id"`
-	Items    []OrderItem `json:"items"`
-	Products []Product   `json:"products"`
+	ID         string         `json:"id"`
+	Items      []OrderItem    `json:"items"`
+	Products   []Product      `json:"products"`
+	CouponCode string         `json:"couponCode,omitempty"`
+	CustomerID string         `json:"customerId,omitempty"`
+	Subtotal   money.Money    `json:"subtotal"`  // Sum of item prices before discounts
+	Discounts  []DiscountLine `json:"discounts"` // Applied discounts, empty if none
+	Total      money.Money    `json:"total"`     // Amount to pay
+	CreatedAt  time.Time      `json:"createdAt"`
+}
+
+// OrderList represents a page of orders
+type OrderList struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"nextCursor,omitempty"` // Pass as "after" to get the next page
+}
+
+// DiscountLine represents a discount applied to an order
+type DiscountLine struct {
+	Code        string      `json:"code"`        // Promo code that granted the discount
+	Rule        string      `json:"rule"`        // Discount rule name
+	Description string      `json:"description"` // Human readable explanation
+	Amount      money.Money `json:"amount"`      // Amount taken off the subtotal
 }
 
 // APIResponse represents a standard API error response
@@ -32,8 +122,29 @@ type APIResponse struct {
 	Code    int    `json:"code"`
 	Type    string `json:"type"`
 	Message string `json:"message"`
+	Reason  string `json:"reason,omitempty"` // Machine readable cause, e.g. why a promo code was rejected
+
+	// Shortages lists the order items that are out of stock
+	Shortages []StockShortage `json:"shortages,omitempty"`
+
+	// RequestID is the X-Request-ID of the failed request, filled in for
+	// errors by middleware.RequestIDSerializer
+	RequestID string `json:"requestId,omitempty"`
 }
 
+// Reasons a promo code is rejected with, see APIResponse.Reason
+const (
+	ReasonPromoInvalid     = "invalid"
+	ReasonPromoNotStarted  = "not_started"
+	ReasonPromoExpired     = "expired"
+	ReasonPromoExhausted   = "exhausted"
+	ReasonPromoAlreadyUsed = "already_used"
+	ReasonCustomerRequired = "customer_required"
+)
+
+// ReasonOutOfStock is the reason of orders rejected for lack of stock
+const ReasonOutOfStock = "out_of_stock"
+
 // HealthResponse represents the health check response
 type HealthResponse struct {
 	Status      string             `json:"status"`      // "healthy", "degraded", "starting"
@@ -44,9 +155,76 @@ type HealthResponse struct {
 
 // PromoServiceStatus represents detailed promo service status
 type PromoServiceStatus struct {
-	Status        string `json:"status"`              // "initializing", "loading", "ready"
-	DataSource    string `json:"dataSource"`          // "none", "mock", "remote"
-	CodesLoaded   int    `json:"codesLoaded"`         // Number of codes available
-	IsFullyLoaded bool   `json:"isFullyLoaded"`       // True when real codes loaded
-	LastError     string `json:"lastError,omitempty"` // Last error if any
+	Status        string            `json:"status"`              // "initializing", "loading", "degraded", "ready"
+	DataSource    string            `json:"dataSource"`          // "none", "mock", "snapshot", "remote"
+	CodesLoaded   int               `json:"codesLoaded"`         // Number of codes available
+	IsFullyLoaded bool              `json:"isFullyLoaded"`       // True when real codes loaded
+	LastError     string            `json:"lastError,omitempty"` // Last error if any
+	Policy        PromoPolicyStatus `json:"policy"`              // Validity rule in effect
+}
+
+// PromoPolicyStatus represents the promo validity rule and last load outcome
+type PromoPolicyStatus struct {
+	MinWeight     int            `json:"minWeight"`     // Summed file weight a code needs
+	PartialPolicy string         `json:"partialPolicy"` // "fail_closed", "snapshot", "degrade"
+	SourceWeights map[string]int `json:"sourceWeights"` // Weight of each source's files
+	FilesLoaded   int            `json:"filesLoaded"`   // Files used by the last load
+	FilesFailed   int            `json:"filesFailed"`   // Files the last load could not use
+	Partial       bool           `json:"partial"`       // True when the last load missed files
+}
+
+// PromoDownloads represents the download progress of a promo code load
+type PromoDownloads struct {
+	Loading   bool               `json:"loading"`   // True while a load runs
+	Downloads []DownloadProgress `json:"downloads"` // One entry per remote coupon file
+}
+
+// DownloadProgress represents the progress of one coupon file download
+type DownloadProgress struct {
+	Source         string     `json:"source"`         // Source URL
+	File           string     `json:"file"`           // Local file name
+	State          string     `json:"state"`          // "downloading", "complete", "cached", "failed"
+	BytesComplete  int64      `json:"bytesComplete"`  // Bytes downloaded, resumed bytes included
+	Size           int64      `json:"size"`           // Total bytes, 0 or less when unknown
+	Percent        float64    `json:"percent"`        // 0-100, 0 when the size is unknown
+	BytesPerSecond float64    `json:"bytesPerSecond"` // Recent transfer rate
+	ETA            *time.Time `json:"eta,omitempty"`  // Estimated completion
+	Resumed        bool       `json:"resumed"`        // True when an interrupted download continued
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	Error          string     `json:"error,omitempty"` // Why the download failed
+}
+
+// PromoLoadErrors represents the last promo load error and recent failures
+type PromoLoadErrors struct {
+	LastError *PromoLoadError  `json:"lastError"` // Null when the last load succeeded
+	History   []PromoLoadError `json:"history"`   // Recent failed loads, newest first
+}
+
+// PromoLoadError represents one failed or partial promo code load
+type PromoLoadError struct {
+	At          time.Time `json:"at"`
+	DurationMs  int64     `json:"durationMs"`
+	Error       string    `json:"error"`
+	Partial     bool      `json:"partial"` // True when some files loaded
+	FilesLoaded int       `json:"filesLoaded"`
+	FilesFailed int       `json:"filesFailed"`
+}
+
+// PromoCodeCheck represents why a promo code is valid or not
+type PromoCodeCheck struct {
+	Code       string          `json:"code"`
+	Valid      bool            `json:"valid"`               // Accepted by the codes being served
+	DataSource string          `json:"dataSource"`          // "none", "mock", "snapshot", "remote"
+	MinWeight  int             `json:"minWeight"`           // Summed file weight a code needs
+	Weight     int             `json:"weight"`              // Summed weight of the files containing it
+	FoundIn    []PromoCodeFile `json:"foundIn"`             // Files of the last load containing it
+	Unchecked  []PromoCodeFile `json:"unchecked,omitempty"` // Files no longer on disk
+}
+
+// PromoCodeFile represents a coupon file of the last load
+type PromoCodeFile struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Weight int    `json:"weight"`
 }
@@ -2,11 +2,12 @@ package models
 
 import (
 	"compress/gzip"
-	"io"
 	"log"
 	"net/http"
 	"strings"
 	"sync"
+
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/utils"
 )
 
 // PromoCodeService handles promo code validation
@@ -84,26 +85,22 @@ func (p *PromoCodeService) downloadAndProcessCouponFile(url string) (map[string]
 	}
 	defer gzReader.Close()
 
-	// Read content
-	content, err := io.ReadAll(gzReader)
-	if err != nil {
-		return nil, err
-	}
-
 	// Extract potential promo codes (8-10 character alphanumeric strings)
 	codes := make(map[string]bool)
-	text := string(content)
-	words := strings.Fields(text)
+	scanner := utils.NewCouponScanner(gzReader)
 
-	for _, word := range words {
-		// Clean word (remove punctuation)
-		cleaned := strings.ToUpper(strings.Trim(word, ".,!?;:\"'()[]{}"))
+	for scanner.Scan() {
+		// Scanner returns words cleaned of punctuation and uppercased
+		cleaned := scanner.Text()
 
 		// Check if it's a valid promo code format
 		if len(cleaned) >= 8 && len(cleaned) <= 10 && isAlphanumeric(cleaned) {
 			codes[cleaned] = true
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
 
 	return codes, nil
 }
@@ -0,0 +1,162 @@
+// Package pricing computes order totals and applies promo code discounts.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
+)
+
+// Item is one order line as seen by discount rules
+type Item struct {
+	ProductID string
+	Name      string
+	UnitPrice money.Money
+	Quantity  int
+}
+
+// Discount is one discount line of a priced order
+type Discount struct {
+	Code        string      // Promo code that triggered the rule
+	Rule        string      // Name of the applied rule
+	Description string      // Human readable explanation
+	Amount      money.Money // Amount taken off the subtotal
+}
+
+// Quote is the price breakdown of an order
+type Quote struct {
+	Subtotal  money.Money
+	Discounts []Discount
+	Total     money.Money
+}
+
+// DiscountRule computes the discount a rule grants on a set of items
+type DiscountRule interface {
+	// Name identifies the rule in code mappings and discount lines
+	Name() string
+	// Description explains the rule to customers
+	Description() string
+	// Discount returns the amount off, zero when the rule does not apply
+	Discount(items []Item) (money.Money, error)
+}
+
+// DefaultCodeRules maps the published promo codes to their rules
+var DefaultCodeRules = map[string]string{
+	"HAPPYHOURS": "happy_hours",
+	"BUYGETONE":  "buy_get_one",
+}
+
+// Engine prices orders and applies the rule mapped to a promo code
+type Engine struct {
+	mu    sync.RWMutex
+	rules map[string]DiscountRule
+	codes map[string]string // Promo code -> rule name
+}
+
+// NewEngine creates an engine with the built-in rules and DefaultCodeRules
+func NewEngine() *Engine {
+	e := &Engine{
+		rules: make(map[string]DiscountRule),
+		codes: make(map[string]string),
+	}
+
+	e.Register(NewPercentOff("happy_hours", 18))
+	e.Register(NewCheapestItemFree("buy_get_one"))
+	for code, rule := range DefaultCodeRules {
+		// Built-in rules are registered above, so the mapping cannot fail
+		_ = e.MapCode(code, rule)
+	}
+	return e
+}
+
+// Register adds a rule, replacing any rule with the same name
+func (e *Engine) Register(rule DiscountRule) {
+	e.mu.Lock()
+	e.rules[rule.Name()] = rule
+	e.mu.Unlock()
+}
+
+// MapCode makes a promo code apply the named rule
+func (e *Engine) MapCode(code, ruleName string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.rules[ruleName]; !ok {
+		return fmt.Errorf("unknown discount rule %q for code %s", ruleName, code)
+	}
+	e.codes[strings.ToUpper(code)] = ruleName
+	return nil
+}
+
+// RuleFor returns the rule mapped to a promo code
+func (e *Engine) RuleFor(code string) (DiscountRule, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	rule, ok := e.rules[e.codes[strings.ToUpper(code)]]
+	return rule, ok
+}
+
+// Price computes the subtotal, the discount of the rule mapped to code, if
+// any, and the total. An empty or unmapped code gives no discount.
+// Items priced in different currencies fail with money.ErrCurrencyMismatch.
+func (e *Engine) Price(items []Item, code string) (Quote, error) {
+	subtotal, err := Subtotal(items)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	quote := Quote{Subtotal: subtotal, Discounts: []Discount{}, Total: subtotal}
+
+	rule, ok := e.RuleFor(code)
+	if !ok {
+		return quote, nil
+	}
+
+	amount, err := rule.Discount(items)
+	if err != nil {
+		return Quote{}, fmt.Errorf("discount rule %s: %w", rule.Name(), err)
+	}
+
+	// A discount never takes the total below zero
+	if cmp, err := amount.Cmp(subtotal); err != nil {
+		return Quote{}, err
+	} else if cmp > 0 {
+		amount = subtotal
+	}
+	if !amount.IsPositive() {
+		return quote, nil
+	}
+
+	quote.Total, _ = subtotal.Sub(amount) // Same currency, checked by Cmp
+	quote.Discounts = append(quote.Discounts, Discount{
+		Code:        strings.ToUpper(code),
+		Rule:        rule.Name(),
+		Description: rule.Description(),
+		Amount:      amount,
+	})
+	return quote, nil
+}
+
+// Subtotal sums unit price times quantity over items. Sums too large for
+// money fail with money.ErrOverflow.
+func Subtotal(items []Item) (money.Money, error) {
+	var subtotal money.Money
+	for _, item := range items {
+		line, err := item.UnitPrice.Mul(int64(item.Quantity))
+		if err != nil {
+			return money.Money{}, fmt.Errorf("order total %w", err)
+		}
+		subtotal, err = subtotal.Add(line)
+		if errors.Is(err, money.ErrOverflow) {
+			return money.Money{}, fmt.Errorf("order total %w", err)
+		}
+		if err != nil {
+			return money.Money{}, err
+		}
+	}
+	return subtotal, nil
+}
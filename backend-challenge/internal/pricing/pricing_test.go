@@ -0,0 +1,110 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Price(t *testing.T) {
+	engine := NewEngine()
+	items := []Item{
+		{ProductID: "1", Name: "Chicken Waffle", UnitPrice: usd("12.99"), Quantity: 2},
+		{ProductID: "2", Name: "Belgian Waffle", UnitPrice: usd("10.99"), Quantity: 1},
+	}
+
+	tests := []struct {
+		name      string
+		code      string
+		subtotal  string
+		discounts []Discount
+		total     string
+	}{
+		{"No code", "", "36.97", []Discount{}, "36.97"},
+		{"Unmapped code", "FIFTYOFF", "36.97", []Discount{}, "36.97"},
+		{"HAPPYHOURS", "HAPPYHOURS", "36.97",
+			[]Discount{{Code: "HAPPYHOURS", Rule: "happy_hours", Description: "18% off the order", Amount: usd("6.65")}}, "30.32"},
+		{"Lowercase code", "happyhours", "36.97",
+			[]Discount{{Code: "HAPPYHOURS", Rule: "happy_hours", Description: "18% off the order", Amount: usd("6.65")}}, "30.32"},
+		{"BUYGETONE", "BUYGETONE", "36.97",
+			[]Discount{{Code: "BUYGETONE", Rule: "buy_get_one", Description: "Lowest priced item free", Amount: usd("10.99")}}, "25.98"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := engine.Price(items, tt.code)
+			require.NoError(t, err)
+			assert.Equal(t, usd(tt.subtotal), quote.Subtotal)
+			assert.Equal(t, tt.discounts, quote.Discounts)
+			assert.Equal(t, usd(tt.total), quote.Total)
+		})
+	}
+}
+
+func TestEngine_PriceAvoidsFloatDrift(t *testing.T) {
+	// 0.1 + 0.2 style drift must not leak into totals
+	quote, err := NewEngine().Price([]Item{
+		{UnitPrice: usd("0.10"), Quantity: 1},
+		{UnitPrice: usd("0.20"), Quantity: 1},
+		{UnitPrice: usd("12.99"), Quantity: 3},
+	}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "39.27", quote.Subtotal.Decimal())
+	assert.Equal(t, "39.27", quote.Total.Decimal())
+}
+
+// fixedRule always grants the same amount
+type fixedRule struct{ amount money.Money }
+
+func (r fixedRule) Name() string        { return "fixed" }
+func (r fixedRule) Description() string { return "Fixed amount off" }
+func (r fixedRule) Discount(items []Item) (money.Money, error) {
+	return r.amount, nil
+}
+
+func TestEngine_RegisterAndMapCode(t *testing.T) {
+	engine := NewEngine()
+	assert.Error(t, engine.MapCode("WELCOME1", "missing"), "Mapping needs a registered rule")
+
+	engine.Register(fixedRule{amount: usd("50")})
+	require.NoError(t, engine.MapCode("welcome1", "fixed"))
+
+	rule, ok := engine.RuleFor("WELCOME1")
+	require.True(t, ok)
+	assert.Equal(t, "fixed", rule.Name())
+
+	// A discount never takes the total below zero
+	quote, err := engine.Price([]Item{{UnitPrice: usd("8.99"), Quantity: 1}}, "WELCOME1")
+	require.NoError(t, err)
+	assert.Equal(t, usd("8.99"), quote.Discounts[0].Amount)
+	assert.True(t, quote.Total.IsZero())
+
+	// Codes can be remapped
+	require.NoError(t, engine.MapCode("HAPPYHOURS", "buy_get_one"))
+	rule, _ = engine.RuleFor("HAPPYHOURS")
+	assert.Equal(t, "buy_get_one", rule.Name())
+}
+
+func TestEngine_PriceRejectsOverflow(t *testing.T) {
+	_, err := NewEngine().Price([]Item{
+		{UnitPrice: money.New(math.MaxInt64/2, "USD"), Quantity: 1},
+		{UnitPrice: money.New(math.MaxInt64/2, "USD"), Quantity: 1},
+		{UnitPrice: usd("0.02"), Quantity: 1},
+	}, "")
+	assert.ErrorIs(t, err, money.ErrOverflow, "Sum out of range")
+
+	_, err = NewEngine().Price([]Item{{UnitPrice: usd("12.99"), Quantity: 2000000000000000000}}, "")
+	assert.ErrorIs(t, err, money.ErrOverflow, "Line out of range")
+}
+
+func TestEngine_PriceRejectsMixedCurrencies(t *testing.T) {
+	_, err := NewEngine().Price([]Item{
+		{UnitPrice: usd("5.00"), Quantity: 1},
+		{UnitPrice: money.MustParse("5.00", "AUD"), Quantity: 1},
+	}, "")
+	assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+}
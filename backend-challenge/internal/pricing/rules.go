@@ -0,0 +1,91 @@
+package pricing
+
+import (
+	"fmt"
+
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
+)
+
+// PercentOff takes a percentage off the whole order
+type PercentOff struct {
+	name     string
+	percent  int64
+	rounding money.RoundingMode
+}
+
+// NewPercentOff creates a rule taking percent off the order subtotal,
+// rounded half up to the minor unit
+func NewPercentOff(name string, percent int) *PercentOff {
+	return &PercentOff{
+		name:     name,
+		percent:  int64(min(max(percent, 0), 100)),
+		rounding: money.RoundHalfUp,
+	}
+}
+
+// Name returns the rule name
+func (r *PercentOff) Name() string {
+	return r.name
+}
+
+// Description explains the rule
+func (r *PercentOff) Description() string {
+	return fmt.Sprintf("%d%% off the order", r.percent)
+}
+
+// Discount returns percent of the subtotal
+func (r *PercentOff) Discount(items []Item) (money.Money, error) {
+	subtotal, err := Subtotal(items)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return subtotal.Percent(r.percent, r.rounding)
+}
+
+// CheapestItemFree gives the lowest priced item for free when at least two
+// items are ordered
+type CheapestItemFree struct {
+	name string
+}
+
+// NewCheapestItemFree creates a buy-one-get-one style rule
+func NewCheapestItemFree(name string) *CheapestItemFree {
+	return &CheapestItemFree{name: name}
+}
+
+// Name returns the rule name
+func (r *CheapestItemFree) Name() string {
+	return r.name
+}
+
+// Description explains the rule
+func (r *CheapestItemFree) Description() string {
+	return "Lowest priced item free"
+}
+
+// Discount returns the price of one unit of the cheapest item
+func (r *CheapestItemFree) Discount(items []Item) (money.Money, error) {
+	units := 0
+	var cheapest *money.Money
+	for i := range items {
+		item := &items[i]
+		if item.Quantity <= 0 {
+			continue
+		}
+		units += item.Quantity
+
+		if cheapest == nil {
+			cheapest = &item.UnitPrice
+		} else if cmp, err := item.UnitPrice.Cmp(*cheapest); err != nil {
+			return money.Money{}, err
+		} else if cmp < 0 {
+			cheapest = &item.UnitPrice
+		}
+	}
+
+	// Nothing is free with a single item, there is nothing to buy first
+	if units < 2 {
+		return money.Money{}, nil
+	}
+	return *cheapest, nil
+}
@@ -0,0 +1,77 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// usd parses a USD amount literal
+func usd(amount string) money.Money {
+	return money.MustParse(amount, "USD")
+}
+
+func TestPercentOff_Discount(t *testing.T) {
+	rule := NewPercentOff("happy_hours", 18)
+
+	tests := []struct {
+		name     string
+		items    []Item
+		expected string
+	}{
+		{"Single item", []Item{{UnitPrice: usd("10.00"), Quantity: 1}}, "1.80"},
+		{"Rounds half up", []Item{{UnitPrice: usd("0.25"), Quantity: 1}}, "0.05"},                                       // 4.5 cents
+		{"Rounds down", []Item{{UnitPrice: usd("12.99"), Quantity: 1}}, "2.34"},                                         // 233.82 cents
+		{"Quantities", []Item{{UnitPrice: usd("12.99"), Quantity: 3}}, "7.01"},                                          // 701.46 cents
+		{"Whole order", []Item{{UnitPrice: usd("12.99"), Quantity: 2}, {UnitPrice: usd("10.99"), Quantity: 1}}, "6.65"}, // 665.46 cents
+		{"Empty order", nil, "0.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discount, err := rule.Discount(tt.items)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, discount.Decimal())
+		})
+	}
+
+	assert.Equal(t, "18% off the order", rule.Description())
+	discount, err := NewPercentOff("all", 150).Discount([]Item{{UnitPrice: usd("10"), Quantity: 1}})
+	require.NoError(t, err)
+	assert.Equal(t, usd("10"), discount, "Percent is capped at 100")
+}
+
+func TestCheapestItemFree_Discount(t *testing.T) {
+	rule := NewCheapestItemFree("buy_get_one")
+
+	tests := []struct {
+		name     string
+		items    []Item
+		expected string
+	}{
+		{"Cheapest of several", []Item{{UnitPrice: usd("12.99"), Quantity: 1}, {UnitPrice: usd("6.99"), Quantity: 1}, {UnitPrice: usd("8.99"), Quantity: 2}}, "6.99"},
+		{"One unit free of a quantity", []Item{{UnitPrice: usd("8.99"), Quantity: 3}}, "8.99"},
+		{"Single item gets nothing", []Item{{UnitPrice: usd("8.99"), Quantity: 1}}, "0.00"},
+		{"Zero quantity lines ignored", []Item{{UnitPrice: usd("1.00"), Quantity: 0}, {UnitPrice: usd("5.00"), Quantity: 2}}, "5.00"},
+		{"Empty order", nil, "0.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discount, err := rule.Discount(tt.items)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, discount.Decimal())
+		})
+	}
+}
+
+func TestCheapestItemFree_MixedCurrencies(t *testing.T) {
+	_, err := NewCheapestItemFree("buy_get_one").Discount([]Item{
+		{UnitPrice: usd("5.00"), Quantity: 1},
+		{UnitPrice: money.MustParse("5.00", "AUD"), Quantity: 1},
+	})
+	assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+}
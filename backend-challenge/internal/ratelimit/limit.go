@@ -0,0 +1,121 @@
+// Package ratelimit implements token bucket rate limits.
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket: Burst requests at once, refilled at Requests per
+// Period. The zero Limit is unlimited.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Unlimited reports whether the limit lets every request through
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// rate returns the refill rate in tokens per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// burst returns the bucket size, Requests unless set
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// String formats the limit the way ParseLimit reads it
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "off"
+	}
+
+	var period string
+	switch l.Period {
+	case time.Second:
+		period = "s"
+	case time.Minute:
+		period = "m"
+	case time.Hour:
+		period = "h"
+	default:
+		period = l.Period.String()
+	}
+
+	s := fmt.Sprintf("%d/%s", l.Requests, period)
+	if l.Burst > 0 && l.Burst != l.Requests {
+		s += fmt.Sprintf("#burst=%d", l.Burst)
+	}
+	return s
+}
+
+// ParseLimit reads a limit such as "60/m", "10/s", "500/h" or "5/30s", with
+// an optional "#burst=N" suffix for the bucket size. "off" is unlimited.
+func ParseLimit(spec string) (Limit, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "off" {
+		return Limit{}, nil
+	}
+
+	rest, burstSpec, hasBurst := strings.Cut(spec, "#burst=")
+	requests, period, ok := strings.Cut(rest, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected requests/period", spec)
+	}
+
+	var limit Limit
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", spec)
+	}
+	limit.Requests = n
+
+	switch period = strings.TrimSpace(period); period {
+	case "s":
+		limit.Period = time.Second
+	case "m":
+		limit.Period = time.Minute
+	case "h":
+		limit.Period = time.Hour
+	default:
+		d, err := time.ParseDuration(period)
+		if err != nil || d <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: unknown period %q", spec, period)
+		}
+		limit.Period = d
+	}
+
+	if hasBurst {
+		burst, err := strconv.Atoi(strings.TrimSpace(burstSpec))
+		if err != nil || burst < 1 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", spec)
+		}
+		limit.Burst = burst
+	}
+	return limit, nil
+}
+
+// UnmarshalText lets limits be written as strings in config files
+func (l *Limit) UnmarshalText(text []byte) error {
+	limit, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = limit
+	return nil
+}
+
+// MarshalText writes the limit as ParseLimit reads it
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
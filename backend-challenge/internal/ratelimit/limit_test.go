@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected Limit
+	}{
+		{"60/m", Limit{Requests: 60, Period: time.Minute}},
+		{"10/s", Limit{Requests: 10, Period: time.Second}},
+		{" 500 / h ", Limit{Requests: 500, Period: time.Hour}},
+		{"5/30s", Limit{Requests: 5, Period: 30 * time.Second}},
+		{"60/m#burst=10", Limit{Requests: 60, Period: time.Minute, Burst: 10}},
+		{"off", Limit{}},
+		{"", Limit{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			limit, err := ParseLimit(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, limit)
+
+			again, err := ParseLimit(limit.String())
+			require.NoError(t, err)
+			assert.Equal(t, limit, again, "String round trips")
+		})
+	}
+
+	for _, spec := range []string{"60", "0/m", "-1/s", "ten/m", "10/fortnight", "10/-1s", "10/m#burst=0", "10/m#burst=x"} {
+		_, err := ParseLimit(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestLimit_UnmarshalYAML(t *testing.T) {
+	var out struct {
+		Limit Limit `yaml:"limit"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte("limit: 30/m#burst=5\n"), &out))
+	assert.Equal(t, Limit{Requests: 30, Period: time.Minute, Burst: 5}, out.Limit)
+
+	assert.Error(t, yaml.Unmarshal([]byte("limit: lots\n"), &out))
+}
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/maphash"
+	"math"
+	"sync"
+	"time"
+)
+
+// Decision is the outcome of taking a token from a bucket
+type Decision struct {
+	Allowed    bool
+	Limit      int           // Bucket size
+	Remaining  int           // Whole tokens left after this request
+	RetryAfter time.Duration // Wait until a token is available, zero when allowed
+	ResetAfter time.Duration // Wait until the bucket is full again
+}
+
+// Store keeps the buckets of every rate limited client
+type Store interface {
+	// Take removes one token from the bucket for key, created full on first use
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+	// Refund puts back a token taken from the bucket for key, for requests
+	// that another bucket denied
+	Refund(ctx context.Context, key string, limit Limit) error
+}
+
+// memoryShards spreads buckets over independently locked shards so
+// concurrent requests rarely wait on each other
+const memoryShards = 64
+
+// MemoryStore keeps buckets in memory, sharded by key
+type MemoryStore struct {
+	shards [memoryShards]memoryShard
+	seed   maphash.Seed
+	now    func() time.Time
+}
+
+// memoryShard is one locked part of a MemoryStore
+type memoryShard struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket is the token count of one key as of last
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // When the bucket will be full again, for sweeping
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{seed: maphash.MakeSeed(), now: time.Now}
+	for i := range s.shards {
+		s.shards[i].buckets = make(map[string]*bucket)
+	}
+	return s
+}
+
+// Take removes one token from the bucket for key
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	burst := limit.burst()
+	if limit.Unlimited() {
+		return Decision{Allowed: true, Limit: burst, Remaining: burst}, nil
+	}
+
+	shard := &s.shards[maphash.String(s.seed, key)%memoryShards]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	now := s.now()
+	shard.sweep(now)
+
+	rate := limit.rate()
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		shard.buckets[key] = b
+	}
+
+	b.refill(now, rate, burst)
+
+	decision := Decision{Limit: burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	decision.Remaining = int(b.tokens)
+	decision.ResetAfter = secondsToDuration((float64(burst) - b.tokens) / rate)
+	b.full = now.Add(decision.ResetAfter)
+
+	return decision, nil
+}
+
+// Refund puts back a token taken from the bucket for key
+func (s *MemoryStore) Refund(ctx context.Context, key string, limit Limit) error {
+	if limit.Unlimited() {
+		return nil
+	}
+
+	shard := &s.shards[maphash.String(s.seed, key)%memoryShards]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	// A swept bucket is full already
+	b, ok := shard.buckets[key]
+	if !ok {
+		return nil
+	}
+
+	now := s.now()
+	rate, burst := limit.rate(), limit.burst()
+	b.refill(now, rate, burst)
+	b.tokens = math.Min(float64(burst), b.tokens+1)
+	b.full = now.Add(secondsToDuration((float64(burst) - b.tokens) / rate))
+	return nil
+}
+
+// Len returns the number of buckets held
+func (s *MemoryStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		n += len(s.shards[i].buckets)
+		s.shards[i].mu.Unlock()
+	}
+	return n
+}
+
+// sweep drops buckets that have refilled, at most once a minute; callers
+// hold mu. A full bucket behaves exactly like a missing one.
+func (sh *memoryShard) sweep(now time.Time) {
+	if now.Sub(sh.lastSweep) < time.Minute {
+		return
+	}
+	sh.lastSweep = now
+
+	for key, b := range sh.buckets {
+		if !now.Before(b.full) {
+			delete(sh.buckets, key)
+		}
+	}
+}
+
+// refill adds the tokens earned since last, never beyond the bucket size
+func (b *bucket) refill(now time.Time, rate float64, burst int) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(burst), b.tokens+elapsed*rate)
+		b.last = now
+	}
+}
+
+// secondsToDuration converts fractional seconds to a duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 60, Period: time.Minute, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		d, err := store.Take(ctx, "key:pos", limit)
+		require.NoError(t, err)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, 3, d.Limit)
+		assert.Equal(t, i, d.Remaining)
+	}
+
+	d, err := store.Take(ctx, "key:pos", limit)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed, "Burst used up")
+	assert.Equal(t, time.Second, d.RetryAfter)
+	assert.Equal(t, 3*time.Second, d.ResetAfter)
+
+	d, err = store.Take(ctx, "key:other", limit)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed, "Buckets are per key")
+
+	// One token per second refills
+	now = now.Add(time.Second)
+	d, err = store.Take(ctx, "key:pos", limit)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 0, d.Remaining)
+
+	// Refill stops at the burst size
+	now = now.Add(time.Hour)
+	d, err = store.Take(ctx, "key:pos", limit)
+	require.NoError(t, err)
+	assert.Equal(t, 2, d.Remaining)
+}
+
+func TestMemoryStore_Refund(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 60, Period: time.Minute, Burst: 2}
+
+	require.NoError(t, store.Refund(ctx, "key:pos", limit), "Unknown buckets are full")
+	assert.Equal(t, 0, store.Len())
+
+	for i := 0; i < 2; i++ {
+		_, err := store.Take(ctx, "key:pos", limit)
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.Refund(ctx, "key:pos", limit))
+	d, err := store.Take(ctx, "key:pos", limit)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed, "The refunded token is available")
+	assert.Equal(t, 0, d.Remaining)
+
+	// Refunds never overfill the bucket
+	now = now.Add(time.Hour)
+	require.NoError(t, store.Refund(ctx, "key:pos", limit))
+	d, err = store.Take(ctx, "key:pos", limit)
+	require.NoError(t, err)
+	assert.Equal(t, 1, d.Remaining)
+}
+
+func TestMemoryStore_Unlimited(t *testing.T) {
+	store := NewMemoryStore()
+	for i := 0; i < 100; i++ {
+		d, err := store.Take(context.Background(), "ip:1.2.3.4", Limit{})
+		require.NoError(t, err)
+		assert.True(t, d.Allowed)
+	}
+	assert.Zero(t, store.Len(), "Unlimited requests keep no state")
+}
+
+func TestMemoryStore_SweepsFullBuckets(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	for i := 0; i < 200; i++ {
+		_, err := store.Take(ctx, fmt.Sprintf("ip:%d", i), Limit{Requests: 10, Period: time.Second})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 200, store.Len())
+
+	// Enough new keys to reach every shard
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < 5000; i++ {
+		_, err := store.Take(ctx, fmt.Sprintf("ip:%d", i+1000), Limit{Requests: 10, Period: time.Second})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 5000, store.Len(), "Refilled buckets are dropped")
+}
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 100, Period: time.Hour}
+
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				d, err := store.Take(context.Background(), "key:shared", limit)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if d.Allowed {
+					allowed.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(100), allowed.Load(), "Exactly the burst is allowed")
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+)
+
+var (
+	// ErrCategoryNotFound is returned when no category has the requested slug
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrInvalidCategory wraps validation failures of a category
+	ErrInvalidCategory = errors.New("invalid category")
+)
+
+// MaxCategorySlugLength bounds category slugs
+const MaxCategorySlugLength = 50
+
+// slugPattern matches lowercase words joined by dashes, e.g. "hot-drinks"
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ValidateCategory checks the fields of a category, returning an error
+// wrapping ErrInvalidCategory
+func ValidateCategory(c models.Category) error {
+	switch {
+	case !slugPattern.MatchString(c.Slug) || len(c.Slug) > MaxCategorySlugLength:
+		return fmt.Errorf("%w: slug must be lowercase words joined by dashes, got %q", ErrInvalidCategory, c.Slug)
+	case strings.TrimSpace(c.Name) == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidCategory)
+	case utf8.RuneCountInString(c.Name) > MaxProductCategoryLength:
+		return fmt.Errorf("%w: name must be at most %d characters", ErrInvalidCategory, MaxProductCategoryLength)
+	case c.Icon != "" && !isImageURL(c.Icon):
+		return fmt.Errorf("%w: icon must be an http(s) URL or an absolute path", ErrInvalidCategory)
+	}
+	return nil
+}
+
+// Slugify derives a slug from a category name, e.g. "Hot Drinks" to "hot-drinks"
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// DefaultCategories returns the categories of DefaultProducts
+func DefaultCategories() []models.Category {
+	return []models.Category{
+		{Slug: "waffle", Name: "Waffle", SortOrder: 10},
+		{Slug: "pancake", Name: "Pancake", SortOrder: 20},
+		{Slug: "toast", Name: "Toast", SortOrder: 30},
+		{Slug: "salad", Name: "Salad", SortOrder: 40},
+		{Slug: "burger", Name: "Burger", SortOrder: 50},
+		{Slug: "main", Name: "Main", SortOrder: 60},
+		{Slug: "dessert", Name: "Dessert", SortOrder: 70},
+	}
+}
+
+// deriveCategories makes a category of every distinct product category,
+// ordered as they first appear, for catalogs predating categories
+func deriveCategories(products []models.Product) []models.Category {
+	var categories []models.Category
+	seen := make(map[string]bool)
+	for _, p := range products {
+		slug := Slugify(p.Category)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		categories = append(categories, models.Category{
+			Slug:      slug,
+			Name:      strings.TrimSpace(p.Category),
+			SortOrder: (len(categories) + 1) * 10,
+		})
+	}
+	return categories
+}
+
+// categorySet is an immutable, indexed set of categories
+type categorySet struct {
+	ordered []models.Category // Display order
+	bySlug  map[string]int
+	byName  map[string]int // Lowercased name
+}
+
+// newCategorySet validates categories; slugs and names must be unique
+func newCategorySet(categories []models.Category) (*categorySet, error) {
+	s := &categorySet{
+		ordered: slices.Clone(categories),
+		bySlug:  make(map[string]int, len(categories)),
+		byName:  make(map[string]int, len(categories)),
+	}
+
+	// Display order is the sort order, then the name
+	slices.SortStableFunc(s.ordered, func(a, b models.Category) int {
+		return cmp.Or(cmp.Compare(a.SortOrder, b.SortOrder), cmp.Compare(a.Name, b.Name))
+	})
+
+	for i, c := range s.ordered {
+		if err := ValidateCategory(c); err != nil {
+			return nil, fmt.Errorf("category %q: %w", c.Slug, err)
+		}
+		name := strings.ToLower(strings.TrimSpace(c.Name))
+		if _, exists := s.bySlug[c.Slug]; exists {
+			return nil, fmt.Errorf("duplicate category slug %s", c.Slug)
+		}
+		if _, exists := s.byName[name]; exists {
+			return nil, fmt.Errorf("duplicate category name %q", c.Name)
+		}
+		s.bySlug[c.Slug] = i
+		s.byName[name] = i
+	}
+	return s, nil
+}
+
+// get returns the category with the given slug
+func (s *categorySet) get(slug string) (models.Category, bool) {
+	i, ok := s.bySlug[slug]
+	if !ok {
+		return models.Category{}, false
+	}
+	return s.ordered[i], true
+}
+
+// resolve finds the category a product refers to by name or slug, ignoring case
+func (s *categorySet) resolve(ref string) (models.Category, bool) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	if i, ok := s.byName[ref]; ok {
+		return s.ordered[i], true
+	}
+	if i, ok := s.bySlug[ref]; ok {
+		return s.ordered[i], true
+	}
+	return models.Category{}, false
+}
+
+// checkProduct points a product's category at its canonical name. It fails
+// with ErrInvalidProduct for unknown categories.
+func (s *categorySet) checkProduct(p *models.Product) error {
+	category, ok := s.resolve(p.Category)
+	if !ok {
+		return fmt.Errorf("%w: unknown category %q", ErrInvalidProduct, p.Category)
+	}
+	p.Category = category.Name
+	return nil
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateCategory(t *testing.T) {
+	valid := models.Category{Slug: "hot-drinks", Name: "Hot Drinks", SortOrder: 10, Icon: "/media/cup.png"}
+
+	tests := []struct {
+		name   string
+		modify func(*models.Category)
+		valid  bool
+	}{
+		{"Valid", func(c *models.Category) {}, true},
+		{"Without icon", func(c *models.Category) { c.Icon = "" }, true},
+		{"Negative sort order", func(c *models.Category) { c.SortOrder = -5 }, true},
+		{"Empty slug", func(c *models.Category) { c.Slug = "" }, false},
+		{"Uppercase slug", func(c *models.Category) { c.Slug = "Hot-Drinks" }, false},
+		{"Slug with spaces", func(c *models.Category) { c.Slug = "hot drinks" }, false},
+		{"Slug with trailing dash", func(c *models.Category) { c.Slug = "hot-" }, false},
+		{"Long slug", func(c *models.Category) { c.Slug = strings.Repeat("a", MaxCategorySlugLength+1) }, false},
+		{"Empty name", func(c *models.Category) { c.Name = " " }, false},
+		{"Long name", func(c *models.Category) { c.Name = strings.Repeat("a", MaxProductCategoryLength+1) }, false},
+		{"Relative icon", func(c *models.Category) { c.Icon = "cup.png" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.modify(&c)
+			err := ValidateCategory(c)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidCategory)
+			}
+		})
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Waffle":        "waffle",
+		"Hot Drinks":    "hot-drinks",
+		" Fish & Chips": "fish-chips",
+		"Kids' Menu 2":  "kids-menu-2",
+		"Crêpes":        "cr-pes",
+		"---":           "",
+	}
+	for name, expected := range tests {
+		assert.Equal(t, expected, Slugify(name), name)
+	}
+}
+
+func TestNewCategorySet(t *testing.T) {
+	set, err := newCategorySet([]models.Category{
+		{Slug: "dessert", Name: "Dessert", SortOrder: 20},
+		{Slug: "main", Name: "Main", SortOrder: 10},
+		{Slug: "drinks", Name: "Drinks", SortOrder: 20},
+	})
+	require.NoError(t, err)
+	var slugs []string
+	for _, c := range set.ordered {
+		slugs = append(slugs, c.Slug)
+	}
+	assert.Equal(t, []string{"main", "dessert", "drinks"}, slugs, "Sort order, then name")
+
+	for _, ref := range []string{"Dessert", "dessert", " DESSERT "} {
+		c, ok := set.resolve(ref)
+		assert.True(t, ok, ref)
+		assert.Equal(t, "Dessert", c.Name, ref)
+	}
+	_, ok := set.resolve("Side")
+	assert.False(t, ok)
+
+	_, err = newCategorySet([]models.Category{{Slug: "main", Name: "Main"}, {Slug: "main", Name: "Mains"}})
+	assert.Error(t, err, "Duplicate slug")
+	_, err = newCategorySet([]models.Category{{Slug: "main", Name: "Main"}, {Slug: "mains", Name: "main"}})
+	assert.Error(t, err, "Duplicate name")
+	_, err = newCategorySet([]models.Category{{Slug: "Main", Name: "Main"}})
+	assert.ErrorIs(t, err, ErrInvalidCategory)
+}
+
+func TestMemoryProductRepository_Categories(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewMemoryProductRepository(DefaultCategories(), DefaultProducts())
+	require.NoError(t, err)
+
+	categories, err := repo.Categories(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultCategories(), categories)
+
+	category, err := repo.Category(ctx, "burger")
+	require.NoError(t, err)
+	assert.Equal(t, "Burger", category.Name)
+	_, err = repo.Category(ctx, "Burger")
+	assert.ErrorIs(t, err, ErrCategoryNotFound)
+
+	// Listings filter by name or slug
+	page, err := repo.List(ctx, ProductQuery{Category: "waffle"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, productIDs(page.Products))
+
+	// Products must reference an existing category
+	_, err = repo.Create(ctx, models.Product{Name: "Latte", Price: money.New(400, "USD"), Category: "Drinks"})
+	assert.ErrorIs(t, err, ErrInvalidProduct)
+	product, err := repo.Get(ctx, "1")
+	require.NoError(t, err)
+	product.Category = "Drinks"
+	_, err = repo.Update(ctx, product)
+	assert.ErrorIs(t, err, ErrInvalidProduct)
+
+	_, err = NewMemoryProductRepository([]models.Category{{Slug: "waffle", Name: "Waffle"}}, DefaultProducts())
+	assert.ErrorIs(t, err, ErrInvalidProduct, "Catalogs may not hold products of unknown categories")
+}
+
+func TestMemoryProductRepository_DerivesCategories(t *testing.T) {
+	repo, err := NewMemoryProductRepository(nil, []models.Product{
+		{ID: "1", Name: "Tea", Price: money.New(300, "USD"), Category: "Hot Drinks"},
+		{ID: "2", Name: "Cake", Price: money.New(500, "USD"), Category: "Dessert"},
+		{ID: "3", Name: "Coffee", Price: money.New(350, "USD"), Category: "hot drinks"},
+	})
+	require.NoError(t, err)
+
+	categories, err := repo.Categories(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []models.Category{
+		{Slug: "hot-drinks", Name: "Hot Drinks", SortOrder: 10},
+		{Slug: "dessert", Name: "Dessert", SortOrder: 20},
+	}, categories)
+
+	coffee, err := repo.Get(context.Background(), "3")
+	require.NoError(t, err)
+	assert.Equal(t, "Hot Drinks", coffee.Category, "Categories are normalized to their name")
+}
+
+func TestFileProductRepository_Categories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "menu.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`categories:
+  - slug: drinks
+    name: Drinks
+    sortOrder: 2
+    icon: /media/cup.png
+  - slug: food
+    name: Food
+    sortOrder: 1
+products:
+  - id: "1"
+    name: Tea
+    price: 3
+    category: drinks
+`), 0o600))
+
+	repo, err := NewFileProductRepository(path)
+	require.NoError(t, err)
+	categories, err := repo.Categories(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []models.Category{
+		{Slug: "food", Name: "Food", SortOrder: 1},
+		{Slug: "drinks", Name: "Drinks", SortOrder: 2, Icon: "/media/cup.png"},
+	}, categories)
+
+	// Categories are written back with the products
+	_, err = repo.Create(context.Background(), models.Product{Name: "Toast", Price: money.New(400, "USD"), Category: "Food"})
+	require.NoError(t, err)
+	reopened, err := NewFileProductRepository(path)
+	require.NoError(t, err)
+	reopenedCategories, err := reopened.Categories(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, categories, reopenedCategories)
+	tea, err := reopened.Get(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, "Drinks", tea.Category)
+
+	unknown := filepath.Join(t.TempDir(), "unknown.yaml")
+	require.NoError(t, os.WriteFile(unknown, []byte(`categories:
+  - {slug: food, name: Food}
+products:
+  - {id: "1", name: Tea, price: 3, category: Drinks}
+`), 0o600))
+	_, err = NewFileProductRepository(unknown)
+	assert.ErrorIs(t, err, ErrInvalidProduct)
+}
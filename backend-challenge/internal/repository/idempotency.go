@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// IdempotencyRecord is what is remembered about a request made with an
+// Idempotency-Key
+type IdempotencyRecord struct {
+	Fingerprint string    // Hash of the request the key was first used with
+	Completed   bool      // False while the first request is still running
+	Status      int       // Response status code
+	ContentType string    // Response Content-Type
+	Body        []byte    // Response body
+	ExpiresAt   time.Time // When the key may be reused
+}
+
+// IdempotencyStore remembers responses by idempotency key
+type IdempotencyStore interface {
+	// Reserve claims a key for a new request. If the key is already in use
+	// it returns the existing record and false instead.
+	Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (IdempotencyRecord, bool, error)
+	// Complete stores the response of a reserved key
+	Complete(ctx context.Context, key string, record IdempotencyRecord) error
+	// Release forgets a reserved key so the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryIdempotencyStore keeps idempotency records in memory
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]IdempotencyRecord
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		records: make(map[string]IdempotencyRecord),
+		now:     time.Now,
+	}
+}
+
+// Reserve claims a key unless an unexpired record exists
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	if record, ok := s.records[key]; ok && now.Before(record.ExpiresAt) {
+		return record, false, nil
+	}
+
+	record := IdempotencyRecord{Fingerprint: fingerprint, ExpiresAt: now.Add(ttl)}
+	s.records[key] = record
+	return record, true, nil
+}
+
+// Complete stores the response of a reserved key, keeping its expiry
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.records[key]; ok {
+		record.Fingerprint = existing.Fingerprint
+		record.ExpiresAt = existing.ExpiresAt
+	}
+	record.Completed = true
+	s.records[key] = record
+	return nil
+}
+
+// Release forgets a key
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.records, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// sweep drops expired records at most once a minute; callers hold mu
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, record := range s.records {
+		if !now.Before(record.ExpiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryIdempotencyStore()
+	store.now = func() time.Time { return now }
+
+	_, reserved, err := store.Reserve(ctx, "key-1", "fp-a", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	// A second request sees the running one
+	record, reserved, err := store.Reserve(ctx, "key-1", "fp-a", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.False(t, record.Completed)
+
+	require.NoError(t, store.Complete(ctx, "key-1", IdempotencyRecord{Status: 200, Body: []byte(`{}`)}))
+	record, reserved, _ = store.Reserve(ctx, "key-1", "fp-b", time.Hour)
+	assert.False(t, reserved)
+	assert.True(t, record.Completed)
+	assert.Equal(t, "fp-a", record.Fingerprint, "The first request's fingerprint is kept")
+	assert.Equal(t, 200, record.Status)
+
+	// Expired keys can be reused
+	now = now.Add(time.Hour)
+	_, reserved, _ = store.Reserve(ctx, "key-1", "fp-b", time.Hour)
+	assert.True(t, reserved)
+
+	// Released keys can be retried
+	require.NoError(t, store.Release(ctx, "key-1"))
+	_, reserved, _ = store.Reserve(ctx, "key-1", "fp-c", time.Hour)
+	assert.True(t, reserved)
+}
+
+func TestMemoryIdempotencyStore_SweepsExpired(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryIdempotencyStore()
+	store.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		store.Reserve(ctx, key, "fp", time.Minute)
+	}
+
+	now = now.Add(2 * time.Minute)
+	store.Reserve(ctx, "d", "fp", time.Minute)
+	assert.Len(t, store.records, 1, "Expired records are dropped")
+}
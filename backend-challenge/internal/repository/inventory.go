@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+)
+
+var (
+	// ErrInsufficientStock is wrapped by StockError
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrInvalidQuantity is returned for quantities below one
+	ErrInvalidQuantity = errors.New("quantity must be positive")
+	// ErrInvalidStock is returned for stock levels below zero
+	ErrInvalidStock = errors.New("stock must not be negative")
+)
+
+// StockError lists the items of a reservation that are short of stock
+type StockError struct {
+	Shortages []models.StockShortage // Ordered by product ID
+}
+
+// Error names the products that are short
+func (e *StockError) Error() string {
+	ids := make([]string, 0, len(e.Shortages))
+	for _, s := range e.Shortages {
+		ids = append(ids, s.ProductID)
+	}
+	return fmt.Sprintf("insufficient stock for products %s", strings.Join(ids, ", "))
+}
+
+// Unwrap makes errors.Is match ErrInsufficientStock
+func (e *StockError) Unwrap() error {
+	return ErrInsufficientStock
+}
+
+// InventoryStore keeps the stock levels of products. Products are only
+// tracked once restocked; until then their stock is unlimited.
+type InventoryStore interface {
+	// Reserve takes the quantities of every item from stock, or nothing if
+	// any item falls short, failing with a *StockError. Checking and taking
+	// happen atomically.
+	Reserve(ctx context.Context, items []models.OrderItem) error
+	// Release puts reserved quantities back into stock
+	Release(ctx context.Context, items []models.OrderItem) error
+	// Restock adds quantity to a product's stock and returns the new level
+	Restock(ctx context.Context, productID string, quantity int) (int, error)
+	// SetStock replaces a product's stock with level, tracking it from then
+	// on, e.g. to correct a count
+	SetStock(ctx context.Context, productID string, level int) error
+	// Untrack stops tracking a product, so its stock is unlimited again
+	Untrack(ctx context.Context, productID string) error
+	// Levels returns the stock of every tracked product by product ID
+	Levels(ctx context.Context) (map[string]int, error)
+}
+
+// MemoryInventoryStore keeps stock levels in memory
+type MemoryInventoryStore struct {
+	mu     sync.Mutex
+	levels map[string]int
+}
+
+// NewMemoryInventoryStore creates a store tracking no products
+func NewMemoryInventoryStore() *MemoryInventoryStore {
+	return &MemoryInventoryStore{levels: make(map[string]int)}
+}
+
+// Reserve takes the quantities of every item from stock, or nothing
+func (s *MemoryInventoryStore) Reserve(ctx context.Context, items []models.OrderItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := sumQuantities(items)
+	if err := checkStock(wanted, func(id string) (int, bool) {
+		level, ok := s.levels[id]
+		return level, ok
+	}); err != nil {
+		return err
+	}
+	for id, quantity := range wanted {
+		if _, tracked := s.levels[id]; tracked {
+			s.levels[id] -= quantity
+		}
+	}
+	return nil
+}
+
+// Release puts reserved quantities back into stock
+func (s *MemoryInventoryStore) Release(ctx context.Context, items []models.OrderItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, quantity := range sumQuantities(items) {
+		if _, tracked := s.levels[id]; tracked {
+			s.levels[id] += quantity
+		}
+	}
+	return nil
+}
+
+// Restock adds quantity to a product's stock
+func (s *MemoryInventoryStore) Restock(ctx context.Context, productID string, quantity int) (int, error) {
+	if quantity < 1 {
+		return 0, ErrInvalidQuantity
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.levels[productID] += quantity
+	return s.levels[productID], nil
+}
+
+// SetStock replaces a product's stock with level
+func (s *MemoryInventoryStore) SetStock(ctx context.Context, productID string, level int) error {
+	if level < 0 {
+		return ErrInvalidStock
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.levels[productID] = level
+	return nil
+}
+
+// Untrack stops tracking a product
+func (s *MemoryInventoryStore) Untrack(ctx context.Context, productID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.levels, productID)
+	return nil
+}
+
+// Levels returns the stock of every tracked product
+func (s *MemoryInventoryStore) Levels(ctx context.Context) (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return maps.Clone(s.levels), nil
+}
+
+// sumQuantities adds up the quantities ordered of each product, so a
+// product listed twice is checked against its stock once
+func sumQuantities(items []models.OrderItem) map[string]int {
+	wanted := make(map[string]int, len(items))
+	for _, item := range items {
+		wanted[item.ProductID] += item.Quantity
+	}
+	return wanted
+}
+
+// checkStock compares wanted quantities with the levels returned by level,
+// which reports false for untracked products
+func checkStock(wanted map[string]int, level func(id string) (int, bool)) error {
+	var shortages []models.StockShortage
+	for id, quantity := range wanted {
+		if available, tracked := level(id); tracked && available < quantity {
+			shortages = append(shortages, models.StockShortage{ProductID: id, Requested: quantity, Available: available})
+		}
+	}
+	if len(shortages) == 0 {
+		return nil
+	}
+	slices.SortFunc(shortages, func(a, b models.StockShortage) int {
+		return compareProducts(models.Product{ID: a.ProductID}, models.Product{ID: b.ProductID})
+	})
+	return &StockError{Shortages: shortages}
+}
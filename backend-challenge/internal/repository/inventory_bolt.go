@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// inventoryBucket maps product IDs to their stock
+var inventoryBucket = []byte("inventory")
+
+// BoltInventoryStore keeps stock levels in the order database, so they
+// survive restarts. bbolt serializes writers, which makes a reservation
+// across every item of an order atomic.
+type BoltInventoryStore struct {
+	db *bolt.DB
+}
+
+// Inventory returns an inventory store sharing the order database
+func (r *BoltOrderRepository) Inventory() *BoltInventoryStore {
+	return &BoltInventoryStore{db: r.db}
+}
+
+// Reserve takes the quantities of every item from stock, or nothing
+func (s *BoltInventoryStore) Reserve(ctx context.Context, items []models.OrderItem) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(inventoryBucket)
+		wanted := sumQuantities(items)
+		if err := checkStock(wanted, func(id string) (int, bool) {
+			level := bucket.Get([]byte(id))
+			return readCount(level), level != nil
+		}); err != nil {
+			return err
+		}
+
+		for id, quantity := range wanted {
+			if level := bucket.Get([]byte(id)); level != nil {
+				if err := bucket.Put([]byte(id), countBytes(readCount(level)-quantity)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Release puts reserved quantities back into stock
+func (s *BoltInventoryStore) Release(ctx context.Context, items []models.OrderItem) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(inventoryBucket)
+		for id, quantity := range sumQuantities(items) {
+			if level := bucket.Get([]byte(id)); level != nil {
+				if err := bucket.Put([]byte(id), countBytes(readCount(level)+quantity)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Restock adds quantity to a product's stock
+func (s *BoltInventoryStore) Restock(ctx context.Context, productID string, quantity int) (int, error) {
+	if quantity < 1 {
+		return 0, ErrInvalidQuantity
+	}
+
+	var level int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(inventoryBucket)
+		level = readCount(bucket.Get([]byte(productID))) + quantity
+		return bucket.Put([]byte(productID), countBytes(level))
+	})
+	return level, err
+}
+
+// SetStock replaces a product's stock with level
+func (s *BoltInventoryStore) SetStock(ctx context.Context, productID string, level int) error {
+	if level < 0 {
+		return ErrInvalidStock
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(inventoryBucket).Put([]byte(productID), countBytes(level))
+	})
+}
+
+// Untrack stops tracking a product
+func (s *BoltInventoryStore) Untrack(ctx context.Context, productID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(inventoryBucket).Delete([]byte(productID))
+	})
+}
+
+// Levels returns the stock of every tracked product
+func (s *BoltInventoryStore) Levels(ctx context.Context) (map[string]int, error) {
+	levels := make(map[string]int)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(inventoryBucket).ForEach(func(id, level []byte) error {
+			levels[string(id)] = readCount(level)
+			return nil
+		})
+	})
+	return levels, err
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testInventoryStore checks the behaviour every InventoryStore shares
+func testInventoryStore(t *testing.T, store InventoryStore) {
+	ctx := context.Background()
+	items := func(pairs ...any) []models.OrderItem {
+		var items []models.OrderItem
+		for i := 0; i < len(pairs); i += 2 {
+			items = append(items, models.OrderItem{ProductID: pairs[i].(string), Quantity: pairs[i+1].(int)})
+		}
+		return items
+	}
+
+	// Untracked products are unlimited
+	require.NoError(t, store.Reserve(ctx, items("1", 1000)))
+	levels, err := store.Levels(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, levels)
+
+	level, err := store.Restock(ctx, "2", 5)
+	require.NoError(t, err)
+	assert.Equal(t, 5, level)
+	level, err = store.Restock(ctx, "3", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, level)
+	_, err = store.Restock(ctx, "3", 0)
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+
+	// A product listed twice counts once against its stock
+	require.NoError(t, store.Reserve(ctx, items("2", 2, "1", 3, "2", 1)))
+
+	// Nothing is taken when any item falls short
+	err = store.Reserve(ctx, items("3", 2, "2", 1, "2", 2))
+	var stockErr *StockError
+	require.ErrorAs(t, err, &stockErr)
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+	assert.Equal(t, []models.StockShortage{
+		{ProductID: "2", Requested: 3, Available: 2},
+		{ProductID: "3", Requested: 2, Available: 1},
+	}, stockErr.Shortages)
+	assert.Equal(t, "insufficient stock for products 2, 3", err.Error())
+
+	levels, err = store.Levels(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"2": 2, "3": 1}, levels)
+
+	// The last units can be taken, then released again
+	require.NoError(t, store.Reserve(ctx, items("2", 2, "3", 1)))
+	assert.ErrorIs(t, store.Reserve(ctx, items("3", 1)), ErrInsufficientStock)
+	require.NoError(t, store.Release(ctx, items("3", 1, "1", 4)))
+	levels, err = store.Levels(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"2": 0, "3": 1}, levels, "Releasing untracked products does not track them")
+
+	// Counts can be corrected downwards, and tracking stopped
+	require.NoError(t, store.SetStock(ctx, "4", 7))
+	require.NoError(t, store.SetStock(ctx, "4", 2))
+	assert.ErrorIs(t, store.SetStock(ctx, "4", -1), ErrInvalidStock)
+	assert.ErrorIs(t, store.Reserve(ctx, items("4", 3)), ErrInsufficientStock)
+	require.NoError(t, store.SetStock(ctx, "5", 0))
+	require.NoError(t, store.Untrack(ctx, "5"))
+	require.NoError(t, store.Untrack(ctx, "6"), "Untracked products stay untracked")
+	require.NoError(t, store.Reserve(ctx, items("5", 1000)))
+	levels, err = store.Levels(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"2": 0, "3": 1, "4": 2}, levels)
+}
+
+// testInventoryOverselling races orders for the last units of two products.
+// Run with -race to also check the store's locking.
+func testInventoryOverselling(t *testing.T, store InventoryStore) {
+	ctx := context.Background()
+	_, err := store.Restock(ctx, "10", 20)
+	require.NoError(t, err)
+	_, err = store.Restock(ctx, "11", 30)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var placed atomic.Int32
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Every order takes one unit of 10 and two of 11
+			err := store.Reserve(ctx, []models.OrderItem{{ProductID: "10", Quantity: 1}, {ProductID: "11", Quantity: 2}})
+			if err == nil {
+				placed.Add(1)
+			} else if !errors.Is(err, ErrInsufficientStock) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 11 runs out after 15 orders, before 10 does
+	assert.Equal(t, int32(15), placed.Load())
+	levels, err := store.Levels(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, levels["10"], "Short orders took nothing")
+	assert.Equal(t, 0, levels["11"])
+}
+
+func TestMemoryInventoryStore(t *testing.T) {
+	testInventoryStore(t, NewMemoryInventoryStore())
+	testInventoryOverselling(t, NewMemoryInventoryStore())
+}
+
+func TestBoltInventoryStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.db")
+	repo, err := NewBoltOrderRepository(path)
+	require.NoError(t, err)
+	testInventoryStore(t, repo.Inventory())
+	testInventoryOverselling(t, repo.Inventory())
+	require.NoError(t, repo.Close())
+
+	// Stock survives reopening the database
+	reopened, err := NewBoltOrderRepository(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	levels, err := reopened.Inventory().Levels(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"2": 0, "3": 1, "4": 2, "10": 5, "11": 0}, levels)
+}
@@ -0,0 +1,51 @@
+// Package repository stores domain objects behind swappable interfaces.
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+)
+
+// ErrOrderNotFound is returned when no order has the requested ID
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrOrderExists is returned when saving an order whose ID is taken
+var ErrOrderExists = errors.New("order already exists")
+
+// Default and maximum page sizes for order listings
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// OrderRepository persists placed orders by their ID
+type OrderRepository interface {
+	// Save stores a new order
+	Save(ctx context.Context, order models.Order) error
+	// Get returns the order with the given ID
+	Get(ctx context.Context, id string) (models.Order, error)
+	// List returns orders newest first, starting after the cursor in opts
+	List(ctx context.Context, opts ListOptions) (OrderPage, error)
+}
+
+// ListOptions selects a page of orders
+type ListOptions struct {
+	Limit int    // Page size, DefaultPageSize if zero
+	After string // Cursor: ID of the last order of the previous page
+}
+
+// OrderPage is one page of orders
+type OrderPage struct {
+	Orders     []models.Order
+	NextCursor string // Empty on the last page
+}
+
+// pageSize clamps the requested page size
+func (o ListOptions) pageSize() int {
+	if o.Limit <= 0 {
+		return DefaultPageSize
+	}
+	return min(o.Limit, MaxPageSize)
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var ordersBucket = []byte("orders")
+
+// BoltOrderRepository stores orders in an embedded bbolt database file.
+// Orders are gob encoded so amounts keep their currency.
+type BoltOrderRepository struct {
+	db *bolt.DB
+}
+
+// NewBoltOrderRepository opens or creates the database at path
+func NewBoltOrderRepository(path string) (*BoltOrderRepository, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create order store directory: %w", err)
+	}
+
+	// The timeout fails fast when another process holds the file lock
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open order store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{ordersBucket, redemptionsBucket, inventoryBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize order store: %w", err)
+	}
+
+	return &BoltOrderRepository{db: db}, nil
+}
+
+// Close releases the database file
+func (r *BoltOrderRepository) Close() error {
+	return r.db.Close()
+}
+
+// Save stores a new order
+func (r *BoltOrderRepository) Save(ctx context.Context, order models.Order) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(order); err != nil {
+		return fmt.Errorf("failed to encode order %s: %w", order.ID, err)
+	}
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(ordersBucket)
+		if bucket.Get([]byte(order.ID)) != nil {
+			return ErrOrderExists
+		}
+		return bucket.Put([]byte(order.ID), buf.Bytes())
+	})
+}
+
+// Get returns the order with the given ID
+func (r *BoltOrderRepository) Get(ctx context.Context, id string) (models.Order, error) {
+	var order models.Order
+	err := r.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(ordersBucket).Get([]byte(id))
+		if data == nil {
+			return ErrOrderNotFound
+		}
+		return decodeOrder(data, &order)
+	})
+	return order, err
+}
+
+// List returns orders newest first
+func (r *BoltOrderRepository) List(ctx context.Context, opts ListOptions) (OrderPage, error) {
+	size := opts.pageSize()
+	page := OrderPage{Orders: make([]models.Order, 0, size)}
+
+	err := r.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(ordersBucket).Cursor()
+
+		// Position on the newest key below the cursor
+		var k, v []byte
+		if opts.After == "" {
+			k, v = cursor.Last()
+		} else if k, _ = cursor.Seek([]byte(opts.After)); k == nil {
+			k, v = cursor.Last()
+		} else {
+			k, v = cursor.Prev()
+		}
+
+		for ; k != nil; k, v = cursor.Prev() {
+			if len(page.Orders) == size {
+				page.NextCursor = page.Orders[size-1].ID
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			var order models.Order
+			if err := decodeOrder(v, &order); err != nil {
+				return err
+			}
+			page.Orders = append(page.Orders, order)
+		}
+		return nil
+	})
+	if err != nil {
+		return OrderPage{}, err
+	}
+	return page, nil
+}
+
+// decodeOrder reads a gob encoded order
+func decodeOrder(data []byte, order *models.Order) error {
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(order); err != nil {
+		return fmt.Errorf("failed to decode stored order: %w", err)
+	}
+
+	// Gob drops empty slices, the API promises an array
+	if order.Discounts == nil {
+		order.Discounts = []models.DiscountLine{}
+	}
+	return nil
+}
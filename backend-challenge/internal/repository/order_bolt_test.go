@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltOrderRepository_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "orders.db")
+	ctx := context.Background()
+
+	repo, err := NewBoltOrderRepository(path)
+	require.NoError(t, err)
+
+	order := testOrder(1)
+	order.Total = money.New(150000, "JPY")
+	require.NoError(t, repo.Save(ctx, order))
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewBoltOrderRepository(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	got, err := reopened.Get(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, order.Total, got.Total, "Stored amounts keep their currency")
+}
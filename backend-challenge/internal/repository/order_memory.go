@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+)
+
+// MemoryOrderRepository keeps orders in memory; they are lost on restart
+type MemoryOrderRepository struct {
+	mu     sync.RWMutex
+	orders map[string]models.Order
+	ids    []string // Sorted ascending, order IDs sort by creation time
+}
+
+// NewMemoryOrderRepository creates an empty in-memory repository
+func NewMemoryOrderRepository() *MemoryOrderRepository {
+	return &MemoryOrderRepository{orders: make(map[string]models.Order)}
+}
+
+// Save stores a new order
+func (r *MemoryOrderRepository) Save(ctx context.Context, order models.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.orders[order.ID]; exists {
+		return ErrOrderExists
+	}
+
+	r.orders[order.ID] = order
+	i, _ := slices.BinarySearch(r.ids, order.ID)
+	r.ids = slices.Insert(r.ids, i, order.ID)
+	return nil
+}
+
+// Get returns the order with the given ID
+func (r *MemoryOrderRepository) Get(ctx context.Context, id string) (models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	order, ok := r.orders[id]
+	if !ok {
+		return models.Order{}, ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// List returns orders newest first
+func (r *MemoryOrderRepository) List(ctx context.Context, opts ListOptions) (OrderPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Walk backwards from the newest ID below the cursor
+	end := len(r.ids)
+	if opts.After != "" {
+		end = sort.SearchStrings(r.ids, opts.After)
+	}
+
+	size := opts.pageSize()
+	page := OrderPage{Orders: make([]models.Order, 0, min(size, end))}
+	for i := end - 1; i >= 0 && len(page.Orders) < size; i-- {
+		page.Orders = append(page.Orders, r.orders[r.ids[i]])
+	}
+
+	if len(page.Orders) == size && end-size > 0 {
+		page.NextCursor = page.Orders[len(page.Orders)-1].ID
+	}
+	return page, nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOrder builds an order whose ID sorts by n
+func testOrder(n int) models.Order {
+	return models.Order{
+		ID:        fmt.Sprintf("ORD-250101-1200%02d-0000000%d", n, n%10),
+		Items:     []models.OrderItem{{ProductID: "1", Quantity: n}},
+		Subtotal:  money.New(int64(1299*n), "USD"),
+		Discounts: []models.DiscountLine{},
+		Total:     money.New(int64(1299*n), "USD"),
+		CreatedAt: time.Date(2025, 1, 1, 12, 0, n, 0, time.UTC),
+	}
+}
+
+// testOrderRepository checks the behaviour every OrderRepository shares
+func testOrderRepository(t *testing.T, repo OrderRepository) {
+	ctx := context.Background()
+
+	_, err := repo.Get(ctx, "ORD-MISSING")
+	assert.ErrorIs(t, err, ErrOrderNotFound)
+
+	empty, err := repo.List(ctx, ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, empty.Orders)
+	assert.Empty(t, empty.NextCursor)
+
+	// Save out of order, listings are still newest first
+	for _, n := range []int{3, 1, 5, 2, 4} {
+		require.NoError(t, repo.Save(ctx, testOrder(n)))
+	}
+	assert.ErrorIs(t, repo.Save(ctx, testOrder(3)), ErrOrderExists)
+
+	got, err := repo.Get(ctx, testOrder(2).ID)
+	require.NoError(t, err)
+	assert.Equal(t, testOrder(2).Total, got.Total)
+	assert.Equal(t, testOrder(2).Items, got.Items)
+	assert.NotNil(t, got.Discounts)
+	assert.True(t, testOrder(2).CreatedAt.Equal(got.CreatedAt))
+
+	var ids []string
+	opts := ListOptions{Limit: 2}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 5, "Pagination should end")
+		page, err := repo.List(ctx, opts)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(page.Orders), 2)
+		for _, order := range page.Orders {
+			ids = append(ids, order.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.After = page.NextCursor
+	}
+	assert.Equal(t, []string{testOrder(5).ID, testOrder(4).ID, testOrder(3).ID, testOrder(2).ID, testOrder(1).ID}, ids)
+
+	// An exact page leaves no dangling cursor
+	page, err := repo.List(ctx, ListOptions{Limit: 5})
+	require.NoError(t, err)
+	assert.Len(t, page.Orders, 5)
+	assert.Empty(t, page.NextCursor)
+
+	// Cursors need not be stored IDs
+	page, err = repo.List(ctx, ListOptions{After: "ORD-250101-120003-9"})
+	require.NoError(t, err)
+	require.Len(t, page.Orders, 3)
+	assert.Equal(t, testOrder(3).ID, page.Orders[0].ID)
+}
+
+func TestMemoryOrderRepository(t *testing.T) {
+	testOrderRepository(t, NewMemoryOrderRepository())
+}
+
+func TestBoltOrderRepository(t *testing.T) {
+	repo, err := NewBoltOrderRepository(filepath.Join(t.TempDir(), "orders.db"))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	testOrderRepository(t, repo)
+}
+
+func TestListOptions_PageSize(t *testing.T) {
+	assert.Equal(t, DefaultPageSize, ListOptions{}.pageSize())
+	assert.Equal(t, 5, ListOptions{Limit: 5}.pageSize())
+	assert.Equal(t, MaxPageSize, ListOptions{Limit: 1000}.pageSize())
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/utils"
+)
+
+var (
+	// ErrProductNotFound is returned when no product has the requested ID
+	ErrProductNotFound = errors.New("product not found")
+	// ErrProductExists is returned when creating a product whose ID is taken
+	ErrProductExists = errors.New("product already exists")
+	// ErrVersionConflict is returned when a product changed since the caller read it
+	ErrVersionConflict = errors.New("product was modified concurrently")
+	// ErrInvalidProduct wraps validation failures of a product, including
+	// references to unknown categories
+	ErrInvalidProduct = errors.New("invalid product")
+)
+
+// Limits on product fields
+const (
+	MaxProductNameLength        = 100
+	MaxProductCategoryLength    = 50
+	MaxProductDescriptionLength = 1000
+	MaxProductImageURLLength    = 2048
+)
+
+// ProductRepository holds the product catalog. Every product belongs to one
+// of its categories, named by the category's name. Every change bumps the
+// product's version; updates and deletes name the version they were based
+// on and fail with ErrVersionConflict if it is no longer current.
+type ProductRepository interface {
+	// Categories returns every category in display order
+	Categories(ctx context.Context) ([]models.Category, error)
+	// Category returns the category with the given slug
+	Category(ctx context.Context, slug string) (models.Category, error)
+	// List returns a page of the products matching query
+	List(ctx context.Context, query ProductQuery) (ProductPage, error)
+	// Get returns the product with the given ID
+	Get(ctx context.Context, id string) (models.Product, error)
+	// Create adds a product at version 1. An empty ID gets the next free one.
+	Create(ctx context.Context, product models.Product) (models.Product, error)
+	// Update replaces the product with product.ID if it is at product.Version
+	Update(ctx context.Context, product models.Product) (models.Product, error)
+	// Delete removes the product with the given ID if it is at version
+	Delete(ctx context.Context, id string, version int) error
+}
+
+// ValidateProduct checks the fields of a product, returning an error
+// wrapping ErrInvalidProduct
+func ValidateProduct(p models.Product) error {
+	switch {
+	case !utils.IsValidID(p.ID):
+		return fmt.Errorf("%w: id must be a positive number without leading zeros, got %q", ErrInvalidProduct, p.ID)
+	case strings.TrimSpace(p.Name) == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidProduct)
+	case utf8.RuneCountInString(p.Name) > MaxProductNameLength:
+		return fmt.Errorf("%w: name must be at most %d characters", ErrInvalidProduct, MaxProductNameLength)
+	case !p.Price.IsPositive():
+		return fmt.Errorf("%w: price must be greater than 0", ErrInvalidProduct)
+	case strings.TrimSpace(p.Category) == "":
+		return fmt.Errorf("%w: category is required", ErrInvalidProduct)
+	case utf8.RuneCountInString(p.Category) > MaxProductCategoryLength:
+		return fmt.Errorf("%w: category must be at most %d characters", ErrInvalidProduct, MaxProductCategoryLength)
+	case utf8.RuneCountInString(p.Description) > MaxProductDescriptionLength:
+		return fmt.Errorf("%w: description must be at most %d characters", ErrInvalidProduct, MaxProductDescriptionLength)
+	}
+
+	if p.Image != nil {
+		for size, link := range map[string]string{
+			"thumbnail": p.Image.Thumbnail,
+			"mobile":    p.Image.Mobile,
+			"tablet":    p.Image.Tablet,
+			"desktop":   p.Image.Desktop,
+		} {
+			if link != "" && !isImageURL(link) {
+				return fmt.Errorf("%w: image.%s must be an http(s) URL or an absolute path", ErrInvalidProduct, size)
+			}
+		}
+	}
+	return nil
+}
+
+// isImageURL reports whether link is an absolute http(s) URL or a path on
+// this server, such as an uploaded file under /media
+func isImageURL(link string) bool {
+	if len(link) > MaxProductImageURLLength {
+		return false
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		return strings.HasPrefix(u.Path, "/")
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// DefaultProducts returns the catalog served when no product file is configured
+func DefaultProducts() []models.Product {
+	usd := func(cents int64) money.Money { return money.New(cents, "USD") }
+	return []models.Product{
+		{ID: "1", Name: "Chicken Waffle", Price: usd(1299), Category: "Waffle", Version: 1},
+		{ID: "2", Name: "Belgian Waffle", Price: usd(1099), Category: "Waffle", Version: 1},
+		{ID: "3", Name: "Pancake Stack", Price: usd(899), Category: "Pancake", Version: 1},
+		{ID: "4", Name: "Avocado Toast", Price: usd(999), Category: "Toast", Version: 1},
+		{ID: "5", Name: "Caesar Salad", Price: usd(1199), Category: "Salad", Version: 1},
+		{ID: "6", Name: "Burger Deluxe", Price: usd(1499), Category: "Burger", Version: 1},
+		{ID: "7", Name: "Fish & Chips", Price: usd(1399), Category: "Main", Version: 1},
+		{ID: "8", Name: "Chocolate Cake", Price: usd(699), Category: "Dessert", Version: 1},
+	}
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileProductRepository keeps the catalog in memory and writes every change
+// back to a JSON or YAML file, chosen by the file extension
+type FileProductRepository struct {
+	*MemoryProductRepository
+	path string
+}
+
+// productFile is the layout of a product file. Files without categories
+// get one for every distinct product category.
+//
+//	categories:
+//	  - slug: waffle
+//	    name: Waffle
+//	    sortOrder: 10
+//	    icon: /media/waffle-icon.png
+//	products:
+//	  - id: "1"
+//	    name: Chicken Waffle
+//	    price: 12.99
+//	    currency: USD
+//	    category: Waffle
+//	    description: Crispy chicken on a Belgian waffle
+//	    image:
+//	      thumbnail: /media/waffle-thumbnail.jpg
+//	      desktop: https://cdn.example.com/waffle-desktop.jpg
+//	    version: 3
+//	lastId: 12
+//
+// lastId is the highest product ID ever used, so IDs of deleted products
+// are not given out again.
+type productFile struct {
+	Categories []categoryRecord `json:"categories" yaml:"categories"`
+	Products   []productRecord  `json:"products" yaml:"products"`
+	LastID     int              `json:"lastId,omitempty" yaml:"lastId,omitempty"`
+}
+
+// categoryRecord is a category as written to a file
+type categoryRecord struct {
+	Slug      string `json:"slug" yaml:"slug"`
+	Name      string `json:"name" yaml:"name"`
+	SortOrder int    `json:"sortOrder" yaml:"sortOrder"`
+	Icon      string `json:"icon,omitempty" yaml:"icon,omitempty"`
+}
+
+// productRecord is a product as written to a file. Prices are kept as
+// decimals, so the file stays easy to edit by hand.
+type productRecord struct {
+	ID          string       `json:"id" yaml:"id"`
+	Name        string       `json:"name" yaml:"name"`
+	Price       decimal      `json:"price" yaml:"price"`
+	Currency    string       `json:"currency,omitempty" yaml:"currency,omitempty"` // money.DefaultCurrency if empty
+	Category    string       `json:"category" yaml:"category"`
+	Description string       `json:"description,omitempty" yaml:"description,omitempty"`
+	Image       *imageRecord `json:"image,omitempty" yaml:"image,omitempty"`
+	Version     int          `json:"version" yaml:"version"`
+}
+
+// imageRecord is a product's image URLs as written to a file
+type imageRecord struct {
+	Thumbnail string `json:"thumbnail,omitempty" yaml:"thumbnail,omitempty"`
+	Mobile    string `json:"mobile,omitempty" yaml:"mobile,omitempty"`
+	Tablet    string `json:"tablet,omitempty" yaml:"tablet,omitempty"`
+	Desktop   string `json:"desktop,omitempty" yaml:"desktop,omitempty"`
+}
+
+// decimal is an amount such as "12.99", written as a plain number
+type decimal string
+
+// MarshalJSON writes the amount as a JSON number
+func (d decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d), nil
+}
+
+// MarshalYAML writes the amount as an unquoted YAML number
+func (d decimal) MarshalYAML() (any, error) {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: string(d)}, nil
+}
+
+// NewFileProductRepository loads the catalog from path. A missing file is
+// created holding DefaultCategories and DefaultProducts.
+func NewFileProductRepository(path string) (*FileProductRepository, error) {
+	categories, products, lastID, err := readProductFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		categories, products = DefaultCategories(), DefaultProducts()
+		if err := writeProductFile(path, categories, products, 0); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	memory, err := NewMemoryProductRepository(categories, products)
+	if err != nil {
+		return nil, fmt.Errorf("invalid product file %s: %w", path, err)
+	}
+	memory.lastID = max(memory.lastID, lastID)
+	memory.persist = func(products []models.Product, lastID int) error {
+		return writeProductFile(path, memory.categories.ordered, products, lastID)
+	}
+	return &FileProductRepository{MemoryProductRepository: memory, path: path}, nil
+}
+
+// Path returns the file the catalog is kept in
+func (r *FileProductRepository) Path() string {
+	return r.path
+}
+
+// readProductFile reads the categories, products and last ID of a JSON or
+// YAML file. Categories are nil if the file has none.
+func readProductFile(path string) ([]models.Category, []models.Product, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to read product file: %w", err)
+	}
+
+	// YAML is a superset of JSON, so both formats decode the same way
+	var file productFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to parse product file %s: %w", path, err)
+	}
+
+	var categories []models.Category
+	for _, record := range file.Categories {
+		categories = append(categories, models.Category(record))
+	}
+
+	products := make([]models.Product, 0, len(file.Products))
+	for _, record := range file.Products {
+		currency := record.Currency
+		if currency == "" {
+			currency = money.DefaultCurrency
+		}
+		price, err := money.Parse(string(record.Price), currency)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("invalid price of product %q in %s: %w", record.ID, path, err)
+		}
+		products = append(products, models.Product{
+			ID:          record.ID,
+			Name:        record.Name,
+			Price:       price,
+			Category:    record.Category,
+			Description: record.Description,
+			Image:       (*models.ProductImage)(record.Image),
+			Version:     record.Version,
+		})
+	}
+	return categories, products, file.LastID, nil
+}
+
+// writeProductFile replaces the file at path with categories, products and
+// the last ID. The file is written next to the target and renamed, so
+// readers never see a partial file.
+func writeProductFile(path string, categories []models.Category, products []models.Product, lastID int) error {
+	file := productFile{
+		Categories: make([]categoryRecord, 0, len(categories)),
+		Products:   make([]productRecord, 0, len(products)),
+		LastID:     lastID,
+	}
+	for _, c := range categories {
+		file.Categories = append(file.Categories, categoryRecord(c))
+	}
+	for _, p := range products {
+		file.Products = append(file.Products, productRecord{
+			ID:          p.ID,
+			Name:        p.Name,
+			Price:       decimal(p.Price.Decimal()),
+			Currency:    p.Price.Currency(),
+			Category:    p.Category,
+			Description: p.Description,
+			Image:       (*imageRecord)(p.Image),
+			Version:     p.Version,
+		})
+	}
+
+	var buf bytes.Buffer
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		encoder := json.NewEncoder(&buf)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file); err != nil {
+			return fmt.Errorf("failed to encode products: %w", err)
+		}
+	default:
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(file); err != nil {
+			return fmt.Errorf("failed to encode products: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create product file directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write product file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write product file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write product file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace product file: %w", err)
+	}
+	return nil
+}
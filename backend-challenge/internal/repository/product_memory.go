@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/utils"
+)
+
+// MemoryProductRepository keeps the catalog in memory. Changes replace the
+// whole catalog slice, so readers never see a half applied change.
+type MemoryProductRepository struct {
+	categories *categorySet
+
+	mu       sync.RWMutex
+	products []models.Product // Sorted by numeric ID
+	lastID   int              // Highest ID ever used, so IDs of deleted products are not given out again
+
+	// persist is called with the changed catalog and last ID before they
+	// are applied; the change is dropped if it fails
+	persist func(products []models.Product, lastID int) error
+}
+
+// NewMemoryProductRepository creates a repository holding categories and
+// products. Without categories, one is made of every distinct product
+// category. Products without a version start at version 1.
+func NewMemoryProductRepository(categories []models.Category, products []models.Product) (*MemoryProductRepository, error) {
+	if categories == nil {
+		categories = deriveCategories(products)
+	}
+	set, err := newCategorySet(categories)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog, err := newCatalog(set, products)
+	if err != nil {
+		return nil, err
+	}
+	repo := &MemoryProductRepository{categories: set, products: catalog}
+	if len(catalog) > 0 {
+		repo.lastID = productID(catalog[len(catalog)-1])
+	}
+	return repo, nil
+}
+
+// newCatalog validates products and sorts them by ID
+func newCatalog(categories *categorySet, products []models.Product) ([]models.Product, error) {
+	catalog := slices.Clone(products)
+	for i := range catalog {
+		p := &catalog[i]
+		if err := ValidateProduct(*p); err != nil {
+			return nil, fmt.Errorf("product %q: %w", p.ID, err)
+		}
+		if err := categories.checkProduct(p); err != nil {
+			return nil, fmt.Errorf("product %q: %w", p.ID, err)
+		}
+		if p.Version < 1 {
+			p.Version = 1
+		}
+	}
+
+	slices.SortFunc(catalog, compareProducts)
+	for i := 1; i < len(catalog); i++ {
+		if productID(catalog[i]) == productID(catalog[i-1]) {
+			return nil, fmt.Errorf("duplicate product ID %s", catalog[i].ID)
+		}
+	}
+	return catalog, nil
+}
+
+// Categories returns every category in display order
+func (r *MemoryProductRepository) Categories(ctx context.Context) ([]models.Category, error) {
+	return slices.Clone(r.categories.ordered), nil
+}
+
+// Category returns the category with the given slug
+func (r *MemoryProductRepository) Category(ctx context.Context, slug string) (models.Category, error) {
+	category, ok := r.categories.get(slug)
+	if !ok {
+		return models.Category{}, ErrCategoryNotFound
+	}
+	return category, nil
+}
+
+// List returns a page of the products matching query
+func (r *MemoryProductRepository) List(ctx context.Context, query ProductQuery) (ProductPage, error) {
+	r.mu.RLock()
+	catalog := r.products // Changes replace the slice, never modify it
+	r.mu.RUnlock()
+
+	// Products carry the category name, so slugs are looked up first
+	if category, ok := r.categories.resolve(query.Category); ok {
+		query.Category = category.Name
+	}
+	return queryProducts(catalog, query)
+}
+
+// Get returns the product with the given ID
+func (r *MemoryProductRepository) Get(ctx context.Context, id string) (models.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i, ok := r.find(id)
+	if !ok {
+		return models.Product{}, ErrProductNotFound
+	}
+	return r.products[i], nil
+}
+
+// Create adds a product at version 1
+func (r *MemoryProductRepository) Create(ctx context.Context, product models.Product) (models.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if product.ID == "" {
+		product.ID = r.nextID()
+	}
+	product.Version = 1
+	if err := ValidateProduct(product); err != nil {
+		return models.Product{}, err
+	}
+	if err := r.categories.checkProduct(&product); err != nil {
+		return models.Product{}, err
+	}
+	if _, exists := r.find(product.ID); exists {
+		return models.Product{}, ErrProductExists
+	}
+
+	i, _ := slices.BinarySearchFunc(r.products, product, compareProducts)
+	lastID := max(r.lastID, productID(product))
+	if err := r.apply(slices.Insert(slices.Clone(r.products), i, product), lastID); err != nil {
+		return models.Product{}, err
+	}
+	return product, nil
+}
+
+// Update replaces the product with product.ID if it is at product.Version
+func (r *MemoryProductRepository) Update(ctx context.Context, product models.Product) (models.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.find(product.ID)
+	if !ok {
+		return models.Product{}, ErrProductNotFound
+	}
+	if r.products[i].Version != product.Version {
+		return models.Product{}, ErrVersionConflict
+	}
+	if err := ValidateProduct(product); err != nil {
+		return models.Product{}, err
+	}
+	if err := r.categories.checkProduct(&product); err != nil {
+		return models.Product{}, err
+	}
+
+	product.ID = r.products[i].ID
+	product.Version++
+	changed := slices.Clone(r.products)
+	changed[i] = product
+	if err := r.apply(changed, r.lastID); err != nil {
+		return models.Product{}, err
+	}
+	return product, nil
+}
+
+// Delete removes the product with the given ID if it is at version
+func (r *MemoryProductRepository) Delete(ctx context.Context, id string, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.find(id)
+	if !ok {
+		return ErrProductNotFound
+	}
+	if r.products[i].Version != version {
+		return ErrVersionConflict
+	}
+	return r.apply(slices.Delete(slices.Clone(r.products), i, i+1), r.lastID)
+}
+
+// apply persists and then installs a changed catalog and last ID, holding
+// the write lock
+func (r *MemoryProductRepository) apply(products []models.Product, lastID int) error {
+	if r.persist != nil {
+		if err := r.persist(products, lastID); err != nil {
+			return err
+		}
+	}
+	r.products = products
+	r.lastID = lastID
+	return nil
+}
+
+// find returns the index of the product with the given ID
+func (r *MemoryProductRepository) find(id string) (int, bool) {
+	// Only the canonical form names a product, as in inventory and orders
+	if !utils.IsValidID(id) {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(id)
+	return slices.BinarySearchFunc(r.products, n, func(p models.Product, n int) int {
+		return cmp.Compare(productID(p), n)
+	})
+}
+
+// nextID returns one past the highest ID ever used. Deleted IDs are not
+// reused, their stock and orders must not pass to a new product.
+func (r *MemoryProductRepository) nextID() string {
+	return strconv.Itoa(r.lastID + 1)
+}
+
+// productID returns the numeric ID of a validated product
+func productID(p models.Product) int {
+	n, _ := strconv.Atoi(p.ID)
+	return n
+}
+
+// compareProducts orders products by numeric ID
+func compareProducts(a, b models.Product) int {
+	return cmp.Compare(productID(a), productID(b))
+}
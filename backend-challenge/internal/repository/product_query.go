@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"cmp"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
+)
+
+// ErrInvalidCursor is returned for cursors not made by a listing with the same sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Product sort orders, ties are broken by ID
+const (
+	SortByID        = ""
+	SortByPrice     = "price"
+	SortByPriceDesc = "-price"
+	SortByName      = "name"
+)
+
+// ProductQuery selects, orders and pages products. Zero values select
+// every product ordered by ID.
+type ProductQuery struct {
+	Category string       // Category name or slug, case-insensitive
+	Search   string       // Substring of the name, case-insensitive
+	MinPrice *money.Money // Inclusive; products in other currencies are left out
+	MaxPrice *money.Money // Inclusive; products in other currencies are left out
+	Sort     string       // One of the SortBy orders
+	Limit    int          // Page size, zero for every match
+	After    string       // Cursor: NextCursor of the previous page
+}
+
+// ProductPage is one page of products
+type ProductPage struct {
+	Products   []models.Product
+	NextCursor string // Empty on the last page
+}
+
+// IsValidSort reports whether sort is one of the SortBy orders
+func IsValidSort(sort string) bool {
+	switch sort {
+	case SortByID, SortByPrice, SortByPriceDesc, SortByName:
+		return true
+	}
+	return false
+}
+
+// queryProducts applies a query to a catalog sorted by ID
+func queryProducts(catalog []models.Product, q ProductQuery) (ProductPage, error) {
+	if !IsValidSort(q.Sort) {
+		return ProductPage{}, errors.New("invalid sort " + strconv.Quote(q.Sort))
+	}
+
+	matches := make([]models.Product, 0, len(catalog))
+	for _, p := range catalog {
+		if q.matches(p) {
+			matches = append(matches, p)
+		}
+	}
+	compare := productOrder(q.Sort)
+	slices.SortFunc(matches, compare)
+
+	// Skip past the last product of the previous page
+	if q.After != "" {
+		last, err := decodeCursor(q.After, q.Sort)
+		if err != nil {
+			return ProductPage{}, err
+		}
+		start, found := slices.BinarySearchFunc(matches, last, compare)
+		if found {
+			start++ // The cursor product itself is still listed
+		}
+		matches = matches[start:]
+	}
+
+	page := ProductPage{Products: matches}
+	if q.Limit > 0 && len(matches) > q.Limit {
+		page.Products = matches[:q.Limit]
+		page.NextCursor = encodeCursor(page.Products[q.Limit-1], q.Sort)
+	}
+	return page, nil
+}
+
+// matches reports whether a product passes the filters of q
+func (q ProductQuery) matches(p models.Product) bool {
+	if q.Category != "" && !strings.EqualFold(p.Category, q.Category) {
+		return false
+	}
+	if q.Search != "" && !strings.Contains(strings.ToLower(p.Name), strings.ToLower(q.Search)) {
+		return false
+	}
+	if q.MinPrice != nil {
+		if c, err := p.Price.Cmp(*q.MinPrice); err != nil || c < 0 {
+			return false
+		}
+	}
+	if q.MaxPrice != nil {
+		if c, err := p.Price.Cmp(*q.MaxPrice); err != nil || c > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// productOrder returns the comparison of a sort order, ending in the ID
+func productOrder(sort string) func(a, b models.Product) int {
+	byPrice := func(a, b models.Product) int {
+		return cmp.Or(
+			cmp.Compare(a.Price.Currency(), b.Price.Currency()),
+			cmp.Compare(a.Price.Minor(), b.Price.Minor()),
+		)
+	}
+
+	switch sort {
+	case SortByPrice:
+		return func(a, b models.Product) int {
+			return cmp.Or(byPrice(a, b), compareProducts(a, b))
+		}
+	case SortByPriceDesc:
+		return func(a, b models.Product) int {
+			return cmp.Or(byPrice(b, a), compareProducts(a, b))
+		}
+	case SortByName:
+		return func(a, b models.Product) int {
+			return cmp.Or(
+				cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)),
+				compareProducts(a, b),
+			)
+		}
+	}
+	return compareProducts
+}
+
+// encodeCursor turns the sort key of the last product of a page into an
+// opaque cursor. It holds the key rather than a position, so products added
+// or removed between pages neither repeat nor go missing.
+func encodeCursor(p models.Product, sort string) string {
+	fields := []string{sort, p.ID, p.Price.Currency(), strconv.FormatInt(p.Price.Minor(), 10), p.Name}
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(fields, "\x00")))
+}
+
+// decodeCursor reads a cursor made by encodeCursor for the same sort
+func decodeCursor(cursor, sort string) (models.Product, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return models.Product{}, ErrInvalidCursor
+	}
+
+	fields := strings.SplitN(string(data), "\x00", 5)
+	if len(fields) != 5 || fields[0] != sort {
+		return models.Product{}, ErrInvalidCursor
+	}
+	if _, err := strconv.Atoi(fields[1]); err != nil {
+		return models.Product{}, ErrInvalidCursor
+	}
+	minor, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return models.Product{}, ErrInvalidCursor
+	}
+
+	return models.Product{ID: fields[1], Price: money.New(minor, fields[2]), Name: fields[4]}, nil
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// productIDs returns the IDs of products in order
+func productIDs(products []models.Product) []string {
+	ids := make([]string, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func TestQueryProducts_Filters(t *testing.T) {
+	usd := func(cents int64) *money.Money {
+		m := money.New(cents, "USD")
+		return &m
+	}
+
+	tests := []struct {
+		name     string
+		query    ProductQuery
+		expected []string
+	}{
+		{"Everything", ProductQuery{}, []string{"1", "2", "3", "4", "5", "6", "7", "8"}},
+		{"Category ignores case", ProductQuery{Category: "waffle"}, []string{"1", "2"}},
+		{"Unknown category", ProductQuery{Category: "Soup"}, []string{}},
+		{"Name search ignores case", ProductQuery{Search: "WAFF"}, []string{"1", "2"}},
+		{"Name search inside words", ProductQuery{Search: "ke"}, []string{"1", "3", "8"}},
+		{"Minimum price is inclusive", ProductQuery{MinPrice: usd(1299)}, []string{"1", "6", "7"}},
+		{"Maximum price is inclusive", ProductQuery{MaxPrice: usd(999)}, []string{"3", "4", "8"}},
+		{"Price range", ProductQuery{MinPrice: usd(1000), MaxPrice: usd(1200)}, []string{"2", "5"}},
+		{"Other currency matches nothing", ProductQuery{MinPrice: &[]money.Money{money.New(1, "EUR")}[0]}, []string{}},
+		{"Combined filters", ProductQuery{Category: "Waffle", MaxPrice: usd(1100)}, []string{"2"}},
+		{"Sort by price", ProductQuery{Sort: SortByPrice, Category: "Waffle"}, []string{"2", "1"}},
+		{"Sort by price descending", ProductQuery{Sort: SortByPriceDesc}, []string{"6", "7", "1", "5", "2", "4", "3", "8"}},
+		{"Sort by name", ProductQuery{Sort: SortByName}, []string{"4", "2", "6", "5", "1", "8", "7", "3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := queryProducts(DefaultProducts(), tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, productIDs(page.Products))
+			assert.NotNil(t, page.Products)
+			assert.Empty(t, page.NextCursor)
+		})
+	}
+
+	_, err := queryProducts(DefaultProducts(), ProductQuery{Sort: "category"})
+	assert.Error(t, err)
+}
+
+func TestQueryProducts_Pages(t *testing.T) {
+	for _, sort := range []string{SortByID, SortByPrice, SortByPriceDesc, SortByName} {
+		t.Run("sort "+sort, func(t *testing.T) {
+			all, err := queryProducts(DefaultProducts(), ProductQuery{Sort: sort})
+			require.NoError(t, err)
+
+			var paged []string
+			query := ProductQuery{Sort: sort, Limit: 3}
+			for pages := 0; ; pages++ {
+				require.Less(t, pages, 5, "Pagination should end")
+				page, err := queryProducts(DefaultProducts(), query)
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(page.Products), 3)
+				paged = append(paged, productIDs(page.Products)...)
+				if page.NextCursor == "" {
+					break
+				}
+				query.After = page.NextCursor
+			}
+			assert.Equal(t, productIDs(all.Products), paged, "Pages list every product once, in order")
+		})
+	}
+}
+
+func TestQueryProducts_CursorSurvivesChanges(t *testing.T) {
+	repo, err := NewMemoryProductRepository(DefaultCategories(), DefaultProducts())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	first, err := repo.List(ctx, ProductQuery{Sort: SortByPrice, Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"8", "3", "4"}, productIDs(first.Products))
+
+	// The last product of the page is removed and a cheaper one added
+	require.NoError(t, repo.Delete(ctx, "4", 1))
+	_, err = repo.Create(ctx, models.Product{Name: "Juice", Price: money.New(399, "USD"), Category: "Dessert"})
+	require.NoError(t, err)
+
+	second, err := repo.List(ctx, ProductQuery{Sort: SortByPrice, Limit: 3, After: first.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2", "5", "1"}, productIDs(second.Products), "The next page continues after the cursor's price")
+}
+
+func TestQueryProducts_InvalidCursor(t *testing.T) {
+	page, err := queryProducts(DefaultProducts(), ProductQuery{Sort: SortByName, Limit: 2})
+	require.NoError(t, err)
+	require.NotEmpty(t, page.NextCursor)
+
+	for _, cursor := range []string{"not base64!", "bm9wZQ", page.NextCursor} {
+		// The valid cursor was made for another sort
+		_, err := queryProducts(DefaultProducts(), ProductQuery{Sort: SortByPrice, After: cursor})
+		assert.ErrorIs(t, err, ErrInvalidCursor, cursor)
+	}
+}
@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listProducts returns every product of repo
+func listProducts(t *testing.T, repo ProductRepository) []models.Product {
+	t.Helper()
+	page, err := repo.List(context.Background(), ProductQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, page.NextCursor)
+	return page.Products
+}
+
+// testProductRepository checks the behaviour every ProductRepository shares.
+// repo must start with DefaultCategories and DefaultProducts.
+func testProductRepository(t *testing.T, repo ProductRepository) {
+	ctx := context.Background()
+
+	products := listProducts(t, repo)
+	require.Len(t, products, 8)
+	assert.Equal(t, "1", products[0].ID)
+	assert.Equal(t, "8", products[7].ID)
+
+	_, err := repo.Get(ctx, "99")
+	assert.ErrorIs(t, err, ErrProductNotFound)
+	for _, id := range []string{"08", "+8", " 8"} {
+		_, err = repo.Get(ctx, id)
+		assert.ErrorIs(t, err, ErrProductNotFound, "Only the canonical ID names a product, not %q", id)
+	}
+
+	// Create assigns the next ID and version 1
+	created, err := repo.Create(ctx, models.Product{Name: "Waffle Fries", Price: money.New(450, "USD"), Category: "main", Version: 7})
+	require.NoError(t, err)
+	assert.Equal(t, "9", created.ID)
+	assert.Equal(t, 1, created.Version)
+	assert.Equal(t, "Main", created.Category, "Categories may be given by slug")
+	_, err = repo.Create(ctx, models.Product{Name: "Hash Brown", Price: money.New(300, "USD"), Category: "Side"})
+	assert.ErrorIs(t, err, ErrInvalidProduct, "Unknown category")
+
+	_, err = repo.Create(ctx, models.Product{ID: "9", Name: "Dup", Price: money.New(1, "USD"), Category: "Main"})
+	assert.ErrorIs(t, err, ErrProductExists)
+	_, err = repo.Create(ctx, models.Product{Name: "", Price: money.New(1, "USD"), Category: "Main"})
+	assert.ErrorIs(t, err, ErrInvalidProduct)
+
+	// IDs sort numerically
+	_, err = repo.Create(ctx, models.Product{ID: "10", Name: "Milkshake", Price: money.New(550, "USD"), Category: "Dessert",
+		Description: "Vanilla", Image: &models.ProductImage{Thumbnail: "/media/shake.png", Desktop: "https://cdn.example.com/shake.png"}})
+	require.NoError(t, err)
+	products = listProducts(t, repo)
+	assert.Equal(t, "10", products[len(products)-1].ID)
+
+	// Updates need the current version and bump it
+	created.Name = "Curly Fries"
+	updated, err := repo.Update(ctx, created)
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	created.Name = "Stale Fries"
+	_, err = repo.Update(ctx, created)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	updated.Price = money.Money{}
+	_, err = repo.Update(ctx, updated)
+	assert.ErrorIs(t, err, ErrInvalidProduct)
+
+	got, err := repo.Get(ctx, "9")
+	require.NoError(t, err)
+	assert.Equal(t, "Curly Fries", got.Name, "Rejected updates change nothing")
+	assert.Equal(t, 2, got.Version)
+
+	_, err = repo.Update(ctx, models.Product{ID: "99", Name: "X", Price: money.New(1, "USD"), Category: "Main", Version: 1})
+	assert.ErrorIs(t, err, ErrProductNotFound)
+
+	// Deletes need the current version too
+	assert.ErrorIs(t, repo.Delete(ctx, "9", 1), ErrVersionConflict)
+	require.NoError(t, repo.Delete(ctx, "9", 2))
+	assert.ErrorIs(t, repo.Delete(ctx, "9", 2), ErrProductNotFound)
+	_, err = repo.Get(ctx, "9")
+	assert.ErrorIs(t, err, ErrProductNotFound)
+
+	// IDs of deleted products are not given out again
+	require.NoError(t, repo.Delete(ctx, "10", 1))
+	created, err = repo.Create(ctx, models.Product{Name: "Onion Rings", Price: money.New(400, "USD"), Category: "Main"})
+	require.NoError(t, err)
+	assert.Equal(t, "11", created.ID)
+	_, err = repo.Create(ctx, models.Product{ID: "10", Name: "Milkshake", Price: money.New(550, "USD"), Category: "Dessert",
+		Description: "Vanilla", Image: &models.ProductImage{Thumbnail: "/media/shake.png", Desktop: "https://cdn.example.com/shake.png"}})
+	require.NoError(t, err, "Unless given explicitly")
+	require.NoError(t, repo.Delete(ctx, "11", 1))
+}
+
+func TestMemoryProductRepository(t *testing.T) {
+	repo, err := NewMemoryProductRepository(DefaultCategories(), DefaultProducts())
+	require.NoError(t, err)
+	testProductRepository(t, repo)
+}
+
+func TestNewMemoryProductRepository_RejectsInvalidCatalogs(t *testing.T) {
+	valid := models.Product{ID: "1", Name: "Waffle", Price: money.New(100, "USD"), Category: "Waffle"}
+
+	_, err := NewMemoryProductRepository(nil, []models.Product{valid, valid})
+	assert.Error(t, err, "Duplicate IDs")
+
+	invalid := valid
+	invalid.Category = ""
+	_, err = NewMemoryProductRepository(nil, []models.Product{invalid})
+	assert.ErrorIs(t, err, ErrInvalidProduct)
+
+	repo, err := NewMemoryProductRepository(nil, []models.Product{valid})
+	require.NoError(t, err)
+	got, err := repo.Get(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.Version, "Products without a version start at 1")
+}
+
+func TestFileProductRepository(t *testing.T) {
+	for _, name := range []string{"products.yaml", "products.json"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			repo, err := NewFileProductRepository(path)
+			require.NoError(t, err)
+			testProductRepository(t, repo)
+
+			// Changes survive reopening the file
+			reopened, err := NewFileProductRepository(path)
+			require.NoError(t, err)
+			products := listProducts(t, reopened)
+			require.Len(t, products, 9)
+			assert.Equal(t, "Milkshake", products[8].Name)
+			assert.Equal(t, money.New(550, "USD"), products[8].Price)
+			assert.Equal(t, "Vanilla", products[8].Description)
+			assert.Equal(t, &models.ProductImage{Thumbnail: "/media/shake.png", Desktop: "https://cdn.example.com/shake.png"}, products[8].Image)
+
+			// So does the last ID
+			created, err := reopened.Create(context.Background(), models.Product{Name: "Hash Brown", Price: money.New(300, "USD"), Category: "Main"})
+			require.NoError(t, err)
+			assert.Equal(t, "12", created.ID)
+		})
+	}
+}
+
+func TestFileProductRepository_ReadsHandWrittenFiles(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "menu.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`products:
+  - id: "2"
+    name: Flat White
+    price: 4.5
+    category: Coffee
+    description: Double ristretto with steamed milk
+    image:
+      thumbnail: /media/flat-white-thumbnail.jpg
+      desktop: https://cdn.example.com/flat-white.jpg
+  - id: "1"
+    name: Ramen
+    price: 1200
+    currency: JPY
+    category: Noodles
+    version: 4
+`), 0o600))
+
+	repo, err := NewFileProductRepository(yamlPath)
+	require.NoError(t, err)
+	products := listProducts(t, repo)
+	require.Len(t, products, 2)
+	assert.Equal(t, money.New(1200, "JPY"), products[0].Price)
+	assert.Equal(t, 4, products[0].Version)
+	assert.Equal(t, money.New(450, "USD"), products[1].Price)
+	assert.Equal(t, 1, products[1].Version)
+	assert.Equal(t, "Double ristretto with steamed milk", products[1].Description)
+	require.NotNil(t, products[1].Image)
+	assert.Equal(t, "/media/flat-white-thumbnail.jpg", products[1].Image.Thumbnail)
+	assert.Equal(t, "https://cdn.example.com/flat-white.jpg", products[1].Image.Desktop)
+	assert.Nil(t, products[0].Image)
+
+	jsonPath := filepath.Join(dir, "menu.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"products":[{"id":"1","name":"Tea","price":3.25,"category":"Drink"}]}`), 0o600))
+	repo, err = NewFileProductRepository(jsonPath)
+	require.NoError(t, err)
+	got, err := repo.Get(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, "3.25", got.Price.Decimal())
+
+	badPrice := filepath.Join(dir, "bad.yaml")
+	require.NoError(t, os.WriteFile(badPrice, []byte("products:\n  - {id: \"1\", name: X, price: 1.234, category: Y}\n"), 0o600))
+	_, err = NewFileProductRepository(badPrice)
+	assert.Error(t, err, "Prices finer than a cent are rejected")
+}
+
+func TestFileProductRepository_KeepsCatalogWhenWriteFails(t *testing.T) {
+	repo, err := NewFileProductRepository(filepath.Join(t.TempDir(), "products.yaml"))
+	require.NoError(t, err)
+	errDiskFull := errors.New("disk full")
+	repo.persist = func([]models.Product, int) error { return errDiskFull }
+
+	_, err = repo.Create(context.Background(), models.Product{Name: "X", Price: money.New(100, "USD"), Category: "Main"})
+	assert.ErrorIs(t, err, errDiskFull)
+	assert.ErrorIs(t, repo.Delete(context.Background(), "1", 1), errDiskFull)
+
+	products := listProducts(t, repo)
+	assert.Len(t, products, 8, "Failed changes are not applied")
+}
+
+func TestValidateProduct(t *testing.T) {
+	valid := models.Product{ID: "1", Name: "Waffle", Price: money.New(100, "USD"), Category: "Waffle"}
+	long := func(n int) string { return strings.Repeat("x", n) }
+
+	tests := []struct {
+		name   string
+		change func(p *models.Product)
+		valid  bool
+	}{
+		{"Valid", func(p *models.Product) {}, true},
+		{"Non-numeric ID", func(p *models.Product) { p.ID = "abc" }, false},
+		{"ID with leading zero", func(p *models.Product) { p.ID = "01" }, false},
+		{"Blank name", func(p *models.Product) { p.Name = "  " }, false},
+		{"Long name", func(p *models.Product) { p.Name = long(MaxProductNameLength + 1) }, false},
+		{"Negative price", func(p *models.Product) { p.Price = money.New(-1, "USD") }, false},
+		{"No category", func(p *models.Product) { p.Category = "" }, false},
+		{"Description", func(p *models.Product) { p.Description = long(MaxProductDescriptionLength) }, true},
+		{"Long description", func(p *models.Product) { p.Description = long(MaxProductDescriptionLength + 1) }, false},
+		{"Image URLs and paths", func(p *models.Product) {
+			p.Image = &models.ProductImage{
+				Thumbnail: "/media/0123456789abcdef.png",
+				Mobile:    "https://cdn.example.com/waffle-mobile.jpg",
+				Desktop:   "http://cdn.example.com/waffle-desktop.jpg",
+			}
+		}, true},
+		{"Relative image path", func(p *models.Product) { p.Image = &models.ProductImage{Tablet: "media/waffle.png"} }, false},
+		{"Image with other scheme", func(p *models.Product) { p.Image = &models.ProductImage{Mobile: "javascript:alert(1)"} }, false},
+		{"Image URL without host", func(p *models.Product) { p.Image = &models.ProductImage{Desktop: "https:///waffle.png"} }, false},
+		{"Long image URL", func(p *models.Product) {
+			p.Image = &models.ProductImage{Thumbnail: "/" + long(MaxProductImageURLLength)}
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid
+			tt.change(&p)
+			err := ValidateProduct(p)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidProduct)
+			}
+		})
+	}
+}
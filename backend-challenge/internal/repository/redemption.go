@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRedemptionsExhausted is returned when a code reached its total cap
+	ErrRedemptionsExhausted = errors.New("promo code redemption cap reached")
+	// ErrAlreadyRedeemed is returned when a customer reached the code's per-customer limit
+	ErrAlreadyRedeemed = errors.New("promo code already redeemed by this customer")
+)
+
+// RedemptionLimits caps the redemptions of one promo code, zero is unlimited
+type RedemptionLimits struct {
+	Total       int
+	PerCustomer int
+}
+
+// Redemption is one use of a promo code by an order
+type Redemption struct {
+	Code       string
+	CustomerID string // Empty when the order names no customer
+	OrderID    string
+	At         time.Time
+}
+
+// RedemptionStore counts promo code redemptions
+type RedemptionStore interface {
+	// Redeem records a redemption unless it would exceed limits. Checking
+	// the limits and recording happen atomically.
+	Redeem(ctx context.Context, redemption Redemption, limits RedemptionLimits) error
+	// Cancel removes the redemption recorded for an order, if any
+	Cancel(ctx context.Context, code, orderID string) error
+	// Count returns how often a code was redeemed, in total and by a customer
+	Count(ctx context.Context, code, customerID string) (total, byCustomer int, err error)
+}
+
+// MemoryRedemptionStore keeps redemption counts in memory
+type MemoryRedemptionStore struct {
+	mu    sync.Mutex
+	codes map[string]*codeRedemptions
+}
+
+// codeRedemptions holds the redemptions of one code
+type codeRedemptions struct {
+	orders      map[string]string // Order ID to customer ID
+	perCustomer map[string]int
+}
+
+// NewMemoryRedemptionStore creates an empty in-memory store
+func NewMemoryRedemptionStore() *MemoryRedemptionStore {
+	return &MemoryRedemptionStore{codes: make(map[string]*codeRedemptions)}
+}
+
+// Redeem records a redemption unless it would exceed limits
+func (s *MemoryRedemptionStore) Redeem(ctx context.Context, redemption Redemption, limits RedemptionLimits) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, ok := s.codes[redemption.Code]
+	if !ok {
+		code = &codeRedemptions{orders: make(map[string]string), perCustomer: make(map[string]int)}
+		s.codes[redemption.Code] = code
+	}
+
+	if _, exists := code.orders[redemption.OrderID]; exists {
+		return nil // Already recorded for this order
+	}
+	if limits.PerCustomer > 0 && redemption.CustomerID != "" && code.perCustomer[redemption.CustomerID] >= limits.PerCustomer {
+		return ErrAlreadyRedeemed
+	}
+	if limits.Total > 0 && len(code.orders) >= limits.Total {
+		return ErrRedemptionsExhausted
+	}
+
+	code.orders[redemption.OrderID] = redemption.CustomerID
+	if redemption.CustomerID != "" {
+		code.perCustomer[redemption.CustomerID]++
+	}
+	return nil
+}
+
+// Cancel removes the redemption recorded for an order
+func (s *MemoryRedemptionStore) Cancel(ctx context.Context, code, orderID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	redemptions, ok := s.codes[code]
+	if !ok {
+		return nil
+	}
+	customerID, ok := redemptions.orders[orderID]
+	if !ok {
+		return nil
+	}
+
+	delete(redemptions.orders, orderID)
+	if customerID != "" {
+		if redemptions.perCustomer[customerID]--; redemptions.perCustomer[customerID] <= 0 {
+			delete(redemptions.perCustomer, customerID)
+		}
+	}
+	return nil
+}
+
+// Count returns how often a code was redeemed
+func (s *MemoryRedemptionStore) Count(ctx context.Context, code, customerID string) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	redemptions, ok := s.codes[code]
+	if !ok {
+		return 0, 0, nil
+	}
+	return len(redemptions.orders), redemptions.perCustomer[customerID], nil
+}
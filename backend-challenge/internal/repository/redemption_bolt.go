@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"encoding/binary"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var redemptionsBucket = []byte("redemptions")
+
+// Keys inside a code's redemption bucket
+var (
+	redemptionTotalKey  = []byte("total")
+	redemptionOrders    = []byte("orders")    // Order ID to customer ID
+	redemptionCustomers = []byte("customers") // Customer ID to count
+)
+
+// BoltRedemptionStore keeps redemption counts in the order database, so
+// caps survive restarts. bbolt serializes writers, which makes the check
+// and the update of a redemption atomic.
+type BoltRedemptionStore struct {
+	db *bolt.DB
+}
+
+// Redemptions returns a redemption store sharing the order database
+func (r *BoltOrderRepository) Redemptions() *BoltRedemptionStore {
+	return &BoltRedemptionStore{db: r.db}
+}
+
+// Redeem records a redemption unless it would exceed limits
+func (s *BoltRedemptionStore) Redeem(ctx context.Context, redemption Redemption, limits RedemptionLimits) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		code, err := tx.Bucket(redemptionsBucket).CreateBucketIfNotExists([]byte(redemption.Code))
+		if err != nil {
+			return err
+		}
+		orders, err := code.CreateBucketIfNotExists(redemptionOrders)
+		if err != nil {
+			return err
+		}
+		customers, err := code.CreateBucketIfNotExists(redemptionCustomers)
+		if err != nil {
+			return err
+		}
+
+		if orders.Get([]byte(redemption.OrderID)) != nil {
+			return nil // Already recorded for this order
+		}
+		byCustomer := readCount(customers.Get([]byte(redemption.CustomerID)))
+		if limits.PerCustomer > 0 && redemption.CustomerID != "" && byCustomer >= limits.PerCustomer {
+			return ErrAlreadyRedeemed
+		}
+		total := readCount(code.Get(redemptionTotalKey))
+		if limits.Total > 0 && total >= limits.Total {
+			return ErrRedemptionsExhausted
+		}
+
+		if err := orders.Put([]byte(redemption.OrderID), []byte(redemption.CustomerID)); err != nil {
+			return err
+		}
+		if redemption.CustomerID != "" {
+			if err := customers.Put([]byte(redemption.CustomerID), countBytes(byCustomer+1)); err != nil {
+				return err
+			}
+		}
+		return code.Put(redemptionTotalKey, countBytes(total+1))
+	})
+}
+
+// Cancel removes the redemption recorded for an order
+func (s *BoltRedemptionStore) Cancel(ctx context.Context, code, orderID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(redemptionsBucket).Bucket([]byte(code))
+		if bucket == nil {
+			return nil
+		}
+		orders := bucket.Bucket(redemptionOrders)
+		customerID := orders.Get([]byte(orderID))
+		if customerID == nil {
+			return nil
+		}
+		customerID = append([]byte(nil), customerID...) // Only valid until the delete
+
+		if err := orders.Delete([]byte(orderID)); err != nil {
+			return err
+		}
+		if len(customerID) > 0 {
+			customers := bucket.Bucket(redemptionCustomers)
+			if n := readCount(customers.Get(customerID)) - 1; n > 0 {
+				if err := customers.Put(customerID, countBytes(n)); err != nil {
+					return err
+				}
+			} else if err := customers.Delete(customerID); err != nil {
+				return err
+			}
+		}
+		return bucket.Put(redemptionTotalKey, countBytes(max(readCount(bucket.Get(redemptionTotalKey))-1, 0)))
+	})
+}
+
+// Count returns how often a code was redeemed
+func (s *BoltRedemptionStore) Count(ctx context.Context, code, customerID string) (total, byCustomer int, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(redemptionsBucket).Bucket([]byte(code))
+		if bucket == nil {
+			return nil
+		}
+		total = readCount(bucket.Get(redemptionTotalKey))
+		if customerID != "" {
+			byCustomer = readCount(bucket.Bucket(redemptionCustomers).Get([]byte(customerID)))
+		}
+		return nil
+	})
+	return total, byCustomer, err
+}
+
+// readCount decodes a stored counter, zero when missing
+func readCount(data []byte) int {
+	if len(data) != 8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(data))
+}
+
+// countBytes encodes a counter
+func countBytes(n int) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(n))
+}
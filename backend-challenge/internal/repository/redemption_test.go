@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRedemptionStore checks the behaviour every RedemptionStore shares
+func testRedemptionStore(t *testing.T, store RedemptionStore) {
+	ctx := context.Background()
+	limits := RedemptionLimits{Total: 3, PerCustomer: 1}
+	redeem := func(customer, order string) error {
+		return store.Redeem(ctx, Redemption{Code: "SPRING24", CustomerID: customer, OrderID: order, At: time.Now()}, limits)
+	}
+
+	require.NoError(t, redeem("alice", "ORD-1"))
+	assert.NoError(t, redeem("alice", "ORD-1"), "Recording the same order twice is a no-op")
+	assert.ErrorIs(t, redeem("alice", "ORD-2"), ErrAlreadyRedeemed)
+	require.NoError(t, redeem("bob", "ORD-3"))
+	require.NoError(t, redeem("", "ORD-4"), "Anonymous orders only count towards the total")
+	assert.ErrorIs(t, redeem("carol", "ORD-5"), ErrRedemptionsExhausted)
+
+	total, byAlice, err := store.Count(ctx, "SPRING24", "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 1, byAlice)
+
+	// Cancelling frees both the customer's and the total allowance
+	require.NoError(t, store.Cancel(ctx, "SPRING24", "ORD-1"))
+	require.NoError(t, store.Cancel(ctx, "SPRING24", "ORD-1"), "Cancelling twice is a no-op")
+	require.NoError(t, store.Cancel(ctx, "OTHER123", "ORD-1"))
+	require.NoError(t, redeem("alice", "ORD-6"))
+
+	total, byAlice, err = store.Count(ctx, "SPRING24", "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 1, byAlice)
+
+	// Codes are counted separately
+	total, _, err = store.Count(ctx, "OTHER123", "")
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	// Concurrent orders never exceed the cap
+	var wg sync.WaitGroup
+	var redeemed atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.Redeem(ctx, Redemption{Code: "RUSH2025", CustomerID: fmt.Sprintf("c%d", i), OrderID: fmt.Sprintf("ORD-R%d", i)},
+				RedemptionLimits{Total: 10})
+			if err == nil {
+				redeemed.Add(1)
+			} else if err != ErrRedemptionsExhausted {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(10), redeemed.Load())
+}
+
+func TestMemoryRedemptionStore(t *testing.T) {
+	testRedemptionStore(t, NewMemoryRedemptionStore())
+}
+
+func TestBoltRedemptionStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.db")
+	repo, err := NewBoltOrderRepository(path)
+	require.NoError(t, err)
+	testRedemptionStore(t, repo.Redemptions())
+	require.NoError(t, repo.Close())
+
+	// Counts survive a restart
+	reopened, err := NewBoltOrderRepository(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	total, _, err := reopened.Redemptions().Count(context.Background(), "RUSH2025", "")
+	require.NoError(t, err)
+	assert.Equal(t, 10, total)
+}
@@ -0,0 +1,50 @@
+// Package requestid carries the ID of the request being served through
+// contexts, so errors, logs and traces of one request can be tied together.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Header is the request and response header holding the ID
+const Header = "X-Request-ID"
+
+// maxLength bounds IDs accepted from clients
+const maxLength = 128
+
+// contextKey is the key of the ID in contexts
+type contextKey struct{}
+
+// New generates a random ID
+func New() string {
+	b := make([]byte, 16)
+	rand.Read(b) // Never fails, see crypto/rand.Read
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether a client supplied id can be used as is: 1 to 128
+// printable ASCII characters without spaces, so it cannot break log lines
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the ID in ctx, or "" outside of a request
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
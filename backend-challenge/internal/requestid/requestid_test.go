@@ -0,0 +1,40 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"4bf92f35-77b3-4da6-a3ce-929d0e0e4736", true},
+		{"pos-7:order/42", true},
+		{strings.Repeat("a", 128), true},
+		{"", false},
+		{strings.Repeat("a", 129), false},
+		{"two words", false},
+		{"forged\nlog line", false},
+		{"café", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Valid(tt.id), "%q", tt.id)
+	}
+}
+
+func TestNew(t *testing.T) {
+	id := New()
+	assert.Len(t, id, 32)
+	assert.True(t, Valid(id))
+	assert.NotEqual(t, id, New())
+}
+
+func TestContext(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+	assert.Equal(t, "req-1", FromContext(NewContext(context.Background(), "req-1")))
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumManifest maps coupon file names to their expected SHA-256
+type ChecksumManifest map[string]string
+
+// LoadChecksumManifest reads a manifest in sha256sum format:
+//
+//	<hex sha256>  <file name>
+//
+// Blank lines and lines starting with # are ignored. Only the base name of
+// each file is used, so a manifest generated next to the files works as is.
+func LoadChecksumManifest(path string) (ChecksumManifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checksum manifest: %w", err)
+	}
+	defer f.Close()
+
+	manifest := make(ChecksumManifest)
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("checksum manifest line %d: expected \"<sha256> <file>\"", lineNum)
+		}
+
+		sum := strings.ToLower(fields[0])
+		if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != 32 {
+			return nil, fmt.Errorf("checksum manifest line %d: invalid SHA-256 %q", lineNum, fields[0])
+		}
+
+		// sha256sum marks binary mode with a leading '*'
+		name := filepath.Base(strings.TrimPrefix(fields[1], "*"))
+		manifest[name] = sum
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read checksum manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// Verify checks a file against the manifest and returns its SHA-256.
+// Files not listed in the manifest are accepted with listed set to false.
+func (m ChecksumManifest) Verify(file CouponFile) (sum string, listed bool, err error) {
+	expected, listed := m[file.Name]
+	if !listed {
+		return "", false, nil
+	}
+
+	sum, err = fileSHA256(file.Path)
+	if err != nil {
+		return "", true, err
+	}
+	if sum != expected {
+		return sum, true, fmt.Errorf("checksum mismatch for %s: got %s, want %s", file.Name, sum, expected)
+	}
+	return sum, true, nil
+}
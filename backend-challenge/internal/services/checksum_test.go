@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadChecksumManifest(t *testing.T) {
+	dir := t.TempDir()
+	sum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	content := fmt.Sprintf("# coupon files\n%s  couponbase1.gz\n\n%s *data/couponbase2.gz\n", sum, sum)
+	path := filepath.Join(dir, "coupons.sha256")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	manifest, err := LoadChecksumManifest(path)
+	require.NoError(t, err)
+	assert.Equal(t, ChecksumManifest{"couponbase1.gz": sum, "couponbase2.gz": sum}, manifest)
+
+	require.NoError(t, os.WriteFile(path, []byte("nothex  couponbase1.gz\n"), 0o644))
+	_, err = LoadChecksumManifest(path)
+	assert.ErrorContains(t, err, "line 1")
+
+	_, err = LoadChecksumManifest(filepath.Join(dir, "missing.sha256"))
+	assert.Error(t, err)
+}
+
+func TestChecksumManifest_Verify(t *testing.T) {
+	path := writeGzipFixture(t, t.TempDir(), "couponbase1.gz", "HAPPYHRS")
+	sum, err := fileSHA256(path)
+	require.NoError(t, err)
+	file := CouponFile{Name: "couponbase1.gz", Path: path}
+
+	got, listed, err := ChecksumManifest{"couponbase1.gz": sum}.Verify(file)
+	require.NoError(t, err)
+	assert.True(t, listed)
+	assert.Equal(t, sum, got)
+
+	_, listed, err = ChecksumManifest{"other.gz": sum}.Verify(file)
+	require.NoError(t, err)
+	assert.False(t, listed, "Unlisted files are accepted unverified")
+
+	wrong := "0000000000000000000000000000000000000000000000000000000000000000"
+	_, _, err = ChecksumManifest{"couponbase1.gz": wrong}.Verify(file)
+	assert.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestPromoCodeService_RejectsFilesFailingManifest(t *testing.T) {
+	dir := t.TempDir()
+	good1 := writeGzipFixture(t, dir, "couponbase1.gz", "HAPPYHRS FIFTYOFF")
+	good2 := writeGzipFixture(t, dir, "couponbase2.gz", "HAPPYHRS")
+	writeGzipFixture(t, dir, "couponbase3.gz", "FIFTYOFF")
+
+	sum1, err := fileSHA256(good1)
+	require.NoError(t, err)
+	sum2, err := fileSHA256(good2)
+	require.NoError(t, err)
+	manifest := ChecksumManifest{
+		"couponbase1.gz": sum1,
+		"couponbase2.gz": sum2,
+		"couponbase3.gz": "0000000000000000000000000000000000000000000000000000000000000000",
+	}
+
+	service := NewPromoCodeService(
+		WithCouponSources(NewDirectorySource(dir)),
+		WithChecksumManifest(manifest),
+	)
+	err = service.loadCodes(context.Background())
+	assert.ErrorIs(t, err, ErrPartialLoad, "A rejected file makes the load partial")
+
+	assert.True(t, service.IsValidPromoCode("HAPPYHRS"))
+	assert.False(t, service.IsValidPromoCode("FIFTYOFF"), "Tampered file must not count")
+	assert.FileExists(t, filepath.Join(dir, "couponbase3.gz"), "Local source files are never deleted")
+}
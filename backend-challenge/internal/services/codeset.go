@@ -0,0 +1,200 @@
+package services
+
+import (
+	"slices"
+)
+
+// Promo codes are 8-10 characters from [A-Z0-9], so a code fits in a uint64:
+// the base-36 value takes at most 52 bits (36^10 < 2^52) and the length is
+// stored in the top byte to tell "0000000A" from "00000000A".
+const codeLengthShift = 56
+
+// encodeCode packs a promo code into a uint64 key.
+// It returns false if the code does not have a valid promo code format.
+func encodeCode(code string) (uint64, bool) {
+	if !isValidPromoCodeFormat(code) {
+		return 0, false
+	}
+
+	var value uint64
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		if c <= '9' {
+			value = value*36 + uint64(c-'0')
+		} else {
+			value = value*36 + uint64(c-'A'+10)
+		}
+	}
+	return uint64(len(code))<<codeLengthShift | value, true
+}
+
+// decodeCode unpacks a key produced by encodeCode
+func decodeCode(key uint64) string {
+	n := int(key >> codeLengthShift)
+	value := key & (1<<codeLengthShift - 1)
+
+	buf := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		digit := byte(value % 36)
+		value /= 36
+		if digit < 10 {
+			buf[i] = '0' + digit
+		} else {
+			buf[i] = 'A' + digit - 10
+		}
+	}
+	return string(buf)
+}
+
+// codeSet is an immutable set of promo codes stored as a sorted array of
+// packed keys. It takes 8 bytes per code and answers lookups with a binary
+// search, compared to roughly ten times that for a map[string]bool.
+type codeSet struct {
+	keys []uint64
+}
+
+// newCodeSet builds a set from codes, skipping invalid formats
+func newCodeSet(codes []string) *codeSet {
+	keys := make([]uint64, 0, len(codes))
+	for _, code := range codes {
+		if key, ok := encodeCode(code); ok {
+			keys = append(keys, key)
+		}
+	}
+	return &codeSet{keys: sortUnique(keys)}
+}
+
+// Contains reports whether the (uppercase) code is in the set
+func (s *codeSet) Contains(code string) bool {
+	key, ok := encodeCode(code)
+	if !ok {
+		return false
+	}
+	_, found := slices.BinarySearch(s.keys, key)
+	return found
+}
+
+// Len returns the number of codes in the set
+func (s *codeSet) Len() int {
+	return len(s.keys)
+}
+
+// Codes returns every code in the set
+func (s *codeSet) Codes() []string {
+	codes := make([]string, len(s.keys))
+	for i, key := range s.keys {
+		codes[i] = decodeCode(key)
+	}
+	return codes
+}
+
+// Union returns a new set holding the codes of both sets
+func (s *codeSet) Union(other *codeSet) *codeSet {
+	keys := make([]uint64, 0, len(s.keys)+len(other.keys))
+	keys = append(keys, s.keys...)
+	keys = append(keys, other.keys...)
+	return &codeSet{keys: sortUnique(keys)}
+}
+
+// fileCodeCollector gathers the distinct keys found in one coupon file.
+// Duplicates are compacted whenever the buffer doubles, so memory stays
+// proportional to the distinct codes rather than every word in the file.
+type fileCodeCollector struct {
+	keys      []uint64
+	compactAt int
+}
+
+// Add records a code, ignoring invalid formats
+func (c *fileCodeCollector) Add(code string) {
+	key, ok := encodeCode(code)
+	if !ok {
+		return
+	}
+
+	c.keys = append(c.keys, key)
+	if len(c.keys) >= c.compactAt {
+		c.keys = sortUnique(c.keys)
+		c.compactAt = max(2*len(c.keys), 1<<16)
+	}
+}
+
+// Keys returns the sorted distinct keys collected so far
+func (c *fileCodeCollector) Keys() []uint64 {
+	c.keys = sortUnique(c.keys)
+	return c.keys
+}
+
+// codeCounter sums, for each code, the weights of the files it occurs in.
+// Files are merged one at a time, so only the running totals and the current
+// file's keys are ever in memory.
+type codeCounter struct {
+	keys   []uint64
+	counts []uint8
+}
+
+// Add merges the sorted distinct keys of one file with the given weight into the totals
+func (c *codeCounter) Add(fileKeys []uint64, weight int) {
+	fileWeight := uint8(min(max(weight, 0), 255))
+
+	n, m := len(c.keys), len(fileKeys)
+	total := n + m
+
+	// Merge from the back into the grown slices so existing totals are never copied
+	c.keys = slices.Grow(c.keys, m)[:total]
+	c.counts = slices.Grow(c.counts, m)[:total]
+
+	i, j, w := n-1, m-1, total
+	for j >= 0 {
+		w--
+		switch {
+		case i >= 0 && c.keys[i] > fileKeys[j]:
+			c.keys[w], c.counts[w] = c.keys[i], c.counts[i]
+			i--
+		case i >= 0 && c.keys[i] == fileKeys[j]:
+			c.keys[w], c.counts[w] = c.keys[i], saturatingAdd(c.counts[i], fileWeight)
+			i--
+			j--
+		default:
+			c.keys[w], c.counts[w] = fileKeys[j], fileWeight
+			j--
+		}
+	}
+
+	// Keys shared with the file leave a gap between the untouched prefix and the merged tail
+	if gap := w - (i + 1); gap > 0 {
+		copy(c.keys[i+1:], c.keys[w:total])
+		copy(c.counts[i+1:], c.counts[w:total])
+		c.keys = c.keys[:total-gap]
+		c.counts = c.counts[:total-gap]
+	}
+}
+
+// Len returns the number of distinct codes seen
+func (c *codeCounter) Len() int {
+	return len(c.keys)
+}
+
+// AtLeast returns the set of codes whose summed weight reaches minWeight
+func (c *codeCounter) AtLeast(minWeight int) *codeSet {
+	var keys []uint64
+	for i, count := range c.counts {
+		if int(count) >= minWeight {
+			keys = append(keys, c.keys[i])
+		}
+	}
+	return &codeSet{keys: keys}
+}
+
+// sortUnique sorts keys and removes duplicates in place
+func sortUnique(keys []uint64) []uint64 {
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
+
+// saturatingAdd adds to a count without wrapping around
+func saturatingAdd(n, delta uint8) uint8 {
+	if n > 255-delta {
+		return 255
+	}
+	return n + delta
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const codeAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// randomCodes generates n valid-format codes of 8-10 characters
+func randomCodes(rng *rand.Rand, n int) []string {
+	codes := make([]string, n)
+	buf := make([]byte, 10)
+	for i := range codes {
+		length := 8 + rng.Intn(3)
+		for j := 0; j < length; j++ {
+			buf[j] = codeAlphabet[rng.Intn(len(codeAlphabet))]
+		}
+		codes[i] = string(buf[:length])
+	}
+	return codes
+}
+
+func TestEncodeDecodeCode(t *testing.T) {
+	tests := []struct {
+		code  string
+		valid bool
+	}{
+		{"HAPPYHRS", true},
+		{"00000000", true},
+		{"000000000", true},
+		{"ZZZZZZZZZZ", true},
+		{"DISCOUNT10", true},
+		{"SHORT", false},
+		{"VERYLONGCODE", false},
+		{"happyhrs", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			key, ok := encodeCode(tt.code)
+			assert.Equal(t, tt.valid, ok)
+			if ok {
+				assert.Equal(t, tt.code, decodeCode(key))
+			}
+		})
+	}
+
+	// Leading zeros of different lengths must not collide
+	a, _ := encodeCode("0000000A")
+	b, _ := encodeCode("00000000A")
+	assert.NotEqual(t, a, b)
+}
+
+func TestCodeSet(t *testing.T) {
+	set := newCodeSet([]string{"HAPPYHRS", "FIFTYOFF", "HAPPYHRS", "BAD"})
+
+	assert.Equal(t, 2, set.Len())
+	assert.True(t, set.Contains("HAPPYHRS"))
+	assert.True(t, set.Contains("FIFTYOFF"))
+	assert.False(t, set.Contains("NOTFOUND"))
+	assert.False(t, set.Contains("BAD"))
+	assert.ElementsMatch(t, []string{"HAPPYHRS", "FIFTYOFF"}, set.Codes())
+
+	union := set.Union(newCodeSet([]string{"FIFTYOFF", "WELCOME1"}))
+	assert.Equal(t, 3, union.Len())
+	assert.True(t, union.Contains("WELCOME1"))
+	assert.Equal(t, 2, set.Len(), "Union should not modify the receiver")
+}
+
+func TestCodeCounter_MatchesMapCounting(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+
+	// Overlapping pools so codes appear in one, two or all files
+	pool := randomCodes(rng, 5000)
+	expected := make(map[string]int)
+	counter := &codeCounter{}
+
+	for file := 0; file < 4; file++ {
+		var collector fileCodeCollector
+		seen := make(map[string]bool)
+		for i := 0; i < 50000; i++ {
+			code := pool[rng.Intn(len(pool))]
+			collector.Add(code)
+			seen[code] = true
+		}
+		for code := range seen {
+			expected[code]++
+		}
+
+		keys := collector.Keys()
+		require.Len(t, keys, len(seen))
+		counter.Add(keys, 1)
+	}
+
+	assert.Equal(t, len(expected), counter.Len())
+
+	for _, minFiles := range []int{1, 2, 3, 4} {
+		var want []string
+		for code, count := range expected {
+			if count >= minFiles {
+				want = append(want, code)
+			}
+		}
+		got := counter.AtLeast(minFiles).Codes()
+		slices.Sort(want)
+		slices.Sort(got)
+		assert.Equal(t, want, got, "minFiles=%d", minFiles)
+	}
+}
+
+func TestCodeCounter_DisjointAndEmptyFiles(t *testing.T) {
+	counter := &codeCounter{}
+	counter.Add(nil, 1)
+	counter.Add(newCodeSet([]string{"BBBBBBBB", "DDDDDDDD"}).keys, 1)
+	counter.Add(newCodeSet([]string{"AAAAAAAA", "CCCCCCCC", "EEEEEEEE"}).keys, 1)
+	counter.Add(newCodeSet([]string{"CCCCCCCC"}).keys, 1)
+
+	assert.Equal(t, 5, counter.Len())
+	assert.Equal(t, []string{"CCCCCCCC"}, counter.AtLeast(2).Codes())
+	assert.Equal(t, []string{"AAAAAAAA", "BBBBBBBB", "CCCCCCCC", "DDDDDDDD", "EEEEEEEE"}, counter.AtLeast(1).Codes())
+}
+
+func TestCodeCounter_Weights(t *testing.T) {
+	counter := &codeCounter{}
+	counter.Add(newCodeSet([]string{"AAAAAAAA", "BBBBBBBB"}).keys, 2)
+	counter.Add(newCodeSet([]string{"BBBBBBBB", "CCCCCCCC"}).keys, 1)
+	counter.Add(newCodeSet([]string{"CCCCCCCC"}).keys, 1)
+
+	assert.Equal(t, []string{"AAAAAAAA", "BBBBBBBB", "CCCCCCCC"}, counter.AtLeast(2).Codes())
+	assert.Equal(t, []string{"BBBBBBBB"}, counter.AtLeast(3).Codes())
+
+	// Totals saturate instead of wrapping around
+	for i := 0; i < 3; i++ {
+		counter.Add(newCodeSet([]string{"AAAAAAAA"}).keys, 200)
+	}
+	assert.Equal(t, []string{"AAAAAAAA"}, counter.AtLeast(255).Codes())
+}
+
+// heapInUse returns live heap bytes after a full collection
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// BenchmarkValidCodes compares the previous map[string]bool storage with the
+// packed codeSet. Run with: go test -bench ValidCodes -run ^$ ./internal/services
+func BenchmarkValidCodes(b *testing.B) {
+	for _, size := range []int{100_000, 1_000_000} {
+		codes := randomCodes(rand.New(rand.NewSource(1)), size)
+		probes := append(randomCodes(rand.New(rand.NewSource(2)), 1024), codes[:1024]...)
+
+		b.Run(fmt.Sprintf("map/%d", size), func(b *testing.B) {
+			before := heapInUse()
+			set := make(map[string]bool, size)
+			for _, code := range codes {
+				set[code] = true
+			}
+			heapPerCode := float64(heapInUse()-before) / float64(len(set))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = set[probes[i%len(probes)]]
+			}
+			b.ReportMetric(heapPerCode, "heap-B/code")
+			runtime.KeepAlive(set)
+		})
+
+		b.Run(fmt.Sprintf("codeset/%d", size), func(b *testing.B) {
+			before := heapInUse()
+			set := newCodeSet(codes)
+			heapPerCode := float64(heapInUse()-before) / float64(set.Len())
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = set.Contains(probes[i%len(probes)])
+			}
+			b.ReportMetric(heapPerCode, "heap-B/code")
+			runtime.KeepAlive(set)
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/tracing"
+
+	"github.com/cavaliergopher/grab/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultCouponSourceSpecs lists the coupon files published by the challenge
+var DefaultCouponSourceSpecs = []string{
+	"https://orderfoodonline-files.s3.ap-southeast-2.amazonaws.com/couponbase1.gz",
+	"https://orderfoodonline-files.s3.ap-southeast-2.amazonaws.com/couponbase2.gz",
+	"https://orderfoodonline-files.s3.ap-southeast-2.amazonaws.com/couponbase3.gz",
+}
+
+// CouponFile is a gzipped coupon file available on local disk
+type CouponFile struct {
+	Name   string // Human readable name used in logs and status
+	Source string // Name of the source that provided the file
+	Path   string // Local path of the gzipped file
+	ETag   string // Entity tag reported by the origin, if any
+
+	SHA256 string // Hex SHA-256 of the file once verified, if known
+	Weight int    // How many files this file counts as for the validity rule
+}
+
+// CouponSource provides one or more gzipped coupon files.
+// Every file returned counts as a separate file for the promo validity rule.
+type CouponSource interface {
+	// Name identifies the source in logs
+	Name() string
+	// Fetch makes the source's files available locally. Sources that need to
+	// download data store it under workDir.
+	Fetch(ctx context.Context, workDir string) ([]CouponFile, error)
+}
+
+// NewCouponSource builds a source from a spec string:
+//   - http:// and https:// URLs are downloaded
+//   - file://<path> is a single local file
+//   - dir://<path> is every *.gz file in a local directory
+//   - any other value is a local path, either a file or a directory
+//
+// A "#weight=N" suffix makes every file of the source count N times.
+func NewCouponSource(spec string) (CouponSource, error) {
+	spec, weight, err := splitWeight(strings.TrimSpace(spec))
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := newUnweightedSource(spec)
+	if err != nil || weight == 1 {
+		return source, err
+	}
+	return Weighted(source, weight), nil
+}
+
+// newUnweightedSource builds a source from a spec without weight suffix
+func newUnweightedSource(spec string) (CouponSource, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("empty coupon source")
+	}
+
+	switch {
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return NewHTTPSource(spec)
+	case strings.HasPrefix(spec, "file://"):
+		return NewFileSource(strings.TrimPrefix(spec, "file://")), nil
+	case strings.HasPrefix(spec, "dir://"):
+		return NewDirectorySource(strings.TrimPrefix(spec, "dir://")), nil
+	}
+
+	info, err := os.Stat(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid coupon source %q: %w", spec, err)
+	}
+	if info.IsDir() {
+		return NewDirectorySource(spec), nil
+	}
+	return NewFileSource(spec), nil
+}
+
+// NewCouponSources builds sources for every spec, failing on the first invalid one
+func NewCouponSources(specs []string) ([]CouponSource, error) {
+	sources := make([]CouponSource, 0, len(specs))
+	for _, spec := range specs {
+		source, err := NewCouponSource(spec)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// FileSource reads a single gzipped coupon file from local disk
+type FileSource struct {
+	path string
+}
+
+// NewFileSource creates a source for a local file
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+// Name returns the file path
+func (s *FileSource) Name() string {
+	return s.path
+}
+
+// Fetch checks that the file exists and returns it as is
+func (s *FileSource) Fetch(ctx context.Context, workDir string) ([]CouponFile, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("coupon file unavailable: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("coupon file %s is a directory", s.path)
+	}
+
+	return []CouponFile{{Name: filepath.Base(s.path), Path: s.path}}, nil
+}
+
+// DirectorySource reads every gzipped coupon file in a local directory
+type DirectorySource struct {
+	dir     string
+	pattern string
+}
+
+// NewDirectorySource creates a source matching *.gz files in dir
+func NewDirectorySource(dir string) *DirectorySource {
+	return &DirectorySource{dir: dir, pattern: "*.gz"}
+}
+
+// Name returns the directory path
+func (s *DirectorySource) Name() string {
+	return s.dir
+}
+
+// Fetch lists matching files in name order so results are deterministic
+func (s *DirectorySource) Fetch(ctx context.Context, workDir string) ([]CouponFile, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, s.pattern))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", s.dir, err)
+	}
+	sort.Strings(matches)
+
+	var files []CouponFile
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, CouponFile{Name: filepath.Base(match), Path: match})
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no coupon files matching %s in %s", s.pattern, s.dir)
+	}
+	return files, nil
+}
+
+// HTTPSource downloads a gzipped coupon file over HTTP(S) using grab
+type HTTPSource struct {
+	url     string
+	client  *grab.Client
+	timeout time.Duration
+}
+
+// NewHTTPSource creates a source for a remote file
+func NewHTTPSource(rawURL string) (*HTTPSource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid coupon source URL %q: %w", rawURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported coupon source scheme %q", u.Scheme)
+	}
+
+	client := grab.NewClient()
+	client.UserAgent = "OolioFoodAPI/1.0"
+
+	return &HTTPSource{
+		url:     rawURL,
+		client:  client,
+		timeout: 20 * time.Minute,
+	}, nil
+}
+
+// Name returns the source URL
+func (s *HTTPSource) Name() string {
+	return s.url
+}
+
+// Fetch downloads the file into workDir.
+//
+// workDir may persist between loads. A completed download is kept as <name>
+// with its ETag in <name>.etag and is reused while the origin reports the same
+// ETag. An interrupted download stays in <name>.part and is resumed with a
+// range request on the next attempt.
+func (s *HTTPSource) Fetch(ctx context.Context, workDir string) ([]CouponFile, error) {
+	name := s.fileName()
+	final := filepath.Join(workDir, name)
+	part := final + ".part"
+
+	// Set timeout for background download
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	// Revalidate cached data against the origin
+	var remoteETag string
+	if fileExists(final) || fileExists(part) {
+		remoteETag = s.remoteETag(ctx)
+	}
+	tracker := downloadTrackerFrom(ctx)
+	logger := logging.FromContext(ctx, slog.Default()).With("file", name)
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("promo.file", name))
+	if cached := readETag(final); cached != "" && cached == remoteETag && fileExists(final) {
+		logger.Info("Coupon file unchanged since last download, using cache")
+		span.SetAttributes(attribute.Bool("promo.cached", true))
+		if info, err := os.Stat(final); err == nil {
+			tracker.Update(DownloadProgress{
+				Source: s.url, File: name, State: DownloadCached,
+				BytesComplete: info.Size(), Size: info.Size(), Percent: 100,
+			})
+		}
+		return []CouponFile{{Name: name, Path: final, ETag: cached}}, nil
+	}
+	if partial := readETag(part); partial != "" && remoteETag != "" && partial != remoteETag {
+		// Never resume on top of bytes from an older version of the file
+		logger.Info("Coupon file changed since interrupted download, restarting")
+		os.Remove(part)
+	}
+
+	req, err := grab.NewRequest(part, s.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download request: %w", err)
+	}
+	req = req.WithContext(ctx)
+	tracing.Propagator.Inject(ctx, propagation.HeaderCarrier(req.HTTPRequest.Header))
+
+	logger.Info("Starting coupon file download", "url", s.url)
+
+	resp := s.client.Do(req)
+	tracker.Update(grabProgress(s.url, name, resp))
+
+	// Report progress every second, log it less often for background
+	progressTicker := time.NewTicker(time.Second)
+	defer progressTicker.Stop()
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	reporterDone := make(chan struct{})
+	go func() {
+		defer close(reporterDone)
+		for {
+			select {
+			case <-progressTicker.C:
+				tracker.Update(grabProgress(s.url, name, resp))
+			case <-ticker.C:
+				if !resp.IsComplete() {
+					if resp.Size() > 0 {
+						logger.Info("Coupon file download progress", "percent", math.Round(100*resp.Progress()))
+					} else {
+						logger.Info("Coupon file download progress", "bytes", resp.BytesComplete())
+					}
+				}
+			case <-resp.Done:
+				return
+			}
+		}
+	}()
+
+	err = resp.Err()
+	<-reporterDone // The final progress below must not be overwritten
+
+	// Remember which version the partial bytes belong to, even on failure
+	etag := remoteETag
+	if resp.HTTPResponse != nil && resp.HTTPResponse.Header.Get("ETag") != "" {
+		etag = resp.HTTPResponse.Header.Get("ETag")
+	}
+	writeETag(logger, part, etag)
+
+	progress := grabProgress(s.url, name, resp)
+	progress.State = DownloadComplete
+	if err != nil {
+		progress.State = DownloadFailed
+		progress.Error = err.Error()
+	}
+	tracker.Update(progress)
+
+	if err != nil {
+		return nil, fmt.Errorf("background download failed (%.1f MB kept for resume): %w",
+			float64(resp.BytesComplete())/(1024*1024), err)
+	}
+
+	logger.Info("Coupon file downloaded", "bytes", resp.Size(), "resumed", resp.DidResume)
+	span.SetAttributes(attribute.Int64("promo.bytes", resp.BytesComplete()), attribute.Bool("promo.resumed", resp.DidResume))
+
+	if err := os.Rename(part, final); err != nil {
+		return nil, fmt.Errorf("failed to move download into place: %w", err)
+	}
+	os.Remove(part + ".etag")
+	writeETag(logger, final, etag)
+
+	return []CouponFile{{Name: name, Path: final, ETag: etag}}, nil
+}
+
+// remoteETag asks the origin for the current ETag, returning "" if unknown
+func (s *HTTPSource) remoteETag(ctx context.Context) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.url, nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("User-Agent", s.client.UserAgent)
+	tracing.Propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := s.client.HTTPClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	return resp.Header.Get("ETag")
+}
+
+// fileName derives a local file name from the URL path
+func (s *HTTPSource) fileName() string {
+	u, err := url.Parse(s.url)
+	if err == nil {
+		if base := path.Base(u.Path); base != "" && base != "/" && base != "." {
+			return base
+		}
+	}
+	return "coupons.gz"
+}
+
+// readETag returns the ETag stored next to path, or ""
+func readETag(path string) string {
+	data, err := os.ReadFile(path + ".etag")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// writeETag stores etag next to path; an empty etag removes it
+func writeETag(logger *slog.Logger, path, etag string) {
+	if etag == "" {
+		os.Remove(path + ".etag")
+		return
+	}
+	if err := os.WriteFile(path+".etag", []byte(etag), 0o644); err != nil {
+		logger.Warn("Failed to record ETag", "path", path, "error", err)
+	}
+}
+
+// fileExists reports whether path is an existing regular file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeGzipFixture writes content as a gzipped file and returns its path
+func writeGzipFixture(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	return path
+}
+
+func TestNewCouponSource(t *testing.T) {
+	dir := t.TempDir()
+	file := writeGzipFixture(t, dir, "coupons.gz", "HAPPYHRS")
+
+	tests := []struct {
+		name     string
+		spec     string
+		expected CouponSource
+		wantErr  bool
+	}{
+		{"HTTPS URL", "https://example.com/couponbase1.gz", &HTTPSource{}, false},
+		{"HTTP URL", "http://localhost:9000/couponbase1.gz", &HTTPSource{}, false},
+		{"File scheme", "file://" + file, &FileSource{}, false},
+		{"Dir scheme", "dir://" + dir, &DirectorySource{}, false},
+		{"Bare file path", file, &FileSource{}, false},
+		{"Bare directory path", dir, &DirectorySource{}, false},
+		{"Missing path", filepath.Join(dir, "missing.gz"), nil, true},
+		{"Empty spec", "  ", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewCouponSource(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, tt.expected, source)
+		})
+	}
+}
+
+func TestFileSource_Fetch(t *testing.T) {
+	dir := t.TempDir()
+	path := writeGzipFixture(t, dir, "couponbase1.gz", "HAPPYHRS")
+
+	files, err := NewFileSource(path).Fetch(context.Background(), t.TempDir())
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, path, files[0].Path)
+	assert.Equal(t, "couponbase1.gz", files[0].Name)
+
+	_, err = NewFileSource(filepath.Join(dir, "missing.gz")).Fetch(context.Background(), t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestDirectorySource_Fetch(t *testing.T) {
+	dir := t.TempDir()
+	writeGzipFixture(t, dir, "b.gz", "FIFTYOFF")
+	writeGzipFixture(t, dir, "a.gz", "HAPPYHRS")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o644))
+
+	files, err := NewDirectorySource(dir).Fetch(context.Background(), t.TempDir())
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "a.gz", files[0].Name)
+	assert.Equal(t, "b.gz", files[1].Name)
+
+	_, err = NewDirectorySource(t.TempDir()).Fetch(context.Background(), t.TempDir())
+	assert.Error(t, err, "Empty directory should be an error")
+}
+
+func TestHTTPSource_Fetch(t *testing.T) {
+	fixture := writeGzipFixture(t, t.TempDir(), "couponbase1.gz", "HAPPYHRS FIFTYOFF")
+	server := httptest.NewServer(http.FileServer(http.Dir(filepath.Dir(fixture))))
+	defer server.Close()
+
+	source, err := NewHTTPSource(server.URL + "/couponbase1.gz")
+	require.NoError(t, err)
+
+	workDir := t.TempDir()
+	files, err := source.Fetch(context.Background(), workDir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, filepath.Join(workDir, "couponbase1.gz"), files[0].Path)
+
+	keys, err := NewPromoCodeService().processGzipFile(files[0].Path)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"HAPPYHRS", "FIFTYOFF"}, (&codeSet{keys: keys}).Codes())
+
+	missing, err := NewHTTPSource(server.URL + "/missing.gz")
+	require.NoError(t, err)
+	_, err = missing.Fetch(context.Background(), t.TempDir())
+	assert.Error(t, err)
+}
+
+// rangeServer serves one file with ETag and range support, and can abort
+// the next full download halfway through
+type rangeServer struct {
+	mu        sync.Mutex
+	data      []byte
+	etag      string
+	abortNext bool
+	gets      int
+	ranges    []string
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, etag := s.data, s.etag
+	abort := s.abortNext && r.Method == http.MethodGet
+	if r.Method == http.MethodGet {
+		s.gets++
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		s.abortNext = false
+	}
+	s.mu.Unlock()
+
+	if abort {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(data[:len(data)/2])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "couponbase1.gz", time.Time{}, bytes.NewReader(data))
+}
+
+// getCount returns the GET requests served so far and their Range headers
+func (s *rangeServer) getCount() (int, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets, append([]string(nil), s.ranges...)
+}
+
+// gzipBytes compresses content with no compression so sizes stay predictable
+func gzipBytes(t *testing.T, content string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, gzip.NoCompression)
+	require.NoError(t, err)
+	_, err = gz.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestHTTPSource_ResumesInterruptedDownload(t *testing.T) {
+	content := strings.Repeat("HAPPYHRS filler ", 20000)
+	srv := &rangeServer{data: gzipBytes(t, content), etag: `"v1"`, abortNext: true}
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	source, err := NewHTTPSource(server.URL + "/couponbase1.gz")
+	require.NoError(t, err)
+	cacheDir := t.TempDir()
+
+	// First attempt is cut off and leaves partial data behind
+	_, err = source.Fetch(context.Background(), cacheDir)
+	require.Error(t, err)
+	partial, err := os.ReadFile(filepath.Join(cacheDir, "couponbase1.gz.part"))
+	require.NoError(t, err)
+	require.NotEmpty(t, partial)
+	require.Less(t, len(partial), len(srv.data))
+
+	// Second attempt asks only for the missing bytes
+	files, err := source.Fetch(context.Background(), cacheDir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, `"v1"`, files[0].ETag)
+	_, ranges := srv.getCount()
+	assert.Equal(t, fmt.Sprintf("bytes=%d-", len(partial)), ranges[len(ranges)-1])
+
+	downloaded, err := os.ReadFile(files[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, srv.data, downloaded, "Resumed file should match the original")
+	assert.NoFileExists(t, filepath.Join(cacheDir, "couponbase1.gz.part"))
+}
+
+func TestHTTPSource_ReusesCacheUntilETagChanges(t *testing.T) {
+	srv := &rangeServer{data: gzipBytes(t, "HAPPYHRS"), etag: `"v1"`}
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	source, err := NewHTTPSource(server.URL + "/couponbase1.gz")
+	require.NoError(t, err)
+	cacheDir := t.TempDir()
+
+	_, err = source.Fetch(context.Background(), cacheDir)
+	require.NoError(t, err)
+	gets, _ := srv.getCount()
+	require.Equal(t, 1, gets)
+
+	// Same ETag: served from cache without a GET
+	files, err := source.Fetch(context.Background(), cacheDir)
+	require.NoError(t, err)
+	gets, _ = srv.getCount()
+	assert.Equal(t, 1, gets)
+	assert.Equal(t, `"v1"`, files[0].ETag)
+
+	// New ETag: downloaded again
+	srv.mu.Lock()
+	srv.data, srv.etag = gzipBytes(t, "FIFTYOFF"), `"v2"`
+	srv.mu.Unlock()
+
+	files, err = source.Fetch(context.Background(), cacheDir)
+	require.NoError(t, err)
+	gets, _ = srv.getCount()
+	assert.Equal(t, 2, gets)
+	assert.Equal(t, `"v2"`, files[0].ETag)
+
+	downloaded, err := os.ReadFile(files[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, srv.data, downloaded)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/utils"
+)
+
+// ErrInvalidCodeFormat is returned for codes that cannot be promo codes
+var ErrInvalidCodeFormat = errors.New("promo codes are 8-10 characters from A-Z and 0-9")
+
+// CodeCheck explains the validity of one promo code
+type CodeCheck struct {
+	Code       string
+	Valid      bool   // Accepted by the codes being served
+	DataSource string // Origin of the codes being served
+	MinWeight  int    // Summed file weight a code needs
+	Weight     int    // Summed weight of the files containing the code
+	FoundIn    []CouponFile
+	Unchecked  []CouponFile // Files of the last load no longer on disk
+}
+
+// CheckCode reports whether a code is valid and which files of the last
+// load contain it. Files are scanned on demand, so downloads are only
+// checked while they are kept on disk, i.e. with a cache directory.
+func (p *PromoCodeService) CheckCode(ctx context.Context, code string) (CodeCheck, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !isValidPromoCodeFormat(code) {
+		return CodeCheck{}, ErrInvalidCodeFormat
+	}
+
+	check := CodeCheck{
+		Code:      code,
+		Valid:     p.isLoadedCode(code),
+		MinWeight: p.policy.MinWeight,
+		FoundIn:   []CouponFile{},
+	}
+	p.codesMutex.RLock()
+	check.DataSource = p.dataSource
+	p.codesMutex.RUnlock()
+
+	p.errorMutex.RLock()
+	files := p.lastFiles
+	p.errorMutex.RUnlock()
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return CodeCheck{}, err
+		}
+
+		found, err := fileContainsCode(file.Path, code)
+		if errors.Is(err, os.ErrNotExist) {
+			check.Unchecked = append(check.Unchecked, file)
+			continue
+		}
+		if err != nil {
+			return CodeCheck{}, err
+		}
+		if found {
+			check.FoundIn = append(check.FoundIn, file)
+			check.Weight += file.Weight
+		}
+	}
+
+	return check, nil
+}
+
+// fileContainsCode scans a gzipped coupon file for code, stopping at the
+// first match
+func fileContainsCode(path, code string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer gzReader.Close()
+
+	scanner := utils.NewCouponScanner(gzReader)
+	for scanner.Scan() {
+		if scanner.Text() == code {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return false, nil
+}
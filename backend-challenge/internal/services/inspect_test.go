@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromoCodeService_CheckCode(t *testing.T) {
+	dir := t.TempDir()
+	writeGzipFixture(t, dir, "couponbase1.gz", "HAPPYHRS FIFTYOFF")
+	writeGzipFixture(t, dir, "couponbase2.gz", "happyhrs, SUPER100")
+	third := writeGzipFixture(t, dir, "couponbase3.gz", "SUPER100")
+
+	service := NewPromoCodeService(WithCouponSources(NewDirectorySource(dir)))
+	require.NoError(t, service.loadCodes(context.Background()))
+
+	check, err := service.CheckCode(context.Background(), " happyhrs ")
+	require.NoError(t, err)
+	assert.Equal(t, "HAPPYHRS", check.Code)
+	assert.True(t, check.Valid)
+	assert.Equal(t, "remote", check.DataSource)
+	assert.Equal(t, 2, check.MinWeight)
+	assert.Equal(t, 2, check.Weight)
+	require.Len(t, check.FoundIn, 2)
+	assert.Equal(t, "couponbase1.gz", check.FoundIn[0].Name)
+	assert.Equal(t, dir, check.FoundIn[0].Source)
+	assert.Equal(t, "couponbase2.gz", check.FoundIn[1].Name)
+
+	check, err = service.CheckCode(context.Background(), "FIFTYOFF")
+	require.NoError(t, err)
+	assert.False(t, check.Valid)
+	assert.Equal(t, 1, check.Weight, "Found in one file only")
+
+	// Files gone from disk are reported rather than failing the check
+	require.NoError(t, os.Remove(third))
+	check, err = service.CheckCode(context.Background(), "SUPER100")
+	require.NoError(t, err)
+	assert.Equal(t, 1, check.Weight)
+	require.Len(t, check.Unchecked, 1)
+	assert.Equal(t, "couponbase3.gz", check.Unchecked[0].Name)
+
+	_, err = service.CheckCode(context.Background(), "bad!")
+	assert.ErrorIs(t, err, ErrInvalidCodeFormat)
+}
+
+func TestPromoCodeService_CheckCodeBeforeLoad(t *testing.T) {
+	service := NewPromoCodeService()
+	service.LoadMockPromoCodes()
+
+	check, err := service.CheckCode(context.Background(), "HAPPYHRS")
+	require.NoError(t, err)
+	assert.True(t, check.Valid)
+	assert.Equal(t, "mock", check.DataSource)
+	assert.Empty(t, check.FoundIn, "No files loaded yet")
+}
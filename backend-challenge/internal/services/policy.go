@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PartialPolicy decides what the service serves when some coupon files could
+// not be fetched, verified or parsed
+type PartialPolicy string
+
+const (
+	// PartialFailClosed serves no codes at all until a complete load succeeds
+	PartialFailClosed PartialPolicy = "fail_closed"
+	// PartialSnapshot keeps serving the last complete snapshot, failing closed without one
+	PartialSnapshot PartialPolicy = "snapshot"
+	// PartialDegrade applies the unchanged threshold to the files that did load
+	PartialDegrade PartialPolicy = "degrade"
+)
+
+// ErrPartialLoad reports that a load was missing some coupon files
+var ErrPartialLoad = errors.New("partial coupon load")
+
+// ParsePartialPolicy validates a policy name
+func ParsePartialPolicy(name string) (PartialPolicy, error) {
+	switch policy := PartialPolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case PartialFailClosed, PartialSnapshot, PartialDegrade:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown partial load policy %q (want fail_closed, snapshot or degrade)", name)
+}
+
+// PromoPolicy is the rule that makes a code valid.
+// A code is valid when the weights of the files containing it add up to at
+// least MinWeight. Every file weighs 1 unless its source is Weighted, so the
+// default MinWeight of 2 means "found in at least two files", and a trusted
+// source with weight 2 is enough on its own.
+type PromoPolicy struct {
+	MinWeight     int
+	PartialPolicy PartialPolicy
+}
+
+// DefaultPromoPolicy is the challenge rule: a code must be in at least two files
+func DefaultPromoPolicy() PromoPolicy {
+	return PromoPolicy{MinWeight: 2, PartialPolicy: PartialDegrade}
+}
+
+// rule describes the policy in snapshot fingerprints
+func (p PromoPolicy) rule() string {
+	return fmt.Sprintf("min-weight=%d", p.MinWeight)
+}
+
+// weightedSource gives every file of a source the same weight
+type weightedSource struct {
+	CouponSource
+	weight int
+}
+
+// Weighted wraps a source so each of its files counts weight times
+func Weighted(source CouponSource, weight int) CouponSource {
+	return &weightedSource{CouponSource: source, weight: weight}
+}
+
+// Weight returns the weight of the source's files
+func (s *weightedSource) Weight() int {
+	return s.weight
+}
+
+// sourceWeight returns the weight of a source's files, 1 by default
+func sourceWeight(source CouponSource) int {
+	if w, ok := source.(interface{ Weight() int }); ok {
+		return w.Weight()
+	}
+	return 1
+}
+
+// splitWeight separates an optional "#weight=N" suffix from a source spec
+func splitWeight(spec string) (string, int, error) {
+	i := strings.LastIndex(spec, "#weight=")
+	if i < 0 {
+		return spec, 1, nil
+	}
+
+	weight, err := strconv.Atoi(spec[i+len("#weight="):])
+	if err != nil || weight < 1 || weight > 255 {
+		return "", 0, fmt.Errorf("invalid weight in coupon source %q: want 1-255", spec)
+	}
+	return spec[:i], weight, nil
+}
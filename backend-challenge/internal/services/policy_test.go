@@ -0,0 +1,201 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePartialPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected PartialPolicy
+		wantErr  bool
+	}{
+		{"fail_closed", PartialFailClosed, false},
+		{"snapshot", PartialSnapshot, false},
+		{" Degrade ", PartialDegrade, false},
+		{"lower-threshold", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParsePartialPolicy(tt.name)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, policy)
+		})
+	}
+}
+
+func TestNewCouponSource_Weight(t *testing.T) {
+	dir := t.TempDir()
+	file := writeGzipFixture(t, dir, "coupons.gz", "HAPPYHRS")
+
+	source, err := NewCouponSource("https://example.com/campaign.gz#weight=2")
+	require.NoError(t, err)
+	assert.Equal(t, 2, sourceWeight(source))
+	assert.Equal(t, "https://example.com/campaign.gz", source.Name())
+
+	source, err = NewCouponSource(file + "#weight=3")
+	require.NoError(t, err)
+	assert.Equal(t, 3, sourceWeight(source))
+	assert.Equal(t, file, source.Name())
+
+	source, err = NewCouponSource(file)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sourceWeight(source))
+	assert.IsType(t, &FileSource{}, source)
+
+	for _, spec := range []string{file + "#weight=0", file + "#weight=x", file + "#weight=256"} {
+		_, err := NewCouponSource(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestPromoCodeService_TrustedSourceCountsAlone(t *testing.T) {
+	dir := t.TempDir()
+	trusted := writeGzipFixture(t, t.TempDir(), "campaign.gz", "SPRING24 HAPPYHRS")
+	writeGzipFixture(t, dir, "couponbase1.gz", "HAPPYHRS FIFTYOFF ONLYONCE1")
+	writeGzipFixture(t, dir, "couponbase2.gz", "FIFTYOFF")
+
+	service := NewPromoCodeService(WithCouponSources(
+		Weighted(NewFileSource(trusted), 2),
+		NewDirectorySource(dir),
+	))
+	require.NoError(t, service.loadCodes(context.Background()))
+
+	assert.True(t, service.IsValidPromoCode("SPRING24"), "Weight 2 source is enough alone")
+	assert.True(t, service.IsValidPromoCode("HAPPYHRS"))
+	assert.True(t, service.IsValidPromoCode("FIFTYOFF"))
+	assert.False(t, service.IsValidPromoCode("ONLYONCE1"))
+
+	policy := service.GetServiceStatus().Policy
+	assert.Equal(t, 2, policy.MinWeight)
+	assert.Equal(t, "degrade", policy.PartialPolicy)
+	assert.Equal(t, map[string]int{trusted: 2, dir: 1}, policy.SourceWeights)
+	assert.Equal(t, 3, policy.FilesLoaded)
+	assert.False(t, policy.Partial)
+}
+
+// failingSource never fetches anything
+type failingSource struct{ name string }
+
+func (s *failingSource) Name() string { return s.name }
+
+func (s *failingSource) Fetch(ctx context.Context, workDir string) ([]CouponFile, error) {
+	return nil, errors.New("connection reset")
+}
+
+func TestPromoCodeService_PartialPolicies(t *testing.T) {
+	dir := t.TempDir()
+	writeGzipFixture(t, dir, "couponbase1.gz", "HAPPYHRS FIFTYOFF ONLYONCE1")
+	writeGzipFixture(t, dir, "couponbase2.gz", "HAPPYHRS")
+
+	// A complete load builds the snapshot the snapshot policy falls back to
+	snapPath := filepath.Join(t.TempDir(), "promo.snap")
+	complete := NewPromoCodeService(WithCouponSources(NewDirectorySource(dir)), WithSnapshotPath(snapPath))
+	require.NoError(t, complete.loadCodes(context.Background()))
+
+	tests := []struct {
+		name       string
+		policy     PartialPolicy
+		snapshot   string
+		dataSource string
+		valid      []string
+		invalid    []string
+	}{
+		{"degrade keeps threshold", PartialDegrade, "", "remote",
+			[]string{"HAPPYHRS"}, []string{"FIFTYOFF", "ONLYONCE1"}},
+		{"fail closed", PartialFailClosed, "", "none",
+			nil, []string{"HAPPYHRS", "FIFTYOFF"}},
+		{"snapshot", PartialSnapshot, snapPath, "snapshot",
+			[]string{"HAPPYHRS"}, []string{"FIFTYOFF"}},
+		{"snapshot missing fails closed", PartialSnapshot, filepath.Join(t.TempDir(), "none.snap"), "none",
+			nil, []string{"HAPPYHRS"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewPromoCodeService(
+				WithCouponSources(NewDirectorySource(dir), &failingSource{name: "mirror"}),
+				WithPolicy(PromoPolicy{MinWeight: 2, PartialPolicy: tt.policy}),
+				WithSnapshotPath(tt.snapshot),
+			)
+			service.LoadMockPromoCodes()
+
+			err := service.loadCodes(context.Background())
+			assert.ErrorIs(t, err, ErrPartialLoad)
+			service.setLoadError(err)
+
+			for _, code := range tt.valid {
+				assert.True(t, service.IsValidPromoCode(code), code)
+			}
+			for _, code := range tt.invalid {
+				assert.False(t, service.IsValidPromoCode(code), code)
+			}
+			assert.False(t, service.IsValidPromoCode("WELCOME1"), "Mock codes must not survive a partial load")
+
+			status := service.GetServiceStatus()
+			assert.Equal(t, "degraded", status.Status)
+			assert.Equal(t, tt.dataSource, status.DataSource)
+			assert.Equal(t, string(tt.policy), status.Policy.PartialPolicy)
+			assert.Equal(t, 1, status.Policy.FilesFailed)
+			assert.True(t, status.Policy.Partial)
+			assert.NotEmpty(t, status.LastError)
+		})
+	}
+}
+
+func TestPromoCodeService_FailClosedTotalOutage(t *testing.T) {
+	reg := metrics.NewRegistry()
+	service := NewPromoCodeService(
+		WithCouponSources(&failingSource{name: "primary"}, &failingSource{name: "mirror"}),
+		WithPolicy(PromoPolicy{MinWeight: 2, PartialPolicy: PartialFailClosed}),
+		WithMetrics(reg),
+	)
+	service.LoadMockPromoCodes()
+
+	// Nothing was fetched, so nothing says the served codes are wrong
+	service.downloadCodesAsync(context.Background())
+	assert.True(t, service.IsValidPromoCode("WELCOME1"), "Mock codes are kept")
+	assert.Positive(t, service.GetValidCodesCount())
+
+	status := service.GetServiceStatus()
+	assert.Equal(t, "mock", status.DataSource)
+	assert.Equal(t, 0, status.Policy.FilesLoaded)
+	assert.NotContains(t, status.LastError, ErrPartialLoad.Error())
+
+	var buf bytes.Buffer
+	require.NoError(t, reg.WriteText(&buf))
+	samples, err := metrics.ParseText(&buf)
+	require.NoError(t, err)
+	failures, _ := metrics.Find(samples, "promo_load_duration_seconds_count", "result", loadFailed)
+	assert.Equal(t, 1.0, failures, "Counted as a failed load, not a partial one")
+}
+
+func TestPromoCodeService_DegradedLoadIsNotSnapshotted(t *testing.T) {
+	dir := t.TempDir()
+	writeGzipFixture(t, dir, "couponbase1.gz", "HAPPYHRS")
+	writeGzipFixture(t, dir, "couponbase2.gz", "HAPPYHRS")
+	snapPath := filepath.Join(t.TempDir(), "promo.snap")
+
+	service := NewPromoCodeService(
+		WithCouponSources(NewDirectorySource(dir), &failingSource{name: "mirror"}),
+		WithSnapshotPath(snapPath),
+	)
+	assert.ErrorIs(t, service.loadCodes(context.Background()), ErrPartialLoad)
+	assert.True(t, service.IsValidPromoCode("HAPPYHRS"))
+	assert.NoFileExists(t, snapPath)
+}
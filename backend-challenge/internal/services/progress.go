@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cavaliergopher/grab/v3"
+)
+
+// Download states reported by DownloadProgress
+const (
+	DownloadRunning  = "downloading"
+	DownloadComplete = "complete"
+	DownloadCached   = "cached"
+	DownloadFailed   = "failed"
+)
+
+// DownloadProgress is the state of one coupon file download
+type DownloadProgress struct {
+	Source         string    // Source URL
+	File           string    // Local file name
+	State          string    // One of the Download* states
+	BytesComplete  int64     // Bytes on disk, resumed bytes included
+	Size           int64     // Total size, 0 or less when unknown
+	Percent        float64   // 0-100, 0 when the size is unknown
+	BytesPerSecond float64   // Recent transfer rate
+	ETA            time.Time // Estimated completion, zero when unknown
+	Resumed        bool      // True when an interrupted download was continued
+	StartedAt      time.Time
+	UpdatedAt      time.Time
+	Error          string // Why the download failed
+}
+
+// DownloadTracker collects the progress of the downloads of one load
+type DownloadTracker struct {
+	mu        sync.Mutex
+	downloads map[string]DownloadProgress
+	now       func() time.Time
+}
+
+// NewDownloadTracker creates an empty tracker
+func NewDownloadTracker() *DownloadTracker {
+	return &DownloadTracker{downloads: make(map[string]DownloadProgress), now: time.Now}
+}
+
+// Update records the latest progress of a source; a nil tracker ignores it
+func (t *DownloadTracker) Update(progress DownloadProgress) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	progress.UpdatedAt = t.now()
+	t.downloads[progress.Source] = progress
+}
+
+// Reset forgets every download, at the start of a new load
+func (t *DownloadTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clear(t.downloads)
+}
+
+// Downloads returns the progress of every download, ordered by source
+func (t *DownloadTracker) Downloads() []DownloadProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	downloads := make([]DownloadProgress, 0, len(t.downloads))
+	for _, progress := range t.downloads {
+		downloads = append(downloads, progress)
+	}
+	sort.Slice(downloads, func(i, j int) bool { return downloads[i].Source < downloads[j].Source })
+	return downloads
+}
+
+// grabProgress reads the progress of a grab download
+func grabProgress(source, file string, resp *grab.Response) DownloadProgress {
+	progress := DownloadProgress{
+		Source:         source,
+		File:           file,
+		State:          DownloadRunning,
+		BytesComplete:  resp.BytesComplete(),
+		Size:           resp.Size(),
+		BytesPerSecond: resp.BytesPerSecond(),
+		Resumed:        resp.DidResume,
+		StartedAt:      resp.Start,
+	}
+	if progress.Size > 0 {
+		progress.Percent = 100 * resp.Progress()
+		progress.ETA = resp.ETA()
+	}
+	return progress
+}
+
+// downloadTrackerKey is the context key of the tracker sources report to
+type downloadTrackerKey struct{}
+
+// withDownloadTracker returns a context whose sources report to tracker
+func withDownloadTracker(ctx context.Context, tracker *DownloadTracker) context.Context {
+	return context.WithValue(ctx, downloadTrackerKey{}, tracker)
+}
+
+// downloadTrackerFrom returns the tracker of ctx, nil if there is none
+func downloadTrackerFrom(ctx context.Context) *DownloadTracker {
+	tracker, _ := ctx.Value(downloadTrackerKey{}).(*DownloadTracker)
+	return tracker
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadTracker(t *testing.T) {
+	tracker := NewDownloadTracker()
+	tracker.Update(DownloadProgress{Source: "https://b.example/c.gz", State: DownloadRunning})
+	tracker.Update(DownloadProgress{Source: "https://a.example/c.gz", State: DownloadRunning})
+	tracker.Update(DownloadProgress{Source: "https://b.example/c.gz", State: DownloadComplete})
+
+	downloads := tracker.Downloads()
+	require.Len(t, downloads, 2)
+	assert.Equal(t, "https://a.example/c.gz", downloads[0].Source)
+	assert.Equal(t, DownloadComplete, downloads[1].State, "Later updates replace earlier ones")
+	assert.False(t, downloads[1].UpdatedAt.IsZero())
+
+	tracker.Reset()
+	assert.Empty(t, tracker.Downloads())
+
+	var none *DownloadTracker
+	none.Update(DownloadProgress{Source: "ignored"}) // Must not panic
+}
+
+func TestHTTPSource_ReportsProgress(t *testing.T) {
+	fixture := writeGzipFixture(t, t.TempDir(), "couponbase1.gz", "HAPPYHRS FIFTYOFF")
+	server := httptest.NewServer(http.FileServer(http.Dir(filepath.Dir(fixture))))
+	defer server.Close()
+
+	source, err := NewHTTPSource(server.URL + "/couponbase1.gz")
+	require.NoError(t, err)
+
+	tracker := NewDownloadTracker()
+	ctx := withDownloadTracker(context.Background(), tracker)
+	workDir := t.TempDir()
+	_, err = source.Fetch(ctx, workDir)
+	require.NoError(t, err)
+
+	downloads := tracker.Downloads()
+	require.Len(t, downloads, 1)
+	progress := downloads[0]
+	assert.Equal(t, source.Name(), progress.Source)
+	assert.Equal(t, "couponbase1.gz", progress.File)
+	assert.Equal(t, DownloadComplete, progress.State)
+	assert.Equal(t, progress.Size, progress.BytesComplete)
+	assert.InDelta(t, 100, progress.Percent, 0.001)
+
+	missing, err := NewHTTPSource(server.URL + "/missing.gz")
+	require.NoError(t, err)
+	_, err = missing.Fetch(ctx, t.TempDir())
+	require.Error(t, err)
+
+	downloads = tracker.Downloads()
+	require.Len(t, downloads, 2)
+	failed := downloads[1]
+	if failed.Source != missing.Name() {
+		failed = downloads[0]
+	}
+	assert.Equal(t, DownloadFailed, failed.State)
+	assert.NotEmpty(t, failed.Error)
+}
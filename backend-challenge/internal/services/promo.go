@@ -3,9 +3,11 @@ package services
 import (
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,50 +15,270 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/cavaliergopher/grab/v3"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/metrics"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/requestid"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/tracing"
+	"github.com/ilyulev/kart-challenge/backend-api/pkg/utils"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // PromoCodeService handles promo code validation with async download
 type PromoCodeService struct {
-	validCodes map[string]bool
-	codesMutex sync.RWMutex // Protects validCodes map
+	validCodes *codeSet
+	codesMutex sync.RWMutex // Protects validCodes
 	isLoaded   int32        // Atomic flag: 0 = loading, 1 = loaded
+	loading    int32        // Atomic flag: 1 while a background load runs
 	loadError  error        // Last load error
-	errorMutex sync.RWMutex // Protects loadError
+	lastLoad   loadStats    // Outcome of the last completed load, protected by errorMutex
+	loadErrors []LoadError  // Recent failed loads, newest first, protected by errorMutex
+	lastFiles  []CouponFile // Files used by the last load, protected by errorMutex
+	errorMutex sync.RWMutex // Protects loadError, lastLoad, loadErrors and lastFiles
 	codesCount int32        // Atomic counter for loaded codes
+	dataSource string       // Origin of validCodes, protected by codesMutex
+	sources    []CouponSource
+
+	snapshotPath        string // Empty disables the on-disk snapshot
+	snapshotFingerprint string // Fingerprint of the served codes, protected by codesMutex
+
+	cacheDir            string           // Persistent download directory, temp dir if empty
+	downloadConcurrency int              // Maximum sources fetched at once
+	manifest            ChecksumManifest // Expected SHA-256 of coupon files, optional
+
+	policy PromoPolicy // Validity threshold and partial download handling
+
+	downloads *DownloadTracker // Progress of the current or last load's downloads
+	logger    *slog.Logger
+	metrics   *promoMetrics
+	tracer    trace.Tracer // Starts the root span of background loads
+
+	loadCtx   context.Context    // Parent of background loads, cancelled by Shutdown
+	stopLoads context.CancelFunc // Cancels loadCtx
+	loads     sync.WaitGroup     // Running background loads
+}
+
+// maxLoadErrors bounds the failed loads kept for the admin API
+const maxLoadErrors = 20
+
+var (
+	// ErrReloadInProgress is returned when a reload is requested during a load
+	ErrReloadInProgress = errors.New("promo code load already in progress")
+	// ErrServiceStopped is returned when a reload is requested after Shutdown
+	ErrServiceStopped = errors.New("promo code service stopped")
+)
+
+// LoadError is a background load that failed or missed coupon files
+type LoadError struct {
+	At          time.Time     // When the load finished
+	Duration    time.Duration // How long the load took
+	Error       string
+	Partial     bool // True when some files loaded and the policy applied
+	FilesLoaded int
+	FilesFailed int
+}
+
+// loadStats records how many coupon files a load used and lost
+type loadStats struct {
+	filesLoaded int
+	filesFailed int
+}
+
+// PromoOption configures a PromoCodeService
+type PromoOption func(*PromoCodeService)
+
+// WithCouponSources sets the sources coupon files are loaded from
+func WithCouponSources(sources ...CouponSource) PromoOption {
+	return func(p *PromoCodeService) {
+		p.sources = sources
+	}
 }
 
-// NewPromoCodeService creates a new promo code service
-func NewPromoCodeService() *PromoCodeService {
-	return &PromoCodeService{
-		validCodes: make(map[string]bool),
-		isLoaded:   0,
+// WithSnapshotPath enables the persistent valid-code snapshot at path
+func WithSnapshotPath(path string) PromoOption {
+	return func(p *PromoCodeService) {
+		p.snapshotPath = path
 	}
 }
 
-// Initialize sets up the service and starts async download
-func (p *PromoCodeService) Initialize() error {
-	log.Println("Promo code service initializing...")
+// WithCacheDir keeps downloaded coupon files in dir so later loads can reuse
+// unchanged files and resume interrupted downloads
+func WithCacheDir(dir string) PromoOption {
+	return func(p *PromoCodeService) {
+		p.cacheDir = dir
+	}
+}
+
+// WithDownloadConcurrency limits how many sources are fetched at once
+func WithDownloadConcurrency(n int) PromoOption {
+	return func(p *PromoCodeService) {
+		if n > 0 {
+			p.downloadConcurrency = n
+		}
+	}
+}
 
-	// Load mock data immediately for quick startup
-	p.LoadMockPromoCodes()
+// WithChecksumManifest verifies coupon files against expected SHA-256 sums
+// before they are parsed
+func WithChecksumManifest(manifest ChecksumManifest) PromoOption {
+	return func(p *PromoCodeService) {
+		p.manifest = manifest
+	}
+}
+
+// WithLogger sets the logger of the service and its coupon sources
+func WithLogger(logger *slog.Logger) PromoOption {
+	return func(p *PromoCodeService) {
+		p.logger = logger
+	}
+}
+
+// WithMetrics registers the metrics of the service in reg
+func WithMetrics(reg *metrics.Registry) PromoOption {
+	return func(p *PromoCodeService) {
+		p.metrics = newPromoMetrics(reg, p)
+	}
+}
+
+// WithTracerProvider traces background loads with provider instead of the
+// global one
+func WithTracerProvider(provider trace.TracerProvider) PromoOption {
+	return func(p *PromoCodeService) {
+		p.tracer = tracing.Tracer(provider)
+	}
+}
+
+// WithPolicy sets the validity threshold and partial download policy
+func WithPolicy(policy PromoPolicy) PromoOption {
+	return func(p *PromoCodeService) {
+		if policy.MinWeight > 0 {
+			p.policy.MinWeight = policy.MinWeight
+		}
+		if policy.PartialPolicy != "" {
+			p.policy.PartialPolicy = policy.PartialPolicy
+		}
+	}
+}
+
+// NewPromoCodeService creates a new promo code service.
+// Without WithCouponSources the published challenge files are used.
+func NewPromoCodeService(opts ...PromoOption) *PromoCodeService {
+	p := &PromoCodeService{
+		validCodes:          &codeSet{},
+		isLoaded:            0,
+		downloadConcurrency: 3,
+		policy:              DefaultPromoPolicy(),
+		downloads:           NewDownloadTracker(),
+		logger:              slog.Default(),
+		tracer:              tracing.Tracer(otel.GetTracerProvider()),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.metrics == nil {
+		p.metrics = newPromoMetrics(metrics.NewRegistry(), p)
+	}
+	p.loadCtx, p.stopLoads = context.WithCancel(logging.NewContext(context.Background(), p.logger))
+
+	if p.sources == nil {
+		// Default specs are static URLs, so they always parse
+		p.sources, _ = NewCouponSources(DefaultCouponSourceSpecs)
+	}
+
+	return p
+}
+
+// Initialize sets up the service and starts async download. Background
+// loads, this one and later reloads, are cancelled when ctx is done.
+func (p *PromoCodeService) Initialize(ctx context.Context) error {
+	p.logger.Info("Promo code service initializing")
+	p.stopLoads()
+	// Coupon sources log through the context of the load
+	p.loadCtx, p.stopLoads = context.WithCancel(logging.NewContext(ctx, p.logger))
+
+	// Serve the last snapshot if there is one, mock data otherwise
+	if err := p.loadSnapshot(); err != nil {
+		if !errors.Is(err, ErrSnapshotNotFound) {
+			p.logger.Warn("Promo snapshot unusable, falling back to mock data", "error", err)
+		}
+		p.LoadMockPromoCodes()
+	}
 
 	// Start async download in background
-	go p.downloadCodesAsync()
+	if err := p.startLoad(ctx); err != nil {
+		return fmt.Errorf("failed to start background load: %w", err)
+	}
 
-	log.Println("Promo code service initialized with mock data, downloading real codes in background")
+	p.logger.Info("Promo code service initialized, loading real codes in background",
+		"data_source", p.GetServiceStatus().DataSource)
 	return nil
 }
 
-// downloadCodesAsync downloads coupon files in background
-func (p *PromoCodeService) downloadCodesAsync() {
-	log.Println("Starting background download of coupon files...")
+// startLoad starts a background load unless one is running already or the
+// service is stopped. The load carries the request ID of requestCtx, if any,
+// but is not cancelled with it.
+func (p *PromoCodeService) startLoad(requestCtx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&p.loading, 0, 1) {
+		return ErrReloadInProgress
+	}
+	ctx := p.loadCtx
+	if ctx.Err() != nil {
+		atomic.StoreInt32(&p.loading, 0)
+		return ErrServiceStopped
+	}
+	if id := requestid.FromContext(requestCtx); id != "" {
+		ctx = requestid.NewContext(ctx, id)
+	}
+
+	p.loads.Add(1)
+	go func() {
+		defer p.loads.Done()
+		defer atomic.StoreInt32(&p.loading, 0)
+		p.downloadCodesAsync(ctx)
+	}()
+	return nil
+}
+
+// Shutdown cancels a running background load and waits for it to stop,
+// giving up when ctx is done. No loads start afterwards.
+func (p *PromoCodeService) Shutdown(ctx context.Context) error {
+	p.stopLoads()
 
-	urls := []string{
-		"https://orderfoodonline-files.s3.ap-southeast-2.amazonaws.com/couponbase1.gz",
-		"https://orderfoodonline-files.s3.ap-southeast-2.amazonaws.com/couponbase2.gz",
-		"https://orderfoodonline-files.s3.ap-southeast-2.amazonaws.com/couponbase3.gz",
+	stopped := make(chan struct{})
+	go func() {
+		p.loads.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background load still running: %w", ctx.Err())
 	}
+}
+
+// downloadCodesAsync loads coupon files in background until ctx is done.
+// Each load is a trace of its own, with a span per source, file and phase.
+func (p *PromoCodeService) downloadCodesAsync(ctx context.Context) {
+	// Loads requested through the admin API log the ID of that request
+	logger := p.logger
+	if id := requestid.FromContext(ctx); id != "" {
+		logger = logger.With("request_id", id)
+		ctx = logging.NewContext(ctx, logger)
+	}
+
+	logger.Info("Starting background load of coupon files", "sources", len(p.sources))
+	started := time.Now()
+	ctx, span := p.tracer.Start(ctx, "promo.load", trace.WithAttributes(attribute.Int("promo.sources", len(p.sources))))
+	if id := requestid.FromContext(ctx); id != "" {
+		span.SetAttributes(attribute.String("request.id", id))
+	}
+	p.downloads.Reset()
 
 	// Set loading state
 	atomic.StoreInt32(&p.isLoaded, 0)
@@ -66,108 +288,349 @@ func (p *PromoCodeService) downloadCodesAsync() {
 	p.loadError = nil
 	p.errorMutex.Unlock()
 
-	// Create temporary directory
-	tempDir, err := os.MkdirTemp("", "oolio-coupons-")
+	err := p.loadCodes(ctx)
+	span.SetAttributes(attribute.Int("promo.codes", p.GetValidCodesCount()))
+	tracing.End(span, err)
 	if err != nil {
-		p.setLoadError(fmt.Errorf("failed to create temp directory: %w", err))
+		if ctx.Err() != nil {
+			// Stopped on shutdown, which says nothing about the sources
+			logger.Info("Background load stopped", "error", err)
+			return
+		}
+		p.setLoadError(err)
+		p.recordLoadError(err, started)
+		if errors.Is(err, ErrPartialLoad) {
+			p.metrics.loaded(loadPartial, started, p.downloads.Downloads())
+			logger.Warn("Background load incomplete", "error", err)
+		} else {
+			p.metrics.loaded(loadFailed, started, p.downloads.Downloads())
+			logger.Error("Background load failed, continuing with mock data", "error", err)
+		}
 		return
 	}
-	defer os.RemoveAll(tempDir)
 
-	// Create grab client
-	client := grab.NewClient()
-	client.UserAgent = "OolioFoodAPI/1.0"
+	// Mark as successfully loaded
+	atomic.StoreInt32(&p.isLoaded, 1)
+	p.metrics.loaded(loadSucceeded, started, p.downloads.Downloads())
+	logger.Info("Background load completed", "codes", atomic.LoadInt32(&p.codesCount),
+		"duration", time.Since(started))
+}
 
-	// Download and process files
-	fileCodes := make([]map[string]bool, len(urls))
-	successCount := 0
+// loadCodes fetches and processes every source, then replaces the valid codes
+func (p *PromoCodeService) loadCodes(ctx context.Context) error {
+	// Download into the cache directory, or a temporary one without a cache
+	workRoot := p.cacheDir
+	if workRoot == "" {
+		tempDir, err := os.MkdirTemp("", "oolio-coupons-")
+		if err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
+		workRoot = tempDir
+	}
+
+	files, failed, err := p.fetchSources(withDownloadTracker(ctx, p.downloads), workRoot)
+	if ctx.Err() != nil {
+		// Files lost to cancellation say nothing about the sources
+		return err
+	}
+	if err == nil && len(p.manifest) > 0 {
+		_, span := tracing.Start(ctx, "promo.verify", attribute.Int("promo.files", len(files)))
+		var rejected int
+		files, rejected = p.verifyFiles(files, workRoot)
+		span.SetAttributes(attribute.Int("promo.files_rejected", rejected))
+		span.End()
+		failed += rejected
+		if len(files) == 0 {
+			err = fmt.Errorf("background load failed: no coupon files passed verification")
+		}
+	}
+	p.setLastFiles(files)
+
+	// Missing files would change which codes pass the threshold, so anything
+	// but degrade stops here rather than parsing an incomplete set. Without
+	// any file the load failed outright and the codes served are kept.
+	if failed > 0 && len(files) > 0 && p.policy.PartialPolicy != PartialDegrade {
+		return p.applyPartialPolicy(len(files), failed)
+	}
+	if err != nil {
+		p.setLoadStats(0, failed)
+		return err
+	}
+
+	// Skip parsing when the files match the snapshot being served
+	var fingerprint string
+	if p.snapshotPath != "" {
+		_, span := tracing.Start(ctx, "promo.fingerprint")
+		fingerprint, err = fingerprintFiles(files, p.policy.rule())
+		tracing.End(span, err)
+		if err != nil {
+			p.logger.Warn("Cannot fingerprint coupon files", "error", err)
+		} else if failed == 0 && fingerprint == p.currentFingerprint() {
+			p.logger.Info("Coupon files unchanged, keeping snapshot")
+			p.setLoadStats(len(files), 0)
+			return nil
+		}
+	}
 
-	for i, url := range urls {
-		log.Printf("Background download: processing file %d of %d", i+1, len(urls))
+	// Process files one at a time, merging each into the running counts
+	// so no more than one file's codes are held on their own
+	counter := &codeCounter{}
+	successCount := 0
 
-		codes, err := p.downloadAndProcessWithGrab(client, url, tempDir, i+1)
+	for _, file := range files {
+		_, span := tracing.Start(ctx, "promo.parse_file",
+			attribute.String("promo.file", file.Name), attribute.String("promo.source", file.Source))
+		keys, err := p.processGzipFile(file.Path)
+		span.SetAttributes(attribute.Int("promo.potential_codes", len(keys)))
+		tracing.End(span, err)
 		if err != nil {
-			log.Printf("Background download warning: failed to process %s: %v", url, err)
+			p.logger.Warn("Failed to process coupon file", "file", file.Name, "error", err)
+			failed++
 			continue
 		}
 
-		fileCodes[i] = codes
+		counter.Add(keys, file.Weight)
 		successCount++
-		log.Printf("Background download: successfully processed file %d (%d potential codes)", i+1, len(codes))
+		p.logger.Info("Processed coupon file", "file", file.Name, "potential_codes", len(keys), "weight", file.Weight)
 	}
 
 	// Process results
+	if failed > 0 && successCount > 0 && p.policy.PartialPolicy != PartialDegrade {
+		return p.applyPartialPolicy(successCount, failed)
+	}
 	if successCount == 0 {
-		p.setLoadError(fmt.Errorf("background download failed: no coupon files processed"))
-		log.Println("Background download failed, continuing with mock data")
-		return
+		p.setLoadStats(0, failed)
+		return fmt.Errorf("background load failed: no coupon files processed")
 	}
 
 	// Replace mock data with real codes
-	if err := p.replaceWithRealCodes(fileCodes, successCount); err != nil {
-		p.setLoadError(fmt.Errorf("background processing failed: %w", err))
-		log.Printf("Background processing failed: %v, continuing with mock data", err)
-		return
+	_, span := tracing.Start(ctx, "promo.select_codes", attribute.Int("promo.min_weight", p.policy.MinWeight))
+	err = p.replaceWithRealCodes(counter)
+	span.SetAttributes(attribute.Int("promo.codes", p.GetValidCodesCount()))
+	tracing.End(span, err)
+	if err != nil {
+		p.setLoadStats(successCount, failed)
+		return fmt.Errorf("background processing failed: %w", err)
 	}
+	p.setLoadStats(successCount, failed)
 
-	// Mark as successfully loaded
-	atomic.StoreInt32(&p.isLoaded, 1)
-	log.Printf("Background download completed successfully: %d valid promo codes loaded",
-		atomic.LoadInt32(&p.codesCount))
-}
+	// A degraded set is served but never persisted as a warm start
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d coupon files missing, serving codes from the rest",
+			ErrPartialLoad, failed, successCount+failed)
+	}
 
-// downloadAndProcessWithGrab downloads a single file
-func (p *PromoCodeService) downloadAndProcessWithGrab(client *grab.Client, url, tempDir string, fileNum int) (map[string]bool, error) {
-	filename := filepath.Join(tempDir, fmt.Sprintf("coupon%d.gz", fileNum))
-	req, err := grab.NewRequest(filename, url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create download request: %w", err)
+	if fingerprint != "" {
+		_, span := tracing.Start(ctx, "promo.save_snapshot")
+		err := p.saveSnapshot(fingerprint)
+		tracing.End(span, err)
+		if err != nil {
+			// The codes are served already, only the next warm start suffers
+			p.logger.Warn("Failed to save promo snapshot", "error", err)
+		}
 	}
 
-	// Set timeout for background download
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Minute)
-	defer cancel()
-	req = req.WithContext(ctx)
+	return nil
+}
 
-	log.Printf("Background: starting download of file %d", fileNum)
+// applyPartialPolicy handles a load that lost some, but not all, coupon
+// files under the fail_closed and snapshot policies
+func (p *PromoCodeService) applyPartialPolicy(loaded, failed int) error {
+	p.setLoadStats(loaded, failed)
+	err := fmt.Errorf("%w: %d of %d coupon files missing", ErrPartialLoad, failed, loaded+failed)
 
-	resp := client.Do(req)
+	if p.policy.PartialPolicy == PartialSnapshot {
+		p.codesMutex.RLock()
+		serving := p.dataSource == "snapshot"
+		p.codesMutex.RUnlock()
 
-	// Simple progress logging (less frequent for background)
-	ticker := time.NewTicker(15 * time.Second)
-	defer ticker.Stop()
+		if serving {
+			return fmt.Errorf("%w, keeping snapshot", err)
+		}
+		snapErr := p.loadSnapshot()
+		if snapErr == nil {
+			return fmt.Errorf("%w, serving snapshot", err)
+		}
+		p.logger.Warn("No usable snapshot for partial load", "error", snapErr)
+	}
+
+	// Fail closed: better to reject every code than accept invalid ones
+	p.codesMutex.Lock()
+	p.validCodes = &codeSet{}
+	p.dataSource = "none"
+	p.snapshotFingerprint = ""
+	p.codesMutex.Unlock()
+
+	atomic.StoreInt32(&p.codesCount, 0)
+	return fmt.Errorf("%w, rejecting all codes", err)
+}
+
+// fetchSources makes every source's files available locally under workRoot,
+// fetching up to downloadConcurrency sources at once. Files are returned in
+// source order regardless of which download finishes first, weighted by their
+// source, together with the number of sources that could not be fetched.
+func (p *PromoCodeService) fetchSources(ctx context.Context, workRoot string) ([]CouponFile, int, error) {
+	results := make([][]CouponFile, len(p.sources))
+	var failed int32
+	semaphore := make(chan struct{}, p.downloadConcurrency)
+	var wg sync.WaitGroup
+
+	for i, source := range p.sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-	go func() {
-		for {
 			select {
-			case <-ticker.C:
-				if !resp.IsComplete() {
-					if resp.Size() > 0 {
-						log.Printf("Background file %d: %.0f%% complete",
-							fileNum, 100*resp.Progress())
-					} else {
-						log.Printf("Background file %d: %.1f MB downloaded",
-							fileNum, float64(resp.BytesComplete())/(1024*1024))
-					}
-				}
-			case <-resp.Done:
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				return
+			}
+
+			p.logger.Info("Fetching coupon source", "source", source.Name(), "index", i+1, "sources", len(p.sources))
+			ctx, span := tracing.Start(ctx, "promo.fetch_source", attribute.String("promo.source", source.Name()))
+			defer span.End()
+
+			// Work dirs are named after the source so a persistent cache
+			// survives reordering, and same-named remote files stay apart
+			workDir := filepath.Join(workRoot, sourceDirName(source))
+			if err := os.MkdirAll(workDir, 0o755); err != nil {
+				p.logger.Warn("Failed to create work directory", "source", source.Name(), "error", err)
+				span.SetStatus(codes.Error, err.Error())
+				atomic.AddInt32(&failed, 1)
 				return
 			}
+
+			files, err := source.Fetch(ctx, workDir)
+			if err != nil {
+				p.logger.Warn("Failed to fetch coupon source", "source", source.Name(), "error", err)
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				atomic.AddInt32(&failed, 1)
+				return
+			}
+
+			weight := sourceWeight(source)
+			for j := range files {
+				files[j].Source = source.Name()
+				files[j].Weight = weight
+			}
+			results[i] = files
+			span.SetAttributes(attribute.Int("promo.files", len(files)))
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, int(failed), fmt.Errorf("background load cancelled: %w", err)
+	}
+
+	var files []CouponFile
+	for _, sourceFiles := range results {
+		files = append(files, sourceFiles...)
+	}
+
+	if len(files) == 0 {
+		return nil, int(failed), fmt.Errorf("background load failed: no coupon files fetched")
+	}
+	return files, int(failed), nil
+}
+
+// verifyFiles drops files that do not match the checksum manifest and
+// returns how many were dropped. Failed downloads inside workRoot are deleted
+// so the next load fetches them again.
+func (p *PromoCodeService) verifyFiles(files []CouponFile, workRoot string) ([]CouponFile, int) {
+	if len(p.manifest) == 0 {
+		return files, 0
+	}
+
+	verified := files[:0]
+	for _, file := range files {
+		sum, listed, err := p.manifest.Verify(file)
+		if err != nil {
+			p.logger.Warn("Coupon file failed verification", "file", file.Name, "error", err)
+			if strings.HasPrefix(file.Path, filepath.Clean(workRoot)+string(filepath.Separator)) {
+				os.Remove(file.Path)
+				os.Remove(file.Path + ".etag")
+			}
+			continue
 		}
-	}()
+		if !listed {
+			p.logger.Warn("Coupon file not in the checksum manifest, accepting unverified", "file", file.Name)
+		}
+
+		file.SHA256 = sum
+		verified = append(verified, file)
+	}
+	return verified, len(files) - len(verified)
+}
+
+// sourceDirName derives a stable directory name for a source
+func sourceDirName(source CouponSource) string {
+	sum := sha256.Sum256([]byte(source.Name()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// loadSnapshot serves the valid codes stored in the snapshot file
+func (p *PromoCodeService) loadSnapshot() error {
+	if p.snapshotPath == "" {
+		return ErrSnapshotNotFound
+	}
 
-	if err := resp.Err(); err != nil {
-		return nil, fmt.Errorf("background download failed: %w", err)
+	snap, err := readSnapshot(p.snapshotPath)
+	if err != nil {
+		return err
 	}
+	if len(snap.Codes) == 0 {
+		return fmt.Errorf("snapshot contains no codes")
+	}
+
+	codes := newCodeSet(snap.Codes)
+
+	p.codesMutex.Lock()
+	p.validCodes = codes
+	p.dataSource = "snapshot"
+	p.snapshotFingerprint = snap.Fingerprint
+	p.codesMutex.Unlock()
+
+	atomic.StoreInt32(&p.codesCount, int32(codes.Len()))
+	p.logger.Info("Loaded promo codes from snapshot", "codes", codes.Len(), "created_at", snap.CreatedAt)
+	return nil
+}
+
+// saveSnapshot persists the currently served codes
+func (p *PromoCodeService) saveSnapshot(fingerprint string) error {
+	p.codesMutex.RLock()
+	codes := p.validCodes.Codes()
+	p.codesMutex.RUnlock()
+
+	err := writeSnapshot(p.snapshotPath, &promoSnapshot{
+		Fingerprint: fingerprint,
+		CreatedAt:   time.Now(),
+		Codes:       codes,
+	})
+	if err != nil {
+		return err
+	}
+
+	p.codesMutex.Lock()
+	p.snapshotFingerprint = fingerprint
+	p.codesMutex.Unlock()
 
-	log.Printf("Background: file %d downloaded (%.1f MB)",
-		fileNum, float64(resp.Size())/(1024*1024))
+	p.logger.Info("Saved promo snapshot", "codes", len(codes), "path", p.snapshotPath)
+	return nil
+}
 
-	return p.processGzipFile(filename)
+// currentFingerprint returns the fingerprint of the served snapshot, if any
+func (p *PromoCodeService) currentFingerprint() string {
+	p.codesMutex.RLock()
+	defer p.codesMutex.RUnlock()
+	return p.snapshotFingerprint
 }
 
-// processGzipFile extracts promo codes from gzipped file
-func (p *PromoCodeService) processGzipFile(filename string) (map[string]bool, error) {
+// processGzipFile extracts the sorted distinct promo code keys from a gzipped file
+func (p *PromoCodeService) processGzipFile(filename string) ([]uint64, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open %s: %w", filename, err)
@@ -180,75 +643,48 @@ func (p *PromoCodeService) processGzipFile(filename string) (map[string]bool, er
 	}
 	defer gzReader.Close()
 
-	codes := make(map[string]bool)
-	buffer := make([]byte, 64*1024)
-	wordCount := 0
-
-	for {
-		n, err := gzReader.Read(buffer)
-		if n > 0 {
-			text := string(buffer[:n])
-			words := strings.Fields(text)
-
-			for _, word := range words {
-				cleaned := strings.ToUpper(strings.Trim(word, ".,!?;:\"'()[]{}"))
-				if isValidPromoCodeFormat(cleaned) {
-					codes[cleaned] = true
-				}
-				wordCount++
-			}
-		}
-
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to read file content: %w", err)
-		}
+	var codes fileCodeCollector
+	scanner := utils.NewCouponScanner(gzReader)
+	for scanner.Scan() {
+		codes.Add(scanner.Text())
 	}
-
-	return codes, nil
-}
-
-// replaceWithRealCodes replaces mock data with real coupon codes
-func (p *PromoCodeService) replaceWithRealCodes(fileCodes []map[string]bool, successCount int) error {
-	// Count code occurrences
-	codeCount := make(map[string]int)
-	for _, fileCodeMap := range fileCodes {
-		for code := range fileCodeMap {
-			codeCount[code]++
-		}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file content: %w", err)
 	}
 
-	// Determine minimum occurrences
-	minOccurrences := 2
-	if successCount == 1 {
-		minOccurrences = 1
-	}
+	return codes.Keys(), nil
+}
 
-	// Build new valid codes map
-	newValidCodes := make(map[string]bool)
-	for code, count := range codeCount {
-		if count >= minOccurrences {
-			newValidCodes[code] = true
-		}
-	}
+// replaceWithRealCodes replaces mock data with the codes meeting the policy threshold
+func (p *PromoCodeService) replaceWithRealCodes(counter *codeCounter) error {
+	// The threshold never drops when files are missing, that would accept
+	// codes the rule rejects
+	newValidCodes := counter.AtLeast(p.policy.MinWeight)
 
-	if len(newValidCodes) == 0 {
+	if newValidCodes.Len() == 0 {
 		return fmt.Errorf("no valid codes found")
 	}
 
-	// Atomically replace the codes map
+	// Atomically replace the code set
 	p.codesMutex.Lock()
 	p.validCodes = newValidCodes
+	p.dataSource = "remote"
+	p.snapshotFingerprint = ""
 	p.codesMutex.Unlock()
 
 	// Update counter
-	atomic.StoreInt32(&p.codesCount, int32(len(newValidCodes)))
+	atomic.StoreInt32(&p.codesCount, int32(newValidCodes.Len()))
 
 	return nil
 }
 
+// setLoadStats records the file counts of a completed load
+func (p *PromoCodeService) setLoadStats(loaded, failed int) {
+	p.errorMutex.Lock()
+	p.lastLoad = loadStats{filesLoaded: loaded, filesFailed: failed}
+	p.errorMutex.Unlock()
+}
+
 // setLoadError sets the load error in a thread-safe way
 func (p *PromoCodeService) setLoadError(err error) {
 	p.errorMutex.Lock()
@@ -256,33 +692,67 @@ func (p *PromoCodeService) setLoadError(err error) {
 	p.errorMutex.Unlock()
 }
 
+// recordLoadError adds a failed load to the history
+func (p *PromoCodeService) recordLoadError(err error, started time.Time) {
+	now := time.Now()
+
+	p.errorMutex.Lock()
+	defer p.errorMutex.Unlock()
+
+	loadErr := LoadError{
+		At:          now,
+		Duration:    now.Sub(started),
+		Error:       err.Error(),
+		Partial:     errors.Is(err, ErrPartialLoad),
+		FilesLoaded: p.lastLoad.filesLoaded,
+		FilesFailed: p.lastLoad.filesFailed,
+	}
+	p.loadErrors = append([]LoadError{loadErr}, p.loadErrors[:min(len(p.loadErrors), maxLoadErrors-1)]...)
+}
+
+// setLastFiles remembers the files a load works with
+func (p *PromoCodeService) setLastFiles(files []CouponFile) {
+	p.errorMutex.Lock()
+	p.lastFiles = append([]CouponFile(nil), files...)
+	p.errorMutex.Unlock()
+}
+
 // loadMockPromoCodes loads initial mock data for immediate availability
 func (p *PromoCodeService) LoadMockPromoCodes() {
 	mockCodes := []string{
 		"HAPPYHRS", "FIFTYOFF", "WELCOME1", "NEWUSER2", "DISCOUNT",
 		"SAVE20PC", "FREESHIP", "SUMMER25", "AUTUMN30", "WINTER15",
 		"STUDENT", "BIRTHDAY", "LOYALTY5", "REFERRAL", "COMEBACK",
+		"HAPPYHOURS", "BUYGETONE",
 	}
 
 	p.codesMutex.Lock()
-	for _, code := range mockCodes {
-		p.validCodes[code] = true
-	}
+	p.validCodes = p.validCodes.Union(newCodeSet(mockCodes))
+	p.dataSource = "mock"
+	count := p.validCodes.Len()
 	p.codesMutex.Unlock()
 
-	atomic.StoreInt32(&p.codesCount, int32(len(mockCodes)))
-	log.Printf("Loaded %d mock promo codes for immediate availability", len(mockCodes))
+	atomic.StoreInt32(&p.codesCount, int32(count))
+	p.logger.Info("Loaded mock promo codes for immediate availability", "codes", len(mockCodes))
 }
 
-// IsValidPromoCode checks if a promo code is valid (thread-safe)
+// IsValidPromoCode checks if a promo code is valid (thread-safe), counting
+// hits and misses in promo_validations_total
 func (p *PromoCodeService) IsValidPromoCode(code string) bool {
+	valid := p.isLoadedCode(code)
+	p.metrics.validated(valid)
+	return valid
+}
+
+// isLoadedCode checks a code like IsValidPromoCode, without counting it
+func (p *PromoCodeService) isLoadedCode(code string) bool {
 	upperCode := strings.ToUpper(code)
 	if !isValidPromoCodeFormat(upperCode) {
 		return false
 	}
 
 	p.codesMutex.RLock() // ✅ Keep this
-	valid := p.validCodes[upperCode]
+	valid := p.validCodes.Contains(upperCode)
 	p.codesMutex.RUnlock()
 
 	return valid
@@ -304,14 +774,19 @@ func (p *PromoCodeService) GetServiceStatus() ServiceStatus {
 	if p.loadError != nil {
 		status.LastError = p.loadError.Error()
 	}
+	status.Policy = p.policyStatus(p.lastLoad)
 	p.errorMutex.RUnlock()
 
+	p.codesMutex.RLock()
+	status.DataSource = p.dataSource
+	p.codesMutex.RUnlock()
+
 	if status.IsFullyLoaded {
 		status.Status = "ready"
-		status.DataSource = "remote"
+	} else if status.Policy.Partial {
+		status.Status = "degraded"
 	} else if status.CodesLoaded > 0 {
 		status.Status = "loading"
-		status.DataSource = "mock"
 	} else {
 		status.Status = "initializing"
 		status.DataSource = "none"
@@ -320,13 +795,41 @@ func (p *PromoCodeService) GetServiceStatus() ServiceStatus {
 	return status
 }
 
+// policyStatus describes the policy in effect and the outcome of a load
+func (p *PromoCodeService) policyStatus(last loadStats) PolicyStatus {
+	weights := make(map[string]int, len(p.sources))
+	for _, source := range p.sources {
+		weights[source.Name()] = sourceWeight(source)
+	}
+
+	return PolicyStatus{
+		MinWeight:     p.policy.MinWeight,
+		PartialPolicy: string(p.policy.PartialPolicy),
+		SourceWeights: weights,
+		FilesLoaded:   last.filesLoaded,
+		FilesFailed:   last.filesFailed,
+		Partial:       last.filesFailed > 0,
+	}
+}
+
 // ServiceStatus represents the current state of the promo service
 type ServiceStatus struct {
-	Status        string `json:"status"`              // "initializing", "loading", "ready"
-	DataSource    string `json:"dataSource"`          // "none", "mock", "remote"
-	CodesLoaded   int    `json:"codesLoaded"`         // Number of codes currently available
-	IsFullyLoaded bool   `json:"isFullyLoaded"`       // True when real codes are loaded
-	LastError     string `json:"lastError,omitempty"` // Last error if any
+	Status        string       `json:"status"`              // "initializing", "loading", "degraded", "ready"
+	DataSource    string       `json:"dataSource"`          // "none", "mock", "snapshot", "remote"
+	CodesLoaded   int          `json:"codesLoaded"`         // Number of codes currently available
+	IsFullyLoaded bool         `json:"isFullyLoaded"`       // True when real codes are loaded
+	LastError     string       `json:"lastError,omitempty"` // Last error if any
+	Policy        PolicyStatus `json:"policy"`              // Validity rule in effect
+}
+
+// PolicyStatus reports the validity rule and how the last load went
+type PolicyStatus struct {
+	MinWeight     int            `json:"minWeight"`     // Summed file weight a code needs
+	PartialPolicy string         `json:"partialPolicy"` // "fail_closed", "snapshot", "degrade"
+	SourceWeights map[string]int `json:"sourceWeights"` // Weight of each source's files
+	FilesLoaded   int            `json:"filesLoaded"`   // Files used by the last load
+	FilesFailed   int            `json:"filesFailed"`   // Files the last load could not use
+	Partial       bool           `json:"partial"`       // True when the last load missed files
 }
 
 // isValidPromoCodeFormat validates promo code format
@@ -345,8 +848,40 @@ func isValidPromoCodeFormat(code string) bool {
 	return true
 }
 
-// ForceReload manually triggers a reload of coupon codes
-func (p *PromoCodeService) ForceReload() {
-	log.Println("Manual reload of promo codes requested")
-	go p.downloadCodesAsync()
+// ForceReload manually triggers a background reload of coupon codes.
+// It returns ErrReloadInProgress while a load is running and
+// ErrServiceStopped after Shutdown. The load logs the request ID of ctx,
+// so it can be tied to the request that asked for it.
+func (p *PromoCodeService) ForceReload(ctx context.Context) error {
+	logger := p.logger
+	if id := requestid.FromContext(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	logger.Info("Manual reload of promo codes requested")
+	return p.startLoad(ctx)
+}
+
+// IsLoading reports whether a background load is running
+func (p *PromoCodeService) IsLoading() bool {
+	return atomic.LoadInt32(&p.loading) == 1
+}
+
+// DownloadProgress returns the progress of the current or last load's
+// downloads. Local sources do not download and are not listed.
+func (p *PromoCodeService) DownloadProgress() []DownloadProgress {
+	return p.downloads.Downloads()
+}
+
+// LoadErrors returns the error of the last load, nil if it succeeded, and
+// the recent failed loads, newest first
+func (p *PromoCodeService) LoadErrors() (*LoadError, []LoadError) {
+	p.errorMutex.RLock()
+	defer p.errorMutex.RUnlock()
+
+	history := append([]LoadError(nil), p.loadErrors...)
+	if p.loadError == nil || len(history) == 0 {
+		return nil, history
+	}
+	last := history[0]
+	return &last, history
 }
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/metrics"
+)
+
+// Outcomes of a background load in promo_load_duration_seconds
+const (
+	loadSucceeded = "success"
+	loadPartial   = "partial"
+	loadFailed    = "failure"
+)
+
+// loadDurationBuckets cover loads from a warm cache to slow full downloads
+var loadDurationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200}
+
+// promoMetrics are the metrics of a PromoCodeService
+type promoMetrics struct {
+	validations   *metrics.CounterVec
+	downloadBytes *metrics.GaugeVec
+	loadDuration  *metrics.HistogramVec
+	lastSuccess   *metrics.Gauge
+}
+
+// newPromoMetrics registers the metrics of p in reg
+func newPromoMetrics(reg *metrics.Registry, p *PromoCodeService) *promoMetrics {
+	reg.NewGaugeFunc("promo_codes_loaded", "Valid promo codes currently served", func() float64 {
+		return float64(p.GetValidCodesCount())
+	})
+	return &promoMetrics{
+		validations: reg.NewCounterVec("promo_validations_total",
+			"Promo codes checked when ordering, by result: hit or miss", "result"),
+		downloadBytes: reg.NewGaugeVec("promo_download_bytes",
+			"Bytes of each coupon file downloaded by the last load, 0 when the cached file was reused", "file"),
+		loadDuration: reg.NewHistogramVec("promo_load_duration_seconds",
+			"Duration of background loads of the coupon files, by result: success, partial or failure",
+			loadDurationBuckets, "result"),
+		lastSuccess: reg.NewGauge("promo_last_successful_load_timestamp_seconds",
+			"Unix time the last complete load finished, 0 before the first"),
+	}
+}
+
+// validated counts a promo code check
+func (m *promoMetrics) validated(valid bool) {
+	result := "miss"
+	if valid {
+		result = "hit"
+	}
+	m.validations.With(result).Inc()
+}
+
+// loaded records a finished load and the downloads it made
+func (m *promoMetrics) loaded(result string, started time.Time, downloads []DownloadProgress) {
+	finished := time.Now()
+	m.loadDuration.With(result).Observe(finished.Sub(started).Seconds())
+	if result == loadSucceeded {
+		m.lastSuccess.Set(float64(finished.Unix()))
+	}
+
+	for _, download := range downloads {
+		var bytes int64
+		if download.State != DownloadCached {
+			bytes = download.BytesComplete
+		}
+		m.downloadBytes.With(download.File).Set(float64(bytes))
+	}
+}
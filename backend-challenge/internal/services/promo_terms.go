@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Reasons a valid promo code cannot be redeemed
+var (
+	ErrPromoNotStarted  = errors.New("promo code is not active yet")
+	ErrPromoExpired     = errors.New("promo code has expired")
+	ErrPromoExhausted   = errors.New("promo code has reached its redemption limit")
+	ErrPromoAlreadyUsed = errors.New("promo code was already used by this customer")
+	ErrCustomerRequired = errors.New("promo code is limited per customer, customerId is required")
+)
+
+// PromoTerms restricts when and how often a promo code can be redeemed.
+// Zero values leave the respective restriction off.
+type PromoTerms struct {
+	Code           string    `yaml:"code"`
+	StartsAt       time.Time `yaml:"startsAt"`       // First moment the code is accepted
+	EndsAt         time.Time `yaml:"endsAt"`         // First moment the code is no longer accepted
+	MaxRedemptions int       `yaml:"maxRedemptions"` // Cap over all customers
+	MaxPerCustomer int       `yaml:"maxPerCustomer"` // Cap per customerId
+}
+
+// activeAt checks the date window of the terms
+func (t PromoTerms) activeAt(now time.Time) error {
+	if !t.StartsAt.IsZero() && now.Before(t.StartsAt) {
+		return ErrPromoNotStarted
+	}
+	if !t.EndsAt.IsZero() && !now.Before(t.EndsAt) {
+		return ErrPromoExpired
+	}
+	return nil
+}
+
+// promoTermsFile is the layout of a promo terms file
+type promoTermsFile struct {
+	Promos []PromoTerms `yaml:"promos"`
+}
+
+// LoadPromoTermsFile reads promo terms from a YAML (or JSON) file:
+//
+//	promos:
+//	  - code: SPRING24
+//	    startsAt: 2025-09-01T00:00:00Z
+//	    endsAt: 2025-12-01T00:00:00Z
+//	    maxRedemptions: 1000
+//	    maxPerCustomer: 1
+func LoadPromoTermsFile(path string) ([]PromoTerms, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read promo terms file: %w", err)
+	}
+
+	var file promoTermsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse promo terms file %s: %w", path, err)
+	}
+	return file.Promos, nil
+}
+
+// PromoRedemptions layers promo terms over the file-derived valid codes and
+// records redemptions. Codes without terms can be redeemed without limit.
+type PromoRedemptions struct {
+	terms map[string]PromoTerms
+	store repository.RedemptionStore
+	now   func() time.Time
+}
+
+// NewPromoRedemptions validates terms and creates the redemption service
+func NewPromoRedemptions(terms []PromoTerms, store repository.RedemptionStore) (*PromoRedemptions, error) {
+	r := &PromoRedemptions{
+		terms: make(map[string]PromoTerms, len(terms)),
+		store: store,
+		now:   time.Now,
+	}
+
+	for _, t := range terms {
+		t.Code = strings.ToUpper(strings.TrimSpace(t.Code))
+		switch {
+		case !isValidPromoCodeFormat(t.Code):
+			return nil, fmt.Errorf("promo terms for invalid code %q", t.Code)
+		case r.terms[t.Code] != PromoTerms{}:
+			return nil, fmt.Errorf("duplicate promo terms for %s", t.Code)
+		case !t.StartsAt.IsZero() && !t.EndsAt.IsZero() && !t.EndsAt.After(t.StartsAt):
+			return nil, fmt.Errorf("promo terms for %s end before they start", t.Code)
+		case t.MaxRedemptions < 0 || t.MaxPerCustomer < 0:
+			return nil, fmt.Errorf("promo terms for %s have a negative limit", t.Code)
+		}
+		r.terms[t.Code] = t
+	}
+	return r, nil
+}
+
+// Terms returns the terms of a code, if it has any
+func (r *PromoRedemptions) Terms(code string) (PromoTerms, bool) {
+	t, ok := r.terms[strings.ToUpper(code)]
+	return t, ok
+}
+
+// Redeem records that an order used a code, or returns why it cannot:
+// ErrPromoNotStarted, ErrPromoExpired, ErrPromoExhausted, ErrPromoAlreadyUsed
+// or ErrCustomerRequired. The limits are checked and the redemption
+// recorded atomically, so concurrent orders never exceed a cap.
+func (r *PromoRedemptions) Redeem(ctx context.Context, code, customerID, orderID string) error {
+	code = strings.ToUpper(code)
+	t, ok := r.terms[code]
+	if !ok {
+		return nil
+	}
+
+	if err := t.activeAt(r.now()); err != nil {
+		return err
+	}
+	if t.MaxPerCustomer > 0 && customerID == "" {
+		return ErrCustomerRequired
+	}
+	if t.MaxRedemptions == 0 && t.MaxPerCustomer == 0 {
+		return nil
+	}
+
+	err := r.store.Redeem(ctx, repository.Redemption{
+		Code:       code,
+		CustomerID: customerID,
+		OrderID:    orderID,
+		At:         r.now(),
+	}, repository.RedemptionLimits{Total: t.MaxRedemptions, PerCustomer: t.MaxPerCustomer})
+
+	switch {
+	case errors.Is(err, repository.ErrRedemptionsExhausted):
+		return ErrPromoExhausted
+	case errors.Is(err, repository.ErrAlreadyRedeemed):
+		return ErrPromoAlreadyUsed
+	case err != nil:
+		return fmt.Errorf("failed to record redemption of %s: %w", code, err)
+	}
+	return nil
+}
+
+// Cancel gives back the redemption of an order that was not placed after all
+func (r *PromoRedemptions) Cancel(ctx context.Context, code, orderID string) error {
+	code = strings.ToUpper(code)
+	if _, ok := r.terms[code]; !ok {
+		return nil
+	}
+	return r.store.Cancel(ctx, code, orderID)
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPromoTermsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "promos.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`promos:
+  - code: SPRING24
+    startsAt: 2025-09-01T00:00:00Z
+    endsAt: 2025-12-01T00:00:00Z
+    maxRedemptions: 1000
+    maxPerCustomer: 1
+  - code: HAPPYHRS
+`), 0o600))
+
+	terms, err := LoadPromoTermsFile(path)
+	require.NoError(t, err)
+	require.Len(t, terms, 2)
+	assert.Equal(t, "SPRING24", terms[0].Code)
+	assert.Equal(t, time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), terms[0].StartsAt)
+	assert.Equal(t, 1000, terms[0].MaxRedemptions)
+	assert.Equal(t, 1, terms[0].MaxPerCustomer)
+	assert.True(t, terms[1].EndsAt.IsZero())
+
+	_, err = LoadPromoTermsFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestNewPromoRedemptions_RejectsInvalidTerms(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		terms []PromoTerms
+	}{
+		{"Invalid code", []PromoTerms{{Code: "NO"}}},
+		{"Duplicate code", []PromoTerms{{Code: "HAPPYHRS"}, {Code: "happyhrs", MaxRedemptions: 1}}},
+		{"Ends before start", []PromoTerms{{Code: "HAPPYHRS", StartsAt: now, EndsAt: now.Add(-time.Hour)}}},
+		{"Negative cap", []PromoTerms{{Code: "HAPPYHRS", MaxRedemptions: -1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPromoRedemptions(tt.terms, repository.NewMemoryRedemptionStore())
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPromoRedemptions_Redeem(t *testing.T) {
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	store := repository.NewMemoryRedemptionStore()
+	r, err := NewPromoRedemptions([]PromoTerms{
+		{Code: "spring24", StartsAt: start, EndsAt: start.AddDate(0, 1, 0), MaxRedemptions: 2, MaxPerCustomer: 1},
+		{Code: "WINDOW24", StartsAt: start, EndsAt: start.AddDate(0, 1, 0)},
+	}, store)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	r.now = func() time.Time { return start.Add(-time.Second) }
+	assert.ErrorIs(t, r.Redeem(ctx, "SPRING24", "alice", "o1"), ErrPromoNotStarted)
+
+	r.now = func() time.Time { return start }
+	assert.ErrorIs(t, r.Redeem(ctx, "SPRING24", "", "o1"), ErrCustomerRequired)
+	require.NoError(t, r.Redeem(ctx, "spring24", "alice", "o1"))
+	assert.ErrorIs(t, r.Redeem(ctx, "SPRING24", "alice", "o2"), ErrPromoAlreadyUsed)
+	require.NoError(t, r.Redeem(ctx, "SPRING24", "bob", "o3"))
+	assert.ErrorIs(t, r.Redeem(ctx, "SPRING24", "carol", "o4"), ErrPromoExhausted)
+
+	require.NoError(t, r.Cancel(ctx, "SPRING24", "o3"))
+	require.NoError(t, r.Redeem(ctx, "SPRING24", "carol", "o4"), "A cancelled redemption frees its slot")
+
+	r.now = func() time.Time { return start.AddDate(0, 1, 0) }
+	assert.ErrorIs(t, r.Redeem(ctx, "SPRING24", "dave", "o5"), ErrPromoExpired)
+	assert.ErrorIs(t, r.Redeem(ctx, "WINDOW24", "", "o6"), ErrPromoExpired)
+
+	// Codes without caps or without terms are not recorded
+	r.now = func() time.Time { return start }
+	require.NoError(t, r.Redeem(ctx, "WINDOW24", "", "o7"))
+	require.NoError(t, r.Redeem(ctx, "HAPPYHRS", "", "o8"))
+	total, _, err := store.Count(ctx, "WINDOW24", "")
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	terms, ok := r.Terms("spring24")
+	assert.True(t, ok)
+	assert.Equal(t, 2, terms.MaxRedemptions)
+	_, ok = r.Terms("HAPPYHRS")
+	assert.False(t, ok)
+}
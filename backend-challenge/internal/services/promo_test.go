@@ -1,7 +1,23 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/ilyulev/kart-challenge/backend-api/internal/requestid"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/tracing"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestIsValidPromoCodeFormat(t *testing.T) {
@@ -43,9 +59,7 @@ func TestPromoCodeService_IsValidPromoCode(t *testing.T) {
 	service := NewPromoCodeService()
 
 	// Manually add some test codes
-	service.validCodes["HAPPYHRS"] = true
-	service.validCodes["FIFTYOFF"] = true
-	service.validCodes["TESTCODE"] = true
+	service.validCodes = newCodeSet([]string{"HAPPYHRS", "FIFTYOFF", "TESTCODE"})
 
 	tests := []struct {
 		name     string
@@ -95,3 +109,319 @@ func TestPromoCodeService_GetServiceStatus(t *testing.T) {
 		t.Errorf("Expected 'mock' data source, got %s", status.DataSource)
 	}
 }
+
+func TestPromoCodeService_LoadFromFixtureSources(t *testing.T) {
+	dir := t.TempDir()
+	writeGzipFixture(t, dir, "couponbase1.gz", "HAPPYHRS FIFTYOFF onlyinone1")
+	writeGzipFixture(t, dir, "couponbase2.gz", "happyhrs SUPER100 short")
+	writeGzipFixture(t, dir, "couponbase3.gz", "FIFTYOFF, SUPER999")
+
+	service := NewPromoCodeService(WithCouponSources(NewDirectorySource(dir)))
+	if err := service.loadCodes(context.Background()); err != nil {
+		t.Fatalf("loadCodes() error = %v", err)
+	}
+
+	tests := []struct {
+		code     string
+		expected bool
+	}{
+		{"HAPPYHRS", true},
+		{"FIFTYOFF", true},
+		{"ONLYINONE1", false},
+		{"SUPER100", false},
+		{"SUPER999", false},
+	}
+
+	for _, tt := range tests {
+		if got := service.IsValidPromoCode(tt.code); got != tt.expected {
+			t.Errorf("IsValidPromoCode(%q) = %v, want %v", tt.code, got, tt.expected)
+		}
+	}
+
+	if service.GetValidCodesCount() != 2 {
+		t.Errorf("Expected 2 valid codes, got %d", service.GetValidCodesCount())
+	}
+}
+
+func TestPromoCodeService_LoadFailsWithoutFiles(t *testing.T) {
+	service := NewPromoCodeService(WithCouponSources(NewFileSource(t.TempDir() + "/missing.gz")))
+	if err := service.loadCodes(context.Background()); err == nil {
+		t.Error("Expected error when no coupon files can be fetched")
+	}
+}
+
+func TestProcessGzipFile_CodesAcrossReadBoundaries(t *testing.T) {
+	// The previous reader split every 64 KB chunk on its own, losing codes
+	// that straddled a chunk and producing bogus fragments
+	var b strings.Builder
+	var expected []string
+	for i, boundary := range []int{64 * 1024, 2 * 64 * 1024, 3 * 64 * 1024} {
+		for b.Len() < boundary-4 {
+			b.WriteString("x ")
+		}
+		code := fmt.Sprintf("ACROSS%02dZZ", i)
+		b.WriteString(code + " ")
+		expected = append(expected, code)
+	}
+
+	path := writeGzipFixture(t, t.TempDir(), "boundary.gz", b.String())
+	keys, err := NewPromoCodeService().processGzipFile(path)
+	if err != nil {
+		t.Fatalf("processGzipFile() error = %v", err)
+	}
+
+	got := (&codeSet{keys: keys}).Codes()
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("processGzipFile() codes = %v, want %v", got, expected)
+	}
+}
+
+// slowSource records how many fetches run at the same time
+type slowSource struct {
+	name    string
+	path    string
+	running *int32
+	peak    *int32
+}
+
+func (s *slowSource) Name() string { return s.name }
+
+func (s *slowSource) Fetch(ctx context.Context, workDir string) ([]CouponFile, error) {
+	n := atomic.AddInt32(s.running, 1)
+	defer atomic.AddInt32(s.running, -1)
+	for {
+		peak := atomic.LoadInt32(s.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(s.peak, peak, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return []CouponFile{{Name: s.name, Path: s.path}}, nil
+}
+
+func TestPromoCodeService_FetchesSourcesConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	var running, peak int32
+	var sources []CouponSource
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("couponbase%d.gz", i)
+		path := writeGzipFixture(t, dir, name, "HAPPYHRS")
+		sources = append(sources, &slowSource{name: name, path: path, running: &running, peak: &peak})
+	}
+
+	service := NewPromoCodeService(WithCouponSources(sources...), WithDownloadConcurrency(2))
+	files, _, err := service.fetchSources(context.Background(), t.TempDir())
+	if err != nil {
+		t.Fatalf("fetchSources() error = %v", err)
+	}
+
+	if len(files) != 6 {
+		t.Fatalf("Expected 6 files, got %d", len(files))
+	}
+	for i, file := range files {
+		if want := fmt.Sprintf("couponbase%d.gz", i); file.Name != want {
+			t.Errorf("files[%d] = %s, want %s (source order)", i, file.Name, want)
+		}
+	}
+	if peak != 2 {
+		t.Errorf("Expected 2 concurrent fetches at peak, got %d", peak)
+	}
+}
+
+// blockingSource holds Fetch until release is closed, then fails
+type blockingSource struct {
+	release chan struct{}
+}
+
+func (s *blockingSource) Name() string { return "blocking" }
+
+func (s *blockingSource) Fetch(ctx context.Context, workDir string) ([]CouponFile, error) {
+	<-s.release
+	return nil, fmt.Errorf("source unavailable")
+}
+
+func TestPromoCodeService_ForceReloadRecordsErrors(t *testing.T) {
+	source := &blockingSource{release: make(chan struct{})}
+	service := NewPromoCodeService(WithCouponSources(source))
+
+	if err := service.ForceReload(context.Background()); err != nil {
+		t.Fatalf("ForceReload() error = %v", err)
+	}
+	if err := service.ForceReload(context.Background()); err != ErrReloadInProgress {
+		t.Errorf("Second ForceReload() error = %v, want ErrReloadInProgress", err)
+	}
+
+	close(source.release)
+	deadline := time.Now().Add(2 * time.Second)
+	for service.IsLoading() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if service.IsLoading() {
+		t.Fatal("Load did not finish")
+	}
+
+	last, history := service.LoadErrors()
+	if last == nil || !strings.Contains(last.Error, "no coupon files fetched") {
+		t.Fatalf("LoadErrors() last = %+v, want fetch failure", last)
+	}
+	if len(history) != 1 || history[0].FilesFailed != 1 {
+		t.Errorf("LoadErrors() history = %+v, want one load with one failed file", history)
+	}
+
+	// The history is bounded, newest first
+	for i := 0; i < maxLoadErrors+5; i++ {
+		service.recordLoadError(fmt.Errorf("failure %d", i), time.Now())
+	}
+	_, history = service.LoadErrors()
+	if len(history) != maxLoadErrors || history[0].Error != fmt.Sprintf("failure %d", maxLoadErrors+4) {
+		t.Errorf("History has %d entries starting with %q", len(history), history[0].Error)
+	}
+}
+
+func TestPromoCodeService_ShutdownCancelsDownload(t *testing.T) {
+	// The server sends part of a coupon file, then stalls until the client gives up
+	requested := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Header().Set("Content-Length", "1048576")
+		w.WriteHeader(http.StatusOK)
+		w.Write(make([]byte, 1024))
+		w.(http.Flusher).Flush()
+		close(requested)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	source, err := NewHTTPSource(server.URL + "/couponbase1.gz")
+	if err != nil {
+		t.Fatalf("NewHTTPSource() error = %v", err)
+	}
+	service := NewPromoCodeService(WithCouponSources(source), WithCacheDir(t.TempDir()))
+	if err := service.Initialize(context.Background()); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	<-requested
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := service.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if service.IsLoading() {
+		t.Error("Load still running after Shutdown")
+	}
+	if last, _ := service.LoadErrors(); last != nil {
+		t.Errorf("LoadErrors() last = %+v, want none for a cancelled load", last)
+	}
+	if status := service.GetServiceStatus(); status.DataSource != "mock" {
+		t.Errorf("DataSource = %s, want mock data kept", status.DataSource)
+	}
+
+	if err := service.ForceReload(context.Background()); !errors.Is(err, ErrServiceStopped) {
+		t.Errorf("ForceReload() after Shutdown error = %v, want ErrServiceStopped", err)
+	}
+}
+
+func TestPromoCodeService_LoadIsTraced(t *testing.T) {
+	dir := t.TempDir()
+	writeGzipFixture(t, dir, "couponbase1.gz", "HAPPYHRS FIFTYOFF")
+	writeGzipFixture(t, dir, "couponbase2.gz", "HAPPYHRS")
+	remote := writeGzipFixture(t, t.TempDir(), "couponbase3.gz", "FIFTYOFF")
+
+	// The origin sees the trace of the load
+	var traceparent atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent.Store(r.Header.Get("traceparent"))
+		http.ServeFile(w, r, remote)
+	}))
+	defer server.Close()
+	source, err := NewHTTPSource(server.URL + "/couponbase3.gz")
+	if err != nil {
+		t.Fatalf("NewHTTPSource() error = %v", err)
+	}
+
+	provider, exporter := tracing.NewInMemory()
+	service := NewPromoCodeService(WithCouponSources(NewDirectorySource(dir), source), WithTracerProvider(provider))
+	service.downloadCodesAsync(context.Background())
+
+	spans := exporter.GetSpans()
+	byName := make(map[string][]tracetest.SpanStub)
+	for _, span := range spans {
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+	if len(byName["promo.load"]) != 1 {
+		t.Fatalf("Spans = %v, want one promo.load", byName)
+	}
+	load := byName["promo.load"][0]
+	if load.Parent.IsValid() {
+		t.Error("promo.load has a parent, want a trace of its own")
+	}
+
+	wantChildren := map[string]int{"promo.fetch_source": 2, "promo.parse_file": 3, "promo.select_codes": 1}
+	for name, want := range wantChildren {
+		if got := len(byName[name]); got != want {
+			t.Errorf("%d %s spans, want %d", got, name, want)
+		}
+		for _, span := range byName[name] {
+			if span.Parent.SpanID() != load.SpanContext.SpanID() {
+				t.Errorf("%s is not a child of promo.load", name)
+			}
+		}
+	}
+
+	want := fmt.Sprintf("00-%s-", load.SpanContext.TraceID())
+	if got, _ := traceparent.Load().(string); !strings.HasPrefix(got, want) {
+		t.Errorf("traceparent = %q, want trace %s", got, load.SpanContext.TraceID())
+	}
+}
+
+func TestPromoCodeService_ReloadLogsRequestID(t *testing.T) {
+	dir := t.TempDir()
+	writeGzipFixture(t, dir, "couponbase1.gz", "HAPPYHRS")
+	writeGzipFixture(t, dir, "couponbase2.gz", "HAPPYHRS")
+
+	var logs syncBuffer
+	service := NewPromoCodeService(WithCouponSources(NewDirectorySource(dir)),
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	if err := service.ForceReload(requestid.NewContext(context.Background(), "req-reload")); err != nil {
+		t.Fatalf("ForceReload() error = %v", err)
+	}
+	for service.IsLoading() {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for _, msg := range []string{"Manual reload of promo codes requested", "Background load completed"} {
+		var found bool
+		for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+			if strings.Contains(line, `"msg":"`+msg+`"`) {
+				found = true
+				if !strings.Contains(line, `"request_id":"req-reload"`) {
+					t.Errorf("%s logged without the request ID: %s", msg, line)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s not logged", msg)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for a logger and a test to share
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Snapshot file layout (all integers big endian):
+//
+//	magic       [6]byte "PCSNAP"
+//	version     uint16
+//	createdAt   int64   unix seconds
+//	fingerprint uint16 length + bytes
+//	count       uint32
+//	codes       count x (uint8 length + bytes), sorted ascending
+//	checksum    uint32  CRC-32 (IEEE) of everything before it
+const (
+	snapshotMagic   = "PCSNAP"
+	snapshotVersion = 1
+)
+
+// ErrSnapshotNotFound is returned when no snapshot file exists yet
+var ErrSnapshotNotFound = errors.New("promo snapshot not found")
+
+// promoSnapshot is the persisted valid-code set
+type promoSnapshot struct {
+	Fingerprint string
+	CreatedAt   time.Time
+	Codes       []string
+}
+
+// writeSnapshot atomically writes the snapshot to path
+func writeSnapshot(path string, snap *promoSnapshot) error {
+	if len(snap.Fingerprint) > 0xFFFF {
+		return fmt.Errorf("snapshot fingerprint too long")
+	}
+
+	codes := append([]string(nil), snap.Codes...)
+	sort.Strings(codes)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".promo-snapshot-*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	crc := crc32.NewIEEE()
+	w := bufio.NewWriter(io.MultiWriter(tmp, crc))
+
+	header := make([]byte, 0, 32)
+	header = append(header, snapshotMagic...)
+	header = binary.BigEndian.AppendUint16(header, snapshotVersion)
+	header = binary.BigEndian.AppendUint64(header, uint64(snap.CreatedAt.Unix()))
+	header = binary.BigEndian.AppendUint16(header, uint16(len(snap.Fingerprint)))
+	header = append(header, snap.Fingerprint...)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(codes)))
+	w.Write(header)
+
+	for _, code := range codes {
+		if len(code) > 0xFF {
+			tmp.Close()
+			return fmt.Errorf("snapshot code too long: %q", code)
+		}
+		w.WriteByte(byte(len(code)))
+		w.WriteString(code)
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := binary.Write(tmp, binary.BigEndian, crc.Sum32()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot checksum: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+	return nil
+}
+
+// readSnapshot loads and verifies a snapshot file
+func readSnapshot(path string) (*promoSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	if len(data) < len(snapshotMagic)+2+8+2+4+4 {
+		return nil, fmt.Errorf("snapshot truncated")
+	}
+
+	body, trailer := data[:len(data)-4], data[len(data)-4:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(trailer) {
+		return nil, fmt.Errorf("snapshot checksum mismatch")
+	}
+
+	r := bytes.NewReader(body)
+
+	magic := make([]byte, len(snapshotMagic))
+	io.ReadFull(r, magic)
+	if string(magic) != snapshotMagic {
+		return nil, fmt.Errorf("not a promo snapshot file")
+	}
+
+	var header struct {
+		Version   uint16
+		CreatedAt int64
+		FPLen     uint16
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("snapshot header: %w", err)
+	}
+	if header.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", header.Version)
+	}
+
+	fingerprint := make([]byte, header.FPLen)
+	if _, err := io.ReadFull(r, fingerprint); err != nil {
+		return nil, fmt.Errorf("snapshot fingerprint: %w", err)
+	}
+
+	var count uint32
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, fmt.Errorf("snapshot count: %w", err)
+	}
+
+	// Every code takes at least 2 bytes, guard against corrupt counts
+	if int64(count)*2 > int64(r.Len()) {
+		return nil, fmt.Errorf("snapshot count %d exceeds file size", count)
+	}
+
+	codes := make([]string, 0, count)
+	buf := make([]byte, 0xFF)
+	for i := uint32(0); i < count; i++ {
+		n, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("snapshot code %d: %w", i, err)
+		}
+		if _, err := io.ReadFull(r, buf[:n]); err != nil {
+			return nil, fmt.Errorf("snapshot code %d: %w", i, err)
+		}
+		codes = append(codes, string(buf[:n]))
+	}
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("snapshot has %d trailing bytes", r.Len())
+	}
+
+	return &promoSnapshot{
+		Fingerprint: string(fingerprint),
+		CreatedAt:   time.Unix(header.CreatedAt, 0),
+		Codes:       codes,
+	}, nil
+}
+
+// fingerprintFiles identifies the content and weight of a set of coupon files
+// and the rule applied to them. Files with an ETag use it, others are hashed.
+func fingerprintFiles(files []CouponFile, rule string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "rule=%s\n", rule)
+
+	for _, file := range files {
+		id := file.ETag
+		if id == "" && file.SHA256 != "" {
+			id = "sha256:" + file.SHA256
+		}
+		if id == "" {
+			sum, err := fileSHA256(file.Path)
+			if err != nil {
+				return "", err
+			}
+			id = "sha256:" + sum
+		}
+		fmt.Fprintf(h, "%s=%s;weight=%d\n", file.Name, id, file.Weight)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileSHA256 returns the hex encoded SHA-256 of a file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
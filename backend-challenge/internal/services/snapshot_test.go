@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "promo.snap")
+	created := time.Unix(1700000000, 0)
+
+	err := writeSnapshot(path, &promoSnapshot{
+		Fingerprint: "abc123",
+		CreatedAt:   created,
+		Codes:       []string{"HAPPYHRS", "FIFTYOFF", "DISCOUNT10"},
+	})
+	require.NoError(t, err)
+
+	snap, err := readSnapshot(path)
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", snap.Fingerprint)
+	assert.True(t, created.Equal(snap.CreatedAt))
+	assert.Equal(t, []string{"DISCOUNT10", "FIFTYOFF", "HAPPYHRS"}, snap.Codes, "Codes should be stored sorted")
+}
+
+func TestSnapshot_ReadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := readSnapshot(filepath.Join(dir, "missing.snap"))
+	assert.ErrorIs(t, err, ErrSnapshotNotFound)
+
+	path := filepath.Join(dir, "promo.snap")
+	require.NoError(t, writeSnapshot(path, &promoSnapshot{Fingerprint: "fp", Codes: []string{"HAPPYHRS"}}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-6] ^= 0xFF
+	require.NoError(t, os.WriteFile(path, corrupt, 0o644))
+	_, err = readSnapshot(path)
+	assert.ErrorContains(t, err, "checksum")
+
+	require.NoError(t, os.WriteFile(path, data[:10], 0o644))
+	_, err = readSnapshot(path)
+	assert.Error(t, err)
+}
+
+func TestFingerprintFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := writeGzipFixture(t, dir, "a.gz", "HAPPYHRS")
+	b := writeGzipFixture(t, dir, "b.gz", "FIFTYOFF")
+
+	files := []CouponFile{{Name: "a.gz", Path: a}, {Name: "b.gz", Path: b}}
+	rule := DefaultPromoPolicy().rule()
+	first, err := fingerprintFiles(files, rule)
+	require.NoError(t, err)
+
+	again, err := fingerprintFiles(files, rule)
+	require.NoError(t, err)
+	assert.Equal(t, first, again, "Fingerprint should be deterministic")
+
+	otherRule, err := fingerprintFiles(files, PromoPolicy{MinWeight: 3}.rule())
+	require.NoError(t, err)
+	assert.NotEqual(t, first, otherRule, "Rule change should change the fingerprint")
+
+	weighted := []CouponFile{{Name: "a.gz", Path: a, Weight: 2}, {Name: "b.gz", Path: b}}
+	reweighted, err := fingerprintFiles(weighted, rule)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, reweighted, "Weight change should change the fingerprint")
+
+	writeGzipFixture(t, dir, "b.gz", "FIFTYOFF DISCOUNT10")
+	changed, err := fingerprintFiles(files, rule)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, changed, "Content change should change the fingerprint")
+
+	// ETag identifies remote files without hashing them
+	tagged := []CouponFile{{Name: "remote.gz", Path: filepath.Join(dir, "missing.gz"), ETag: `"v1"`}}
+	_, err = fingerprintFiles(tagged, rule)
+	assert.NoError(t, err)
+}
+
+func TestPromoCodeService_SnapshotWarmStart(t *testing.T) {
+	dir := t.TempDir()
+	writeGzipFixture(t, dir, "couponbase1.gz", "HAPPYHRS FIFTYOFF")
+	writeGzipFixture(t, dir, "couponbase2.gz", "HAPPYHRS FIFTYOFF")
+	snapPath := filepath.Join(t.TempDir(), "promo.snap")
+
+	// Cold start builds the snapshot
+	cold := NewPromoCodeService(WithCouponSources(NewDirectorySource(dir)), WithSnapshotPath(snapPath))
+	require.NoError(t, cold.loadCodes(context.Background()))
+	assert.Equal(t, "remote", cold.GetServiceStatus().DataSource)
+	require.FileExists(t, snapPath)
+
+	// Warm start serves the snapshot before any source is read
+	warm := NewPromoCodeService(WithCouponSources(NewDirectorySource(dir)), WithSnapshotPath(snapPath))
+	require.NoError(t, warm.loadSnapshot())
+	assert.Equal(t, "snapshot", warm.GetServiceStatus().DataSource)
+	assert.True(t, warm.IsValidPromoCode("HAPPYHRS"))
+	assert.Equal(t, 2, warm.GetValidCodesCount())
+
+	// Unchanged sources keep the snapshot
+	require.NoError(t, warm.loadCodes(context.Background()))
+	assert.Equal(t, "snapshot", warm.GetServiceStatus().DataSource)
+
+	// Changed sources rebuild the snapshot
+	writeGzipFixture(t, dir, "couponbase2.gz", "HAPPYHRS")
+	require.NoError(t, warm.loadCodes(context.Background()))
+	assert.Equal(t, "remote", warm.GetServiceStatus().DataSource)
+	assert.False(t, warm.IsValidPromoCode("FIFTYOFF"))
+
+	snap, err := readSnapshot(snapPath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HAPPYHRS"}, snap.Codes)
+}
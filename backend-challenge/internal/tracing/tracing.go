@@ -0,0 +1,110 @@
+// Package tracing sets up OpenTelemetry tracing: the exporter spans are sent
+// to, W3C trace context propagation, and helpers to start child spans.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters selectable in the configuration
+const (
+	ExporterNone   = "none"   // Spans are created for propagation but not exported
+	ExporterStdout = "stdout" // Spans are written as JSON, for local debugging
+	ExporterOTLP   = "otlp"   // Spans are sent to an OTLP/HTTP collector
+)
+
+// ServiceName is the service.name of exported spans, unless OTEL_SERVICE_NAME is set
+const ServiceName = "kart-challenge-api"
+
+// instrumentationName names the tracer of the spans started here
+const instrumentationName = "github.com/ilyulev/kart-challenge/backend-api"
+
+// Propagator reads and writes the W3C traceparent, tracestate and baggage headers
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{}, propagation.Baggage{})
+
+// ValidateExporter checks that name is a known exporter
+func ValidateExporter(name string) error {
+	switch name {
+	case ExporterNone, ExporterStdout, ExporterOTLP:
+		return nil
+	}
+	return fmt.Errorf("unknown trace exporter %q", name)
+}
+
+// NewExporter creates the named exporter, nil for none. stdout spans are
+// written to w. The OTLP exporter is configured by the standard
+// OTEL_EXPORTER_OTLP_* variables, e.g. OTEL_EXPORTER_OTLP_ENDPOINT.
+func NewExporter(ctx context.Context, name string, w io.Writer) (sdktrace.SpanExporter, error) {
+	switch name {
+	case ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(w))
+	case ExporterOTLP:
+		return otlptracehttp.New(ctx)
+	}
+	return nil, ValidateExporter(name)
+}
+
+// NewProvider creates a tracer provider sampling sampleRatio of the traces
+// that do not continue a sampled trace, and exporting them in batches.
+// A nil exporter still starts spans, so trace context is propagated.
+func NewProvider(ctx context.Context, exporter sdktrace.SpanExporter, sampleRatio float64) (*sdktrace.TracerProvider, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", ServiceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the service: %w", err)
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+// NewInMemory creates a provider recording every span in the returned
+// exporter as soon as it ends, for tests
+func NewInMemory() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
+
+// Tracer returns the tracer of this service from provider
+func Tracer(provider trace.TracerProvider) trace.Tracer {
+	return provider.Tracer(instrumentationName)
+}
+
+// Start starts a child of the span in ctx, from the same provider. Without
+// a span in ctx nothing is recorded.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	provider := trace.SpanFromContext(ctx).TracerProvider()
+	return Tracer(provider).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it failed with err unless err is nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
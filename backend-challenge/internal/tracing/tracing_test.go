@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewExporter(t *testing.T) {
+	ctx := context.Background()
+
+	exporter, err := NewExporter(ctx, ExporterNone, nil)
+	require.NoError(t, err)
+	assert.Nil(t, exporter)
+
+	_, err = NewExporter(ctx, "zipkin", nil)
+	assert.Error(t, err)
+
+	var out bytes.Buffer
+	exporter, err = NewExporter(ctx, ExporterStdout, &out)
+	require.NoError(t, err)
+	provider, err := NewProvider(ctx, exporter, 1)
+	require.NoError(t, err)
+
+	_, span := Tracer(provider).Start(ctx, "promo.load")
+	span.End()
+	require.NoError(t, provider.Shutdown(ctx), "Shutdown flushes the batch")
+	assert.Contains(t, out.String(), `"Name":"promo.load"`)
+	assert.Contains(t, out.String(), ServiceName)
+}
+
+func TestNewProvider_Sampling(t *testing.T) {
+	ctx := context.Background()
+	provider, err := NewProvider(ctx, nil, 0)
+	require.NoError(t, err)
+	defer provider.Shutdown(ctx)
+
+	_, span := Tracer(provider).Start(ctx, "new trace")
+	assert.False(t, span.SpanContext().IsSampled())
+	assert.True(t, span.SpanContext().IsValid(), "Unsampled traces are still propagated")
+
+	// A sampled caller overrides the ratio
+	carrier := propagation.HeaderCarrier{}
+	carrier.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx = Propagator.Extract(ctx, carrier)
+	_, span = Tracer(provider).Start(ctx, "continued trace")
+	assert.True(t, span.SpanContext().IsSampled())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+}
+
+func TestStart(t *testing.T) {
+	provider, exporter := NewInMemory()
+
+	_, orphan := Start(context.Background(), "no parent")
+	assert.False(t, orphan.IsRecording(), "Spans need a parent to be recorded")
+	orphan.End()
+
+	ctx, parent := Tracer(provider).Start(context.Background(), "POST /api/order")
+	_, child := Start(ctx, "order.save", attribute.String("order.id", "ORD-1"))
+	End(child, errors.New("disk full"))
+	_, ok := Start(ctx, "order.price")
+	End(ok, nil)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	save := spans[0]
+	assert.Equal(t, "order.save", save.Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), save.Parent.SpanID())
+	assert.Equal(t, codes.Error, save.Status.Code)
+	assert.Equal(t, "disk full", save.Status.Description)
+	assert.Contains(t, save.Attributes, attribute.String("order.id", "ORD-1"))
+	require.Len(t, save.Events, 1, "The error is recorded")
+
+	assert.Equal(t, codes.Unset, spans[1].Status.Code)
+	assert.Equal(t, trace.SpanKindInternal, spans[1].SpanKind)
+}
@@ -0,0 +1,316 @@
+// Package money represents amounts as integer minor units with an ISO 4217
+// currency, so sums and discounts never accumulate floating point error.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used for amounts decoded from plain JSON numbers
+const DefaultCurrency = "USD"
+
+var (
+	// ErrCurrencyMismatch is returned when combining amounts in different currencies
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrOverflow is returned when a result does not fit in int64 minor units
+	ErrOverflow = errors.New("amount out of range")
+)
+
+// RoundingMode decides how fractions of a minor unit are rounded
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds to the nearest unit, halves away from zero
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds to the nearest unit, halves to the even unit
+	RoundHalfEven
+	// RoundDown truncates toward zero
+	RoundDown
+	// RoundUp rounds away from zero
+	RoundUp
+)
+
+// minorDigits lists currencies that do not have two decimal places
+var minorDigits = map[string]int{
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0,
+	"XOF": 0, "XPF": 0,
+}
+
+// Money is an amount in the minor unit of its currency, e.g. cents.
+// The zero value is zero with no currency, which adopts the currency of
+// whatever it is added to.
+type Money struct {
+	amount   int64
+	currency string
+}
+
+// New creates an amount of minor units in currency
+func New(minor int64, currency string) Money {
+	return Money{amount: minor, currency: strings.ToUpper(currency)}
+}
+
+// Parse reads a decimal amount such as "12.99" exactly.
+// It fails if the amount has more decimals than the currency allows.
+func Parse(s, currency string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	if !isCurrencyCode(currency) {
+		return Money{}, fmt.Errorf("invalid currency code %q", currency)
+	}
+
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+
+	r.Mul(r, new(big.Rat).SetInt(pow10(Digits(currency))))
+	if !r.IsInt() {
+		return Money{}, fmt.Errorf("amount %q has more than %d decimals for %s", s, Digits(currency), currency)
+	}
+	if !r.Num().IsInt64() {
+		return Money{}, fmt.Errorf("amount %q out of range", s)
+	}
+	return Money{amount: r.Num().Int64(), currency: currency}, nil
+}
+
+// MustParse is like Parse but panics on error, for literals
+func MustParse(s, currency string) Money {
+	m, err := Parse(s, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Digits returns the number of decimals of a currency's minor unit
+func Digits(currency string) int {
+	if digits, ok := minorDigits[currency]; ok {
+		return digits
+	}
+	return 2
+}
+
+// Minor returns the amount in minor units
+func (m Money) Minor() int64 {
+	return m.amount
+}
+
+// Currency returns the ISO 4217 currency code, empty for the zero value
+func (m Money) Currency() string {
+	return m.currency
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.amount == 0
+}
+
+// IsPositive reports whether the amount is above zero
+func (m Money) IsPositive() bool {
+	return m.amount > 0
+}
+
+// IsNegative reports whether the amount is below zero
+func (m Money) IsNegative() bool {
+	return m.amount < 0
+}
+
+// Add returns m + other
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := m.common(other)
+	if err != nil {
+		return Money{}, err
+	}
+	sum := m.amount + other.amount
+	if (sum > m.amount) != (other.amount > 0) {
+		return Money{}, ErrOverflow
+	}
+	return Money{amount: sum, currency: currency}, nil
+}
+
+// Sub returns m - other
+func (m Money) Sub(other Money) (Money, error) {
+	currency, err := m.common(other)
+	if err != nil {
+		return Money{}, err
+	}
+	diff := m.amount - other.amount
+	if (diff < m.amount) != (other.amount > 0) {
+		return Money{}, ErrOverflow
+	}
+	return Money{amount: diff, currency: currency}, nil
+}
+
+// Mul returns m times a whole quantity
+func (m Money) Mul(n int64) (Money, error) {
+	product := new(big.Int).Mul(big.NewInt(m.amount), big.NewInt(n))
+	if !product.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{amount: product.Int64(), currency: m.currency}, nil
+}
+
+// MulFrac returns m * num / den rounded to a minor unit with mode
+func (m Money) MulFrac(num, den int64, mode RoundingMode) (Money, error) {
+	n := new(big.Int).Mul(big.NewInt(m.amount), big.NewInt(num))
+	amount, err := divRound(n, big.NewInt(den), mode)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{amount: amount, currency: m.currency}, nil
+}
+
+// Percent returns percent of m rounded to a minor unit with mode
+func (m Money) Percent(percent int64, mode RoundingMode) (Money, error) {
+	return m.MulFrac(percent, 100, mode)
+}
+
+// Cmp compares two amounts, returning -1, 0 or +1
+func (m Money) Cmp(other Money) (int, error) {
+	if _, err := m.common(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.amount < other.amount:
+		return -1, nil
+	case m.amount > other.amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Decimal formats the amount with the currency's decimals, e.g. "12.99"
+func (m Money) Decimal() string {
+	digits := Digits(m.currency)
+	amount := m.amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+
+	abs := new(big.Int).Abs(big.NewInt(amount)).String()
+	if digits == 0 {
+		return sign + abs
+	}
+	if len(abs) <= digits {
+		abs = strings.Repeat("0", digits-len(abs)+1) + abs
+	}
+	return sign + abs[:len(abs)-digits] + "." + abs[len(abs)-digits:]
+}
+
+// String formats the amount with its currency, e.g. "12.99 USD"
+func (m Money) String() string {
+	if m.currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.currency
+}
+
+// MarshalJSON encodes the amount as a JSON number, e.g. 12.99
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+// UnmarshalJSON decodes a JSON number exactly. The receiver keeps its
+// currency if it has one, DefaultCurrency is used otherwise.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	currency := m.currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	s := string(data)
+	if s == "" || s[0] == '"' || s == "null" {
+		return fmt.Errorf("money: expected a JSON number, got %s", s)
+	}
+
+	parsed, err := Parse(s, currency)
+	if err != nil {
+		return fmt.Errorf("money: %w", err)
+	}
+	*m = parsed
+	return nil
+}
+
+// common returns the currency of an operation on m and other
+func (m Money) common(other Money) (string, error) {
+	switch {
+	case m.currency == other.currency || other.currency == "":
+		return m.currency, nil
+	case m.currency == "":
+		return other.currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, other.currency)
+}
+
+// divRound divides n by d and rounds the quotient with mode, failing with
+// ErrOverflow if it does not fit in int64
+func divRound(n, d *big.Int, mode RoundingMode) (int64, error) {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Sign() != 0 {
+		// Direction away from zero
+		step := big.NewInt(int64(n.Sign() * d.Sign()))
+
+		// Compare the remainder with half the divisor
+		half := new(big.Int).Abs(r)
+		half.Lsh(half, 1)
+		cmp := half.Cmp(new(big.Int).Abs(d))
+
+		switch mode {
+		case RoundUp:
+			q.Add(q, step)
+		case RoundHalfUp:
+			if cmp >= 0 {
+				q.Add(q, step)
+			}
+		case RoundHalfEven:
+			if cmp > 0 || (cmp == 0 && q.Bit(0) != 0) {
+				q.Add(q, step)
+			}
+		}
+	}
+
+	if !q.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return q.Int64(), nil
+}
+
+// pow10 returns 10^n
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// isCurrencyCode reports whether code looks like an ISO 4217 code
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < 'A' || code[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// GobEncode keeps the currency, unlike JSON, so stored amounts round trip
+func (m Money) GobEncode() ([]byte, error) {
+	return []byte(strconv.FormatInt(m.amount, 10) + " " + m.currency), nil
+}
+
+// GobDecode reads an amount written by GobEncode
+func (m *Money) GobDecode(data []byte) error {
+	amount, currency, _ := strings.Cut(string(data), " ")
+	n, err := strconv.ParseInt(amount, 10, 64)
+	if err != nil {
+		return fmt.Errorf("money: invalid gob amount %q", data)
+	}
+	*m = Money{amount: n, currency: currency}
+	return nil
+}
@@ -0,0 +1,302 @@
+package money
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency string
+		minor    int64
+		wantErr  bool
+	}{
+		{"12.99", "USD", 1299, false},
+		{"12.9", "usd", 1290, false},
+		{"12", "USD", 1200, false},
+		{"-0.05", "USD", -5, false},
+		{"1e2", "USD", 10000, false},
+		{"1500", "JPY", 1500, false},
+		{"1.234", "KWD", 1234, false},
+		{"12.999", "USD", 0, true},
+		{"1.5", "JPY", 0, true},
+		{"abc", "USD", 0, true},
+		{"1.00", "US", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input+" "+tt.currency, func(t *testing.T) {
+			m, err := Parse(tt.input, tt.currency)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.minor, m.Minor())
+		})
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	assert.Equal(t, "12.99", New(1299, "USD").Decimal())
+	assert.Equal(t, "0.05", New(5, "USD").Decimal())
+	assert.Equal(t, "-0.05", New(-5, "USD").Decimal())
+	assert.Equal(t, "0.00", Money{}.Decimal())
+	assert.Equal(t, "1500", New(1500, "JPY").Decimal())
+	assert.Equal(t, "1.234", New(1234, "KWD").Decimal())
+	assert.Equal(t, "12.99 USD", New(1299, "usd").String())
+}
+
+func TestRoundingModes(t *testing.T) {
+	tests := []struct {
+		name  string
+		minor int64
+		mode  RoundingMode
+		want  int64
+	}{
+		// 18% of 25 cents is 4.5 cents
+		{"Half up", 25, RoundHalfUp, 5},
+		{"Half even down", 25, RoundHalfEven, 4},
+		{"Down", 25, RoundDown, 4},
+		{"Up", 25, RoundUp, 5},
+		{"Negative half up", -25, RoundHalfUp, -5},
+		{"Negative down", -25, RoundDown, -4},
+		// 18% of 35 cents is 6.3 cents
+		{"Half up below half", 35, RoundHalfUp, 6},
+		{"Up below half", 35, RoundUp, 7},
+		// 18% of 75 cents is 13.5 cents
+		{"Half even up", 75, RoundHalfEven, 14},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.minor, "USD").Percent(18, tt.mode)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Minor())
+		})
+	}
+}
+
+func TestAddCurrencies(t *testing.T) {
+	usd := New(100, "USD")
+
+	sum, err := Money{}.Add(usd)
+	require.NoError(t, err)
+	assert.Equal(t, usd, sum, "Zero value adopts the other currency")
+
+	_, err = usd.Add(New(100, "AUD"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = usd.Cmp(New(100, "AUD"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+}
+
+func TestJSON(t *testing.T) {
+	var product struct {
+		Price Money `json:"price"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"price": 12.99}`), &product))
+	assert.Equal(t, New(1299, DefaultCurrency), product.Price)
+
+	data, err := json.Marshal(product)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"price": 12.99}`, string(data))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"price": "12.99"}`), &product), "Prices are numbers")
+	assert.Error(t, json.Unmarshal([]byte(`{"price": 12.999}`), &product), "Sub-cent prices are rejected")
+
+	// The receiver's currency decides the decimals
+	yen := New(0, "JPY")
+	require.NoError(t, json.Unmarshal([]byte(`1500`), &yen))
+	assert.Equal(t, New(1500, "JPY"), yen)
+}
+
+// amount keeps generated values far from int64 overflow
+func amount(n int32) Money {
+	return New(int64(n), "USD")
+}
+
+func TestProperty_AddCommutativeAndAssociative(t *testing.T) {
+	f := func(a, b, c int32) bool {
+		ab, _ := amount(a).Add(amount(b))
+		ba, _ := amount(b).Add(amount(a))
+		abc1, _ := ab.Add(amount(c))
+		bc, _ := amount(b).Add(amount(c))
+		abc2, _ := amount(a).Add(bc)
+		return ab == ba && abc1 == abc2
+	}
+	assert.NoError(t, quick.Check(f, nil))
+}
+
+func TestProperty_SubInvertsAdd(t *testing.T) {
+	f := func(a, b int32) bool {
+		sum, _ := amount(a).Add(amount(b))
+		back, _ := sum.Sub(amount(b))
+		return back == amount(a)
+	}
+	assert.NoError(t, quick.Check(f, nil))
+}
+
+func TestProperty_MulIsRepeatedAdd(t *testing.T) {
+	f := func(a int32, n uint8) bool {
+		var sum Money
+		for i := 0; i < int(n); i++ {
+			sum, _ = sum.Add(amount(a))
+		}
+		product, err := amount(a).Mul(int64(n))
+		return err == nil && product.Minor() == sum.Minor()
+	}
+	assert.NoError(t, quick.Check(f, nil))
+}
+
+func TestProperty_MulDistributesOverAdd(t *testing.T) {
+	f := func(a, b int32, n int16) bool {
+		sum, _ := amount(a).Add(amount(b))
+		left, _ := sum.Mul(int64(n))
+		an, _ := amount(a).Mul(int64(n))
+		bn, _ := amount(b).Mul(int64(n))
+		right, _ := an.Add(bn)
+		return left == right
+	}
+	assert.NoError(t, quick.Check(f, nil))
+}
+
+func TestProperty_PercentWithinHalfUnit(t *testing.T) {
+	f := func(a int32, p uint8) bool {
+		percent := int64(p % 101)
+		exact := int64(a) * percent // In hundredths of a minor unit
+
+		percentOf := func(mode RoundingMode) int64 {
+			m, _ := amount(a).Percent(percent, mode)
+			return m.Minor() * 100
+		}
+
+		for _, mode := range []RoundingMode{RoundHalfUp, RoundHalfEven} {
+			diff := percentOf(mode) - exact
+			if diff > 50 || diff < -50 {
+				return false
+			}
+		}
+
+		down := percentOf(RoundDown)
+		up := percentOf(RoundUp)
+		if a >= 0 {
+			return down <= exact && exact <= up && up-down <= 100
+		}
+		return up <= exact && exact <= down && down-up <= 100
+	}
+	assert.NoError(t, quick.Check(f, nil))
+}
+
+func TestProperty_DiscountNeverExceedsAmount(t *testing.T) {
+	f := func(a uint32, p uint8) bool {
+		price := New(int64(a), "USD")
+		discount, err := price.Percent(int64(p%101), RoundHalfUp)
+		rest, _ := price.Sub(discount)
+		return err == nil && !discount.IsNegative() && !rest.IsNegative()
+	}
+	assert.NoError(t, quick.Check(f, nil))
+}
+
+func TestProperty_AddSubOverflowReported(t *testing.T) {
+	f := func(a, b int64) bool {
+		want := new(big.Int).Add(big.NewInt(a), big.NewInt(b))
+		sum, err := New(a, "USD").Add(New(b, "USD"))
+		if !want.IsInt64() {
+			return errors.Is(err, ErrOverflow)
+		}
+		if err != nil || sum.Minor() != want.Int64() {
+			return false
+		}
+
+		want.Sub(big.NewInt(a), big.NewInt(b))
+		diff, err := New(a, "USD").Sub(New(b, "USD"))
+		if !want.IsInt64() {
+			return errors.Is(err, ErrOverflow)
+		}
+		return err == nil && diff.Minor() == want.Int64()
+	}
+	assert.NoError(t, quick.Check(f, nil))
+}
+
+func TestProperty_MulOverflowReported(t *testing.T) {
+	f := func(a int64, n int32) bool {
+		want := new(big.Int).Mul(big.NewInt(a), big.NewInt(int64(n)))
+		product, err := New(a, "USD").Mul(int64(n))
+		if !want.IsInt64() {
+			return errors.Is(err, ErrOverflow)
+		}
+		return err == nil && product.Minor() == want.Int64()
+	}
+	assert.NoError(t, quick.Check(f, nil))
+}
+
+func TestOverflowBoundary(t *testing.T) {
+	largest := New(math.MaxInt64, "USD")
+	smallest := New(math.MinInt64, "USD")
+
+	_, err := largest.Add(New(1, "USD"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = smallest.Sub(New(1, "USD"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = smallest.Add(New(-1, "USD"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	sum, err := largest.Add(smallest)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), sum.Minor())
+
+	product, err := New(math.MaxInt64/2, "USD").Mul(2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64-1), product.Minor())
+	_, err = New(math.MaxInt64/2+1, "USD").Mul(2)
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = smallest.Mul(-1)
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	// The order from the bug report: 2e18 units at 12.99
+	_, err = New(1299, "USD").Mul(2000000000000000000)
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	whole, err := largest.Percent(100, RoundHalfUp)
+	require.NoError(t, err)
+	assert.Equal(t, largest, whole, "Intermediate products may exceed int64")
+	_, err = largest.MulFrac(3, 2, RoundHalfUp)
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = New(math.MaxInt64-1, "USD").MulFrac(3, 3, RoundUp)
+	assert.NoError(t, err)
+	_, err = largest.MulFrac(2, 2, RoundUp)
+	assert.NoError(t, err)
+}
+
+func TestProperty_JSONRoundTrip(t *testing.T) {
+	f := func(a int64) bool {
+		m := New(a, "USD")
+		data, err := json.Marshal(m)
+		if err != nil {
+			return false
+		}
+		var back Money
+		return json.Unmarshal(data, &back) == nil && back == m
+	}
+	assert.NoError(t, quick.Check(f, nil))
+}
+
+func TestGobKeepsCurrency(t *testing.T) {
+	var buf bytes.Buffer
+	in := struct{ Price, Zero Money }{Price: New(150000, "JPY")}
+	require.NoError(t, gob.NewEncoder(&buf).Encode(in))
+
+	var out struct{ Price, Zero Money }
+	require.NoError(t, gob.NewDecoder(&buf).Decode(&out))
+	assert.Equal(t, in, out)
+}
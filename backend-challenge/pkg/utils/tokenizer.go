@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"bufio"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxCouponTokenLen is the longest whitespace separated token CouponScanner
+// returns. Longer runs cannot be promo codes and are skipped whole, so the
+// scanner never fails on binary or garbage input.
+const MaxCouponTokenLen = 1024
+
+// CouponScanner splits coupon text into sanitized words.
+// Unlike splitting fixed size read chunks, words that span reads are
+// returned intact, so results do not depend on how the input is buffered.
+type CouponScanner struct {
+	scanner  *bufio.Scanner
+	skipping bool // Inside a token longer than MaxCouponTokenLen
+	text     string
+}
+
+// NewCouponScanner creates a scanner reading from r
+func NewCouponScanner(r io.Reader) *CouponScanner {
+	s := &CouponScanner{scanner: bufio.NewScanner(r)}
+	s.scanner.Buffer(make([]byte, 64*1024), 64*1024)
+	s.scanner.Split(s.split)
+	return s
+}
+
+// Scan advances to the next word, returning false at the end of input or on error
+func (s *CouponScanner) Scan() bool {
+	for s.scanner.Scan() {
+		if text := SanitizeString(s.scanner.Text()); text != "" {
+			s.text = text
+			return true
+		}
+	}
+	return false
+}
+
+// Text returns the current word, uppercased with surrounding punctuation removed
+func (s *CouponScanner) Text() string {
+	return s.text
+}
+
+// Err returns the first read error, if any
+func (s *CouponScanner) Err() error {
+	return s.scanner.Err()
+}
+
+// split is a bufio.SplitFunc like bufio.ScanWords that discards over-long
+// tokens instead of failing with bufio.ErrTooLong
+func (s *CouponScanner) split(data []byte, atEOF bool) (int, []byte, error) {
+	// Skip leading spaces
+	start := 0
+	for start < len(data) {
+		r, width := utf8.DecodeRune(data[start:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		start += width
+	}
+
+	// A space ends any over-long token being skipped
+	if start > 0 {
+		s.skipping = false
+	}
+
+	// Scan until a space marks the end of the word
+	for i := start; i < len(data); {
+		r, width := utf8.DecodeRune(data[i:])
+		if unicode.IsSpace(r) {
+			return i + width, s.token(data[start:i]), nil
+		}
+		i += width
+	}
+
+	if atEOF && len(data) > start {
+		return len(data), s.token(data[start:]), nil
+	}
+
+	// Drop the buffered part of an over-long token and keep skipping until the next space
+	if len(data)-start > MaxCouponTokenLen {
+		s.skipping = true
+		return len(data), nil, nil
+	}
+
+	// Request more data
+	return start, nil, nil
+}
+
+// token completes a word, dropping it if it is the tail of an over-long token
+func (s *CouponScanner) token(word []byte) []byte {
+	if s.skipping || len(word) > MaxCouponTokenLen {
+		s.skipping = false
+		return nil
+	}
+	return word
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkReader returns at most n bytes per Read
+type chunkReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if len(p) > c.n {
+		p = p[:c.n]
+	}
+	return c.r.Read(p)
+}
+
+// scanAll collects every word the scanner returns
+func scanAll(t *testing.T, r io.Reader) []string {
+	t.Helper()
+
+	var words []string
+	scanner := NewCouponScanner(r)
+	for scanner.Scan() {
+		words = append(words, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	return words
+}
+
+func TestCouponScanner_Words(t *testing.T) {
+	input := "happyhrs, (FIFTYOFF)\tSave20Pc!\n\n  \"quoted\" ...  SUPER100\r\n"
+
+	words := scanAll(t, strings.NewReader(input))
+	assert.Equal(t, []string{"HAPPYHRS", "FIFTYOFF", "SAVE20PC", "QUOTED", "SUPER100"}, words,
+		"Punctuation-only tokens should be dropped")
+}
+
+func TestCouponScanner_BufferBoundaries(t *testing.T) {
+	// Place codes so they straddle common buffer sizes
+	var b strings.Builder
+	var expected []string
+	for _, boundary := range []int{4096, 32 * 1024, 64 * 1024, 128 * 1024, 200_000} {
+		for b.Len() < boundary-4 {
+			b.WriteString("filler ")
+			expected = append(expected, "FILLER")
+		}
+		for b.Len() < boundary-4 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(" SPLIT")
+		b.WriteString(strings.Repeat("X", boundary%5))
+		b.WriteString("CODE ")
+		expected = append(expected, "SPLIT"+strings.Repeat("X", boundary%5)+"CODE")
+	}
+	b.WriteString("LASTCODE1")
+	expected = append(expected, "LASTCODE1")
+	input := b.String()
+
+	readers := map[string]func() io.Reader{
+		"whole":     func() io.Reader { return strings.NewReader(input) },
+		"one byte":  func() io.Reader { return iotest.OneByteReader(strings.NewReader(input)) },
+		"half":      func() io.Reader { return iotest.HalfReader(strings.NewReader(input)) },
+		"data err":  func() io.Reader { return iotest.DataErrReader(strings.NewReader(input)) },
+		"7 bytes":   func() io.Reader { return &chunkReader{strings.NewReader(input), 7} },
+		"64k bytes": func() io.Reader { return &chunkReader{strings.NewReader(input), 64 * 1024} },
+		"odd chunk": func() io.Reader { return &chunkReader{strings.NewReader(input), 65533} },
+	}
+
+	for name, newReader := range readers {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, expected, scanAll(t, newReader()))
+		})
+	}
+}
+
+func TestCouponScanner_SkipsOverLongTokens(t *testing.T) {
+	long := strings.Repeat("A", 200_000)
+	input := "HAPPYHRS " + long + " FIFTYOFF " + strings.Repeat("B", MaxCouponTokenLen+1)
+
+	words := scanAll(t, iotest.HalfReader(strings.NewReader(input)))
+	assert.Equal(t, []string{"HAPPYHRS", "FIFTYOFF"}, words)
+
+	exact := strings.Repeat("C", MaxCouponTokenLen)
+	words = scanAll(t, strings.NewReader(exact+" DISCOUNT"))
+	assert.Equal(t, []string{exact, "DISCOUNT"}, words)
+}
+
+func TestCouponScanner_ReadError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("HAPPYHRS "), iotest.ErrReader(io.ErrUnexpectedEOF))
+
+	scanner := NewCouponScanner(r)
+	require.True(t, scanner.Scan())
+	assert.Equal(t, "HAPPYHRS", scanner.Text())
+	assert.False(t, scanner.Scan())
+	assert.ErrorIs(t, scanner.Err(), io.ErrUnexpectedEOF)
+}
@@ -5,13 +5,20 @@ import (
 	"strings"
 )
 
-// IsValidID validates if an ID is in correct format
+// IsValidID validates if an ID is in correct format: a positive decimal
+// number without sign or leading zeros, so every product has exactly one ID
+// string and "07" or "+7" cannot stand in for "7"
 func IsValidID(id string) bool {
-	if id == "" {
+	if id == "" || id[0] == '0' {
 		return false
 	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '0' || id[i] > '9' {
+			return false
+		}
+	}
 
-	// Check if it's a valid numeric ID
+	// Check that it fits an int
 	if _, err := strconv.Atoi(id); err != nil {
 		return false
 	}
@@ -19,6 +26,21 @@ func IsValidID(id string) bool {
 	return true
 }
 
+// IsValidOrderID validates the format of IDs made by the order handler,
+// e.g. ORD-250101-120000-1A2B3C4D
+func IsValidOrderID(id string) bool {
+	if !strings.HasPrefix(id, "ORD-") || len(id) > 64 {
+		return false
+	}
+
+	for _, part := range strings.Split(id[len("ORD-"):], "-") {
+		if part == "" || !IsAlphanumeric(part) {
+			return false
+		}
+	}
+	return true
+}
+
 // IsAlphanumeric checks if string contains only letters and numbers
 func IsAlphanumeric(s string) bool {
 	for _, r := range s {
@@ -20,6 +20,11 @@ func TestIsValidID(t *testing.T) {
 		{"Invalid alphanumeric", "123abc", false},
 		{"Invalid with spaces", "123 456", false},
 		{"Invalid with special chars", "123!", false},
+		{"Invalid leading zero", "07", false},
+		{"Invalid zero", "0", false},
+		{"Invalid plus sign", "+7", false},
+		{"Invalid negative", "-7", false},
+		{"Invalid overflow", "99999999999999999999", false},
 	}
 
 	for _, tt := range tests {
@@ -30,6 +35,28 @@ func TestIsValidID(t *testing.T) {
 	}
 }
 
+func TestIsValidOrderID(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		expected bool
+	}{
+		{"Generated ID", "ORD-250101-120000-1A2B3C4D", true},
+		{"Fallback ID", "ORD-250101-120000-FALLBACK", true},
+		{"Missing prefix", "250101-120000-1A2B3C4D", false},
+		{"Empty part", "ORD-250101--1A2B3C4D", false},
+		{"Prefix only", "ORD-", false},
+		{"Path characters", "ORD-../../etc", false},
+		{"Product ID", "1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsValidOrderID(tt.id))
+		})
+	}
+}
+
 func TestIsAlphanumeric(t *testing.T) {
 	tests := []struct {
 		name     string
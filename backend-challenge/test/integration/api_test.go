@@ -2,65 +2,137 @@ package integration
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ilyulev/kart-challenge/backend-api/internal/auth"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/config"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/handlers"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/logging"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/metrics"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/middleware"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/models"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/pricing"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/repository"
 	"github.com/ilyulev/kart-challenge/backend-api/internal/services"
+	"github.com/ilyulev/kart-challenge/backend-api/internal/tracing"
 
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type APITestSuite struct {
 	suite.Suite
-	echo           *echo.Echo
-	promoService   *services.PromoCodeService
-	productHandler *handlers.ProductHandler
-	orderHandler   *handlers.OrderHandler
-	healthHandler  *handlers.HealthHandler
+	echo             *echo.Echo
+	promoService     *services.PromoCodeService
+	productHandler   *handlers.ProductHandler
+	categoryHandler  *handlers.CategoryHandler
+	orderHandler     *handlers.OrderHandler
+	healthHandler    *handlers.HealthHandler
+	inventoryHandler *handlers.InventoryHandler
+	inventory        repository.InventoryStore
+	metrics          *metrics.Registry
+	spans            *tracetest.InMemoryExporter
 }
 
 func (suite *APITestSuite) SetupSuite() {
 	// Initialize services
-	suite.promoService = services.NewPromoCodeService()
-	err := suite.promoService.Initialize()
+	suite.metrics = metrics.NewRegistry()
+	tracerProvider, spans := tracing.NewInMemory()
+	suite.spans = spans
+	// Coupon files come from testdata, so the suite runs offline: HAPPYHRS,
+	// FIFTYOFF, WELCOME1, SUMMER25 and WINTER15 are in two or more files,
+	// NOTVALID1 and ONLYHERE in one only
+	suite.promoService = services.NewPromoCodeService(
+		services.WithCouponSources(services.NewDirectorySource("testdata")),
+		services.WithMetrics(suite.metrics))
+	err := suite.promoService.Initialize(context.Background())
 	require.NoError(suite.T(), err)
 
-	// Wait a moment for async initialization
-	time.Sleep(100 * time.Millisecond)
+	// Wait for the background load to replace the mock codes
+	require.Eventually(suite.T(), func() bool {
+		return suite.promoService.GetServiceStatus().IsFullyLoaded
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(suite.T(), 5, suite.promoService.GetValidCodesCount())
 
 	// Initialize handlers
-	suite.productHandler = handlers.NewProductHandler()
-	suite.orderHandler = handlers.NewOrderHandler(suite.promoService)
+	products, err := repository.NewMemoryProductRepository(repository.DefaultCategories(), repository.DefaultProducts())
+	require.NoError(suite.T(), err)
+	suite.inventory = repository.NewMemoryInventoryStore()
+	suite.productHandler = handlers.NewProductHandler(products, suite.inventory, logging.Discard())
+	suite.categoryHandler = handlers.NewCategoryHandler(products, suite.inventory, logging.Discard())
+	redemptions, err := services.NewPromoRedemptions([]services.PromoTerms{
+		{Code: "WELCOME1", MaxPerCustomer: 1},
+	}, repository.NewMemoryRedemptionStore())
+	require.NoError(suite.T(), err)
+	suite.orderHandler = handlers.NewOrderHandler(suite.promoService, pricing.NewEngine(), products, suite.inventory,
+		repository.NewMemoryOrderRepository(), redemptions, logging.Discard(), suite.metrics)
 	suite.healthHandler = handlers.NewHealthHandler(suite.promoService)
+	suite.inventoryHandler = handlers.NewInventoryHandler(products, suite.inventory, logging.Discard())
 
 	// Setup Echo
 	suite.echo = echo.New()
-	suite.echo.Use(echomiddleware.Logger())
+	suite.echo.JSONSerializer = middleware.RequestIDSerializer{}
+	suite.echo.HTTPErrorHandler = middleware.HTTPErrorHandler
+	suite.echo.Use(middleware.RequestID())
+	suite.echo.Use(middleware.RequestLogger(logging.Discard()))
+	suite.echo.Use(middleware.Tracing(tracerProvider))
+	suite.echo.Use(middleware.Metrics(suite.metrics))
 	suite.echo.Use(echomiddleware.Recover())
 	suite.setupRoutes()
 }
 
+func (suite *APITestSuite) TearDownSuite() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	suite.NoError(suite.promoService.Shutdown(ctx))
+}
+
 func (suite *APITestSuite) setupRoutes() {
 	// API routes
+	keys, err := auth.NewKeySet([]auth.APIKey{
+		{Name: "default", Key: "apitest", Scopes: config.DefaultKeyScopes},
+		{Name: "reporting", Key: "readonly", Scopes: []string{auth.ScopeReadOrders}},
+		{Name: "operator", Key: "admin-secret", Scopes: []string{auth.ScopeAll}},
+	})
+	require.NoError(suite.T(), err)
+
 	api := suite.echo.Group("/api")
 	api.GET("/product", suite.productHandler.ListProducts)
 	api.GET("/product/:productId", suite.productHandler.GetProduct)
-	api.POST("/order", suite.orderHandler.PlaceOrder, middleware.APIKeyAuth())
+	api.GET("/category", suite.categoryHandler.ListCategories)
+	api.GET("/category/:slug/products", suite.categoryHandler.ListCategoryProducts)
+	productAuth := middleware.APIKeyAuth(keys, auth.ScopeManageProducts)
+	api.POST("/product", suite.productHandler.CreateProduct, productAuth)
+	api.PATCH("/product/:productId", suite.productHandler.PatchProduct, productAuth)
+	api.DELETE("/product/:productId", suite.productHandler.DeleteProduct, productAuth)
+	api.POST("/order", suite.orderHandler.PlaceOrder, middleware.APIKeyAuth(keys, auth.ScopeCreateOrder),
+		middleware.Idempotency(repository.NewMemoryIdempotencyStore(), time.Hour))
+	api.GET("/order", suite.orderHandler.ListOrders, middleware.APIKeyAuth(keys, auth.ScopeReadOrders))
+	api.GET("/order/:orderId", suite.orderHandler.GetOrder, middleware.APIKeyAuth(keys, auth.ScopeReadOrders))
+
+	// Admin routes
+	adminHandler := handlers.NewAdminHandler(suite.promoService)
+	admin := suite.echo.Group("/admin", middleware.APIKeyAuth(keys, auth.ScopeAdmin))
+	admin.GET("/promo/errors", adminHandler.PromoLoadErrors)
+	admin.GET("/promo/codes/:code", adminHandler.CheckPromoCode)
+	admin.POST("/inventory/:productId/restock", suite.inventoryHandler.Restock)
 
 	// Health routes
 	suite.echo.GET("/health", suite.healthHandler.Health)
 	suite.echo.GET("/health/live", suite.healthHandler.LivenessProbe)
 	suite.echo.GET("/health/ready", suite.healthHandler.ReadinessProbe)
+	suite.echo.GET("/metrics", echo.WrapHandler(suite.metrics.Handler()))
 }
 
 func (suite *APITestSuite) TestHealthEndpoints() {
@@ -149,6 +221,18 @@ func (suite *APITestSuite) TestOrderEndpoints() {
 		assert.Equal(suite.T(), http.StatusUnauthorized, rec.Code)
 	})
 
+	// Test order with a key lacking the create_order scope
+	suite.Run("Read-only key cannot place orders", func() {
+		req := httptest.NewRequest(http.MethodPost, "/api/order", bytes.NewBufferString(`{"items":[{"productId":"1","quantity":1}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("api_key", "readonly")
+		rec := httptest.NewRecorder()
+
+		suite.echo.ServeHTTP(rec, req)
+
+		assert.Equal(suite.T(), http.StatusForbidden, rec.Code)
+	})
+
 	// Test valid order with API key
 	suite.Run("Valid order with API key", func() {
 		orderReq := models.OrderRequest{
@@ -184,7 +268,7 @@ func (suite *APITestSuite) TestOrderEndpoints() {
 			Items: []models.OrderItem{
 				{ProductID: "1", Quantity: 1},
 			},
-			CouponCode: "HAPPYHRS", // This should be in mock data
+			CouponCode: "HAPPYHRS", // In every fixture file
 		}
 
 		body, err := json.Marshal(orderReq)
@@ -250,7 +334,7 @@ func (suite *APITestSuite) TestFullOrderWorkflow() {
 				{ProductID: products[0].ID, Quantity: 2},
 				{ProductID: products[1].ID, Quantity: 1},
 			},
-			CouponCode: "HAPPYHRS", // Valid mock promo code
+			CouponCode: "HAPPYHRS", // Valid fixture promo code
 		}
 
 		body, err := json.Marshal(orderReq)
@@ -275,9 +359,310 @@ func (suite *APITestSuite) TestFullOrderWorkflow() {
 		assert.Len(suite.T(), order.Products, 2)
 		assert.Equal(suite.T(), orderReq.Items[0].ProductID, order.Items[0].ProductID)
 		assert.Equal(suite.T(), orderReq.Items[0].Quantity, order.Items[0].Quantity)
+
+		// Step 4: Look the order up again with the read-only key
+		req = httptest.NewRequest(http.MethodGet, "/api/order/"+order.ID, nil)
+		req.Header.Set("api_key", "readonly")
+		rec = httptest.NewRecorder()
+		suite.echo.ServeHTTP(rec, req)
+
+		require.Equal(suite.T(), http.StatusOK, rec.Code)
+
+		var stored models.Order
+		require.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &stored))
+		assert.Equal(suite.T(), order.ID, stored.ID)
+		assert.Equal(suite.T(), order.Total, stored.Total)
 	})
 }
 
+func (suite *APITestSuite) TestIdempotentOrderRetry() {
+	place := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/order", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("api_key", "apitest")
+		req.Header.Set(middleware.IdempotencyKeyHeader, "pos-terminal-7-txn-42")
+		rec := httptest.NewRecorder()
+		suite.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := place(`{"items":[{"productId":"3","quantity":1}]}`)
+	require.Equal(suite.T(), http.StatusOK, first.Code)
+
+	retry := place(`{"items":[{"productId":"3","quantity":1}]}`)
+	require.Equal(suite.T(), http.StatusOK, retry.Code)
+	assert.Equal(suite.T(), first.Body.String(), retry.Body.String(), "The retry returns the original order")
+
+	changed := place(`{"items":[{"productId":"3","quantity":2}]}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, changed.Code)
+}
+
+func (suite *APITestSuite) TestCatalogChangesReachOrders() {
+	serve := func(method, target, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("api_key", apiKey)
+		rec := httptest.NewRecorder()
+		suite.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/api/product", "readonly", `{"name":"Hash Brown","price":3.5,"category":"Main"}`)
+	assert.Equal(suite.T(), http.StatusForbidden, rec.Code)
+	rec = serve(http.MethodPost, "/api/product", "apitest", `{"name":"Hash Brown","price":3.5,"category":"Main"}`)
+	assert.Equal(suite.T(), http.StatusForbidden, rec.Code, "The public default key cannot change the catalog")
+
+	rec = serve(http.MethodPost, "/api/product", "admin-secret", `{"name":"Hash Brown","price":3.5,"category":"Main"}`)
+	require.Equal(suite.T(), http.StatusCreated, rec.Code, rec.Body.String())
+	var product models.Product
+	require.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &product))
+
+	// A price change applies to the next order
+	rec = serve(http.MethodPatch, "/api/product/"+product.ID, "admin-secret", fmt.Sprintf(`{"price":4,"version":%d}`, product.Version))
+	require.Equal(suite.T(), http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = serve(http.MethodPost, "/api/order", "apitest", fmt.Sprintf(`{"items":[{"productId":"%s","quantity":2}]}`, product.ID))
+	require.Equal(suite.T(), http.StatusOK, rec.Code, rec.Body.String())
+	var order models.Order
+	require.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &order))
+	assert.Equal(suite.T(), "8.00", order.Total.Decimal())
+
+	// Deleted products can no longer be ordered
+	rec = serve(http.MethodDelete, "/api/product/"+product.ID+"?version=2", "admin-secret", "")
+	require.Equal(suite.T(), http.StatusNoContent, rec.Code)
+	rec = serve(http.MethodPost, "/api/order", "apitest", fmt.Sprintf(`{"items":[{"productId":"%s","quantity":1}]}`, product.ID))
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, rec.Code)
+}
+
+func (suite *APITestSuite) TestCategoryMenu() {
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		suite.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/api/category")
+	require.Equal(suite.T(), http.StatusOK, rec.Code)
+	var categories []models.Category
+	require.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &categories))
+	require.NotEmpty(suite.T(), categories)
+
+	// Every product is listed under exactly one category
+	total := 0
+	for _, category := range categories {
+		rec := get("/api/category/" + category.Slug + "/products")
+		require.Equal(suite.T(), http.StatusOK, rec.Code, category.Slug)
+		var products []models.Product
+		require.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &products))
+		for _, p := range products {
+			assert.Equal(suite.T(), category.Name, p.Category)
+		}
+		total += len(products)
+	}
+
+	rec = get("/api/product")
+	var products []models.Product
+	require.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &products))
+	assert.Equal(suite.T(), len(products), total)
+
+	assert.Equal(suite.T(), http.StatusNotFound, get("/api/category/drinks/products").Code)
+}
+
+func (suite *APITestSuite) TestStockReservation() {
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("api_key", "apitest")
+		if strings.HasPrefix(target, "/admin") {
+			req.Header.Set("api_key", "admin-secret")
+		}
+		rec := httptest.NewRecorder()
+		suite.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/admin/inventory/6/restock", `{"quantity":2}`)
+	require.Equal(suite.T(), http.StatusOK, rec.Code, rec.Body.String())
+
+	// Product 6 is available until its last units are ordered
+	var product models.Product
+	require.NoError(suite.T(), json.Unmarshal(serve(http.MethodGet, "/api/product/6", "").Body.Bytes(), &product))
+	assert.True(suite.T(), product.Available)
+
+	rec = serve(http.MethodPost, "/api/order", `{"items":[{"productId":"6","quantity":3}]}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, rec.Code)
+	var resp models.APIResponse
+	require.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(suite.T(), models.ReasonOutOfStock, resp.Reason)
+	assert.Equal(suite.T(), []models.StockShortage{{ProductID: "6", Requested: 3, Available: 2}}, resp.Shortages)
+
+	rec = serve(http.MethodPost, "/api/order", `{"items":[{"productId":"6","quantity":2}]}`)
+	require.Equal(suite.T(), http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(suite.T(), json.Unmarshal(serve(http.MethodGet, "/api/product/6", "").Body.Bytes(), &product))
+	assert.False(suite.T(), product.Available)
+
+	rec = serve(http.MethodPost, "/api/order", `{"items":[{"productId":"6","quantity":1}]}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, rec.Code)
+}
+
+func (suite *APITestSuite) TestPromoRedemptionLimits() {
+	place := func(body string) (int, models.APIResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/api/order", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("api_key", "apitest")
+		rec := httptest.NewRecorder()
+		suite.echo.ServeHTTP(rec, req)
+
+		var resp models.APIResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	code, resp := place(`{"couponCode":"WELCOME1","items":[{"productId":"1","quantity":1}]}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, code)
+	assert.Equal(suite.T(), models.ReasonCustomerRequired, resp.Reason)
+
+	code, _ = place(`{"couponCode":"WELCOME1","customerId":"cust-1","items":[{"productId":"1","quantity":1}]}`)
+	assert.Equal(suite.T(), http.StatusOK, code)
+
+	code, resp = place(`{"couponCode":"welcome1","customerId":"cust-1","items":[{"productId":"2","quantity":1}]}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, code)
+	assert.Equal(suite.T(), models.ReasonPromoAlreadyUsed, resp.Reason)
+
+	code, _ = place(`{"couponCode":"WELCOME1","customerId":"cust-2","items":[{"productId":"1","quantity":1}]}`)
+	assert.Equal(suite.T(), http.StatusOK, code, "Other customers can still use the code")
+}
+
+func (suite *APITestSuite) TestAdminEndpointsNeedAdminScope() {
+	tests := []struct {
+		name           string
+		apiKey         string
+		expectedStatus int
+	}{
+		{"Without key", "", http.StatusUnauthorized},
+		{"Read-only key", "readonly", http.StatusForbidden},
+		{"Public default key", "apitest", http.StatusForbidden},
+		{"Key with every scope", "admin-secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			req := httptest.NewRequest(http.MethodGet, "/admin/promo/codes/HAPPYHRS", nil)
+			req.Header.Set("api_key", tt.apiKey)
+			rec := httptest.NewRecorder()
+
+			suite.echo.ServeHTTP(rec, req)
+
+			assert.Equal(suite.T(), tt.expectedStatus, rec.Code)
+		})
+	}
+}
+
+// scrape fetches /metrics and parses it
+func (suite *APITestSuite) scrape() []metrics.Sample {
+	rec := httptest.NewRecorder()
+	suite.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(suite.T(), http.StatusOK, rec.Code)
+	assert.Equal(suite.T(), metrics.ContentType, rec.Header().Get("Content-Type"))
+
+	samples, err := metrics.ParseText(rec.Body)
+	require.NoError(suite.T(), err)
+	return samples
+}
+
+func (suite *APITestSuite) TestMetrics() {
+	// Other tests share the registry, so only changes are compared
+	value := func(samples []metrics.Sample, name string, labels ...string) float64 {
+		v, _ := metrics.Find(samples, name, labels...)
+		return v
+	}
+	place := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/order", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("api_key", "apitest")
+		rec := httptest.NewRecorder()
+		suite.echo.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	before := suite.scrape()
+	suite.Equal(http.StatusOK, place(`{"couponCode":"HAPPYHRS","items":[{"productId":"1","quantity":1}]}`))
+	suite.Equal(http.StatusUnprocessableEntity, place(`{"couponCode":"NOTVALID1","items":[{"productId":"1","quantity":1}]}`))
+	suite.Equal(http.StatusUnprocessableEntity, place(`{"items":[]}`))
+	rec := httptest.NewRecorder()
+	suite.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/order/ORD-1", nil))
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+	after := suite.scrape()
+
+	delta := func(name string, labels ...string) float64 {
+		return value(after, name, labels...) - value(before, name, labels...)
+	}
+	suite.Equal(1.0, delta("orders_placed_total"))
+	suite.Equal(1.0, delta("orders_rejected_total", "reason", "promo_invalid"))
+	suite.Equal(1.0, delta("orders_rejected_total", "reason", "invalid_request"))
+	suite.Equal(1.0, delta("promo_validations_total", "result", "hit"))
+	suite.Equal(1.0, delta("promo_validations_total", "result", "miss"))
+	suite.Equal(1.0, delta("http_requests_total", "method", "POST", "route", "/api/order", "status", "200"))
+	suite.Equal(2.0, delta("http_requests_total", "method", "POST", "route", "/api/order", "status", "422"))
+	suite.Equal(1.0, delta("http_requests_total", "method", "GET", "route", "/api/order/:orderId", "status", "401"))
+	suite.Equal(2.0, delta("http_request_duration_seconds_count", "method", "POST", "route", "/api/order", "status", "422"))
+	suite.Equal(1.0, delta("http_request_duration_seconds_bucket", "route", "/api/order", "status", "200", "le", "+Inf"))
+
+	codes, ok := metrics.Find(after, "promo_codes_loaded")
+	suite.True(ok)
+	suite.Equal(float64(suite.promoService.GetValidCodesCount()), codes)
+	_, ok = metrics.Find(after, "promo_last_successful_load_timestamp_seconds")
+	suite.True(ok)
+}
+
+func (suite *APITestSuite) TestTracing() {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "/api/order", bytes.NewBufferString(`{"items":[{"productId":"1","quantity":1}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("api_key", "apitest")
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	suite.echo.ServeHTTP(rec, req)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+
+	// Other tests share the exporter, so only spans of this trace count
+	var names []string
+	for _, span := range suite.spans.GetSpans() {
+		if span.SpanContext.TraceID().String() == traceID {
+			names = append(names, span.Name)
+		}
+	}
+	suite.Contains(names, "POST /api/order")
+	suite.Contains(names, "order.reserve_stock")
+	suite.Contains(names, "order.save")
+}
+
+func (suite *APITestSuite) TestRequestID() {
+	// A rejected order names the client's request in the body and header
+	req := httptest.NewRequest(http.MethodPost, "/api/order", bytes.NewBufferString(`{"couponCode":"NOTVALID1","items":[{"productId":"1","quantity":1}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("api_key", "apitest")
+	req.Header.Set("X-Request-ID", "pos-7-order-42")
+	rec := httptest.NewRecorder()
+	suite.echo.ServeHTTP(rec, req)
+
+	suite.Equal(http.StatusUnprocessableEntity, rec.Code)
+	suite.Equal("pos-7-order-42", rec.Header().Get("X-Request-ID"))
+	var resp models.APIResponse
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	suite.Equal("pos-7-order-42", resp.RequestID)
+	suite.Equal(models.ReasonPromoInvalid, resp.Reason)
+
+	// Auth failures too, with a generated ID
+	rec = httptest.NewRecorder()
+	suite.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/order/ORD-1", nil))
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+	resp = models.APIResponse{}
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	suite.NotEmpty(resp.RequestID)
+	suite.Equal(rec.Header().Get("X-Request-ID"), resp.RequestID)
+}
+
 func TestAPIIntegration(t *testing.T) {
 	suite.Run(t, new(APITestSuite))
 }
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/ilyulev/kart-challenge/backend-api/internal/services"
 	"github.com/stretchr/testify/mock"
 )
@@ -10,8 +12,13 @@ type MockPromoCodeService struct {
 	mock.Mock
 }
 
-func (m *MockPromoCodeService) Initialize() error {
-	args := m.Called()
+func (m *MockPromoCodeService) Initialize(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockPromoCodeService) Shutdown(ctx context.Context) error {
+	args := m.Called(ctx)
 	return args.Error(0)
 }
 